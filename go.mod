@@ -1,3 +1,5 @@
 module go-redis
 
 go 1.22
+
+require github.com/yuin/gopher-lua v1.1.1
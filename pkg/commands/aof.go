@@ -0,0 +1,544 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-redis/pkg/resp"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The append-only file is the sequence of write commands in RESP form, in
+// the order they were applied. Commands whose effect depends on when or how
+// often they run are logged in a form that replays to the same result:
+// relative expiry times become absolute ones, SPOP becomes SREM of the
+// members it popped, a served blocking pop becomes its non-blocking
+// variant, and keys that expire are logged as DEL. A SELECT precedes each
+// command that applies to another database than the one before it.
+
+const (
+	errRewriteInProgress  = "ERR Background append only file rewriting already in progress"
+	errAppendOnlyDisabled = "ERR append only file is not enabled"
+	rewriteStarted        = "Background append only file rewriting started"
+
+	// aofRewriteItemsPerCommand caps how many elements a rewritten command
+	// carries, so huge collections do not turn into huge commands.
+	aofRewriteItemsPerCommand = 64
+)
+
+// AppendFsync is the policy for flushing the append-only file to disk.
+type AppendFsync int
+
+const (
+	// FsyncAlways syncs after every write command, before it is answered.
+	FsyncAlways AppendFsync = iota
+	// FsyncEverySec syncs once a second, losing at most a second of
+	// writes on a crash.
+	FsyncEverySec
+	// FsyncNo leaves flushing to the operating system.
+	FsyncNo
+)
+
+// ParseAppendFsync parses an appendfsync policy: always, everysec or no.
+func ParseAppendFsync(s string) (AppendFsync, error) {
+	switch strings.ToLower(s) {
+	case "always":
+		return FsyncAlways, nil
+	case "everysec":
+		return FsyncEverySec, nil
+	case "no":
+		return FsyncNo, nil
+	}
+	return 0, fmt.Errorf("invalid appendfsync policy %q: expected always, everysec or no", s)
+}
+
+var (
+	// aofMu guards the open append-only file and the rewrite buffer.
+	aofMu     sync.Mutex
+	aofFile   *os.File
+	aofPath   = "appendonly.aof"
+	aofFsync  = FsyncEverySec
+	aofFailed bool
+
+	// While a rewrite is running, every propagated command is also kept
+	// in rewriteBuf so it can be appended to the rewritten file.
+	rewriting  bool
+	rewriteBuf []byte
+	rewriteMu  sync.Mutex
+
+	// transactionDepth counts the transactions being propagated, such as
+	// EXEC and scripts, which may nest. transactionOpen is set once the
+	// first change made by the outermost one has been logged.
+	transactionDepth int
+	transactionOpen  bool
+
+	// aofSelectedDB is the database the commands appended last apply to,
+	// or -1 if the next command must select one first.
+	aofSelectedDB = -1
+
+	// loading is set while the append-only file is replayed. Keys do not
+	// expire during replay; the log carries a DEL wherever one did.
+	loading atomic.Bool
+)
+
+// LoadAppendOnly replays the append-only file at path and keeps it open
+// for appending every write command from then on. A missing file is
+// created. If the file ends in the middle of a command, as happens when
+// the server dies mid-write, the partial command is cut off. It returns
+// the number of commands replayed.
+func LoadAppendOnly(path string, fsync AppendFsync) (int, error) {
+	replayed, err := replayAppendOnly(path)
+	if err != nil {
+		return replayed, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return replayed, err
+	}
+	aofMu.Lock()
+	aofFile, aofPath, aofFsync, aofSelectedDB = f, path, fsync, -1
+	aofMu.Unlock()
+
+	if fsync == FsyncEverySec {
+		go runAppendOnlyFsync()
+	}
+	return replayed, nil
+}
+
+func replayAppendOnly(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	loading.Store(true)
+	defer loading.Store(false)
+	client := NewClient()
+	defer client.Close()
+
+	deserializer := resp.NewDeserializer(bytes.NewReader(data))
+	replayed, offset, multiOffset := 0, 0, 0
+	for offset < len(data) {
+		value, err := deserializer.Read()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			if err := truncateAppendOnly(path, data, offset); err != nil {
+				return replayed, err
+			}
+			break
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("bad append only file format at offset %d: %w", offset, err)
+		}
+		if value.DataType != resp.TypeArray || len(value.Array) == 0 {
+			return replayed, fmt.Errorf("bad append only file format at offset %d: expected a command", offset)
+		}
+
+		// The file was written with Serialize, so serializing the command
+		// again tells how many bytes it took up
+		name := strings.ToUpper(value.Array[0].Bulk)
+		if name == "MULTI" {
+			multiOffset = offset
+		}
+		offset += len(value.Serialize())
+		result, ok := Execute(client, name, value.Array[1:])
+		if !ok {
+			return replayed, fmt.Errorf("unknown command %q in append only file at offset %d", name, offset)
+		}
+		if result.DataType == resp.TypeError {
+			return replayed, fmt.Errorf("replaying %s failed: %s", name, result.Err)
+		}
+		replayed++
+	}
+
+	// The commands of a transaction whose EXEC never made it to the file
+	// were only queued, so the transaction is cut off as a whole
+	if client.inMulti {
+		if err := truncateAppendOnly(path, data, multiOffset); err != nil {
+			return replayed, err
+		}
+	}
+
+	// Replaying is not a change that needs saving
+	dirty.Store(0)
+	return replayed, nil
+}
+
+func truncateAppendOnly(path string, data []byte, offset int) error {
+	log.Printf("Append only file ends with a partial command; truncating %d bytes at offset %d", len(data)-offset, offset)
+	return os.Truncate(path, int64(offset))
+}
+
+func runAppendOnlyFsync() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		aofMu.Lock()
+		f := aofFile
+		aofMu.Unlock()
+		if err := f.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+			log.Println("Error syncing append only file:", err)
+		}
+	}
+}
+
+// propagate records a change made to db: it counts towards the save rules,
+// invalidates WATCH on the keys it wrote and is appended to the append-only
+// file. command is the command to log, name first.
+func propagate(db *Database, command []resp.Value) {
+	markDirty()
+	if keys, ok := writeCommands[command[0].Bulk]; ok {
+		touchKeys(db, keys(command[1:]))
+	}
+
+	aofMu.Lock()
+	defer aofMu.Unlock()
+	if db.id != aofSelectedDB {
+		appendCommandLocked(bulkValues("SELECT", strconv.Itoa(db.id)))
+		aofSelectedDB = db.id
+	}
+	if transactionDepth > 0 && !transactionOpen {
+		// The first change made by a transaction opens it in the log
+		transactionOpen = true
+		appendCommandLocked(bulkValues("MULTI"))
+	}
+	appendCommandLocked(command)
+}
+
+// beginPropagatedTransaction makes the commands propagated until the
+// matching endPropagatedTransaction appear between MULTI and EXEC in the
+// log, so a log cut off in the middle of a transaction replays none of it.
+// Calls may nest; only the outermost pair shows up in the log. The caller
+// holds the keyspace lock exclusively, so nothing else is propagated in
+// between.
+func beginPropagatedTransaction() {
+	aofMu.Lock()
+	transactionDepth++
+	aofMu.Unlock()
+}
+
+func endPropagatedTransaction() {
+	aofMu.Lock()
+	defer aofMu.Unlock()
+	transactionDepth--
+	if transactionDepth == 0 && transactionOpen {
+		appendCommandLocked(bulkValues("EXEC"))
+		transactionOpen = false
+	}
+}
+
+func appendCommandLocked(command []resp.Value) {
+	if aofFile == nil && !rewriting {
+		return
+	}
+	data := resp.Value{DataType: resp.TypeArray, Array: command}.Serialize()
+	if rewriting {
+		rewriteBuf = append(rewriteBuf, data...)
+	}
+	if aofFile == nil {
+		return
+	}
+
+	if _, err := aofFile.Write(data); err != nil {
+		// Keep serving; the next successful write logs the recovery
+		if !aofFailed {
+			log.Println("Error writing to append only file:", err)
+		}
+		aofFailed = true
+		return
+	}
+	if aofFailed {
+		log.Println("Append only file writes recovered")
+		aofFailed = false
+	}
+	if aofFsync == FsyncAlways {
+		if err := aofFile.Sync(); err != nil {
+			log.Println("Error syncing append only file:", err)
+		}
+	}
+}
+
+func bulkValues(args ...string) []resp.Value {
+	values := make([]resp.Value, len(args))
+	for i, arg := range args {
+		values[i] = resp.Value{DataType: resp.TypeBulk, Bulk: arg}
+	}
+	return values
+}
+
+// propagateCommand logs a write command run through Execute on db,
+// rewriting the ones that would not replay to the same result.
+func propagateCommand(db *Database, name string, args []resp.Value, result resp.Value) {
+	command := append([]resp.Value{{DataType: resp.TypeBulk, Bulk: name}}, args...)
+	if rewrite, ok := propagationRewrites[name]; ok {
+		command = rewrite(db, args, result)
+	}
+	if command != nil {
+		propagate(db, command)
+	}
+}
+
+// propagationRewrites maps a command to the command that should be logged
+// in its place, or nil if it changed nothing.
+var propagationRewrites = map[string]func(db *Database, args []resp.Value, result resp.Value) []resp.Value{
+	"SET":         rewriteSet,
+	"SETEX":       rewriteSetEx,
+	"PSETEX":      rewriteSetEx,
+	"INCRBYFLOAT": rewriteIncrByFloat,
+	"BITFIELD":    rewriteBitfield,
+	"GETEX":       rewriteExpiry,
+	"EXPIRE":      rewriteExpiry,
+	"PEXPIRE":     rewriteExpiry,
+	"EXPIREAT":    rewriteExpiry,
+	"PEXPIREAT":   rewriteExpiry,
+	"SPOP":        rewriteSPop,
+	"EVAL":        rewriteScript,
+	"EVALSHA":     rewriteScript,
+}
+
+// rewriteSet turns a relative EX or PX into PXAT. If the SET did not take
+// effect because of NX or XX, it will not on replay either.
+func rewriteSet(db *Database, args []resp.Value, result resp.Value) []resp.Value {
+	command := append(bulkValues("SET"), args...)
+	record, ok := db.peekKey(args[0].Bulk)
+	if !ok || record.ExpiryTime == nil {
+		return command
+	}
+	for i := 3; i < len(command); i++ {
+		switch strings.ToUpper(command[i].Bulk) {
+		case "EX", "PX":
+			command[i] = resp.Value{DataType: resp.TypeBulk, Bulk: "PXAT"}
+			command[i+1] = resp.Value{DataType: resp.TypeBulk, Bulk: strconv.FormatInt(record.ExpiryTime.UnixMilli(), 10)}
+			return command
+		}
+	}
+	return command
+}
+
+// rewriteSetEx logs SETEX and PSETEX as a SET with an absolute expiry.
+func rewriteSetEx(db *Database, args []resp.Value, result resp.Value) []resp.Value {
+	record, ok := db.peekKey(args[0].Bulk)
+	if !ok || record.ExpiryTime == nil {
+		return nil
+	}
+	return bulkValues("SET", args[0].Bulk, args[2].Bulk, "PXAT", strconv.FormatInt(record.ExpiryTime.UnixMilli(), 10))
+}
+
+// rewriteIncrByFloat logs the result of INCRBYFLOAT rather than the
+// increment, so replaying it cannot round differently.
+func rewriteIncrByFloat(db *Database, args []resp.Value, result resp.Value) []resp.Value {
+	return bulkValues("SET", args[0].Bulk, result.Bulk, "KEEPTTL")
+}
+
+// rewriteBitfield drops a BITFIELD that only read fields.
+func rewriteBitfield(db *Database, args []resp.Value, result resp.Value) []resp.Value {
+	for i := 1; i < len(args); {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "SET", "INCRBY":
+			return append(bulkValues("BITFIELD"), args...)
+		case "OVERFLOW":
+			i += 2
+		default:
+			i += 3
+		}
+	}
+	return nil
+}
+
+// rewriteExpiry logs whatever the command left behind: an absolute expiry,
+// its removal, or the deletion of a key whose new expiry had already
+// passed.
+func rewriteExpiry(db *Database, args []resp.Value, result resp.Value) []resp.Value {
+	// A GETEX without options or on a missing key, and an EXPIRE whose
+	// condition did not hold, change nothing
+	if len(args) == 1 || result.IsNull || (result.DataType == resp.TypeInteger && result.Num == 0) {
+		return nil
+	}
+
+	key := args[0].Bulk
+	record, ok := db.peekKey(key)
+	switch {
+	case !ok:
+		return bulkValues("DEL", key)
+	case record.ExpiryTime == nil:
+		return bulkValues("PERSIST", key)
+	default:
+		return bulkValues("PEXPIREAT", key, strconv.FormatInt(record.ExpiryTime.UnixMilli(), 10))
+	}
+}
+
+func rewriteSPop(db *Database, args []resp.Value, result resp.Value) []resp.Value {
+	command := bulkValues("SREM", args[0].Bulk)
+	switch result.DataType {
+	case resp.TypeBulk:
+		command = append(command, result)
+	case resp.TypeArray:
+		command = append(command, result.Array...)
+	}
+	if len(command) == 2 {
+		return nil
+	}
+	return command
+}
+
+// rewriteScript drops EVAL and EVALSHA from the log: the commands a script
+// calls are propagated on their own, so replaying them does not depend on
+// the script cache or on the script being deterministic.
+func rewriteScript(db *Database, args []resp.Value, result resp.Value) []resp.Value {
+	return nil
+}
+
+// propagateExpired logs the deletion of a key whose TTL has passed.
+func propagateExpired(db *Database, key string) {
+	propagate(db, bulkValues("DEL", key))
+}
+
+func handleBGRewriteAOF(args []resp.Value) resp.Value {
+	aofMu.Lock()
+	enabled := aofFile != nil
+	aofMu.Unlock()
+	if !enabled {
+		return resp.Value{DataType: resp.TypeError, Err: errAppendOnlyDisabled}
+	}
+	if !rewriteMu.TryLock() {
+		return resp.Value{DataType: resp.TypeError, Err: errRewriteInProgress}
+	}
+
+	// The commands propagated from the fork on are buffered for the tail of
+	// the rewritten file, so taking both at once leaves no gap between them
+	keyspaceMu.Lock()
+	fork := forkKeyspace()
+	aofMu.Lock()
+	// The rewritten file may end in another database than the live one
+	rewriting, rewriteBuf, aofSelectedDB = true, nil, -1
+	aofMu.Unlock()
+	keyspaceMu.Unlock()
+
+	go func() {
+		defer rewriteMu.Unlock()
+		if err := finishRewrite(encodeAppendOnly(fork)); err != nil {
+			log.Println("Error rewriting append only file:", err)
+			aofMu.Lock()
+			rewriting, rewriteBuf = false, nil
+			aofMu.Unlock()
+			return
+		}
+		log.Println("Background append only file rewriting terminated with success")
+	}()
+	return resp.Value{DataType: resp.TypeString, Str: rewriteStarted}
+}
+
+// finishRewrite writes the compacted log to a temporary file, then appends
+// the commands propagated since the rewrite started and renames it over
+// the live file, which it replaces for all further appends.
+func finishRewrite(data []byte) (err error) {
+	aofMu.Lock()
+	dir := filepath.Dir(aofPath)
+	aofMu.Unlock()
+
+	f, err := os.CreateTemp(dir, "temp-rewriteaof-*.aof")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	aofMu.Lock()
+	defer aofMu.Unlock()
+	buffered := rewriteBuf
+	rewriting, rewriteBuf = false, nil
+	if _, err := f.Write(buffered); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), aofPath); err != nil {
+		return err
+	}
+
+	aofFile.Close()
+	aofFile = f
+	return nil
+}
+
+// encodeAppendOnly renders the keyspace as of fork as the shortest sequence
+// of commands that rebuilds it, selecting each database that holds keys in
+// turn, and walks the fork.
+func encodeAppendOnly(fork *keyspaceFork) []byte {
+	var buf []byte
+	emit := func(args ...string) {
+		buf = append(buf, resp.Value{DataType: resp.TypeArray, Array: bulkValues(args...)}.Serialize()...)
+	}
+	// emitBatched spreads items over as many commands as needed
+	emitBatched := func(command, key string, items []string, perItem int) {
+		for len(items) > 0 {
+			n := min(len(items), aofRewriteItemsPerCommand*perItem)
+			emit(append([]string{command, key}, items[:n]...)...)
+			items = items[n:]
+		}
+	}
+
+	selected := -1
+	fork.walk(func(db int, key string, record Record) {
+		if db != selected {
+			emit("SELECT", strconv.Itoa(db))
+			selected = db
+		}
+
+		switch record.Type {
+		case TypeString:
+			emit("SET", key, stringValue(record))
+		case TypeList:
+			list := record.Value.(*deque)
+			items := make([]string, list.len())
+			for i := range items {
+				items[i] = list.at(i)
+			}
+			emitBatched("RPUSH", key, items, 1)
+		case TypeSet:
+			emitBatched("SADD", key, setToSlice(record.Value.(*stringSet)), 1)
+		case TypeZSet:
+			zset := record.Value.(*sortedSet)
+			items := make([]string, 0, 2*zset.len())
+			for x := zset.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+				items = append(items, resp.FormatDouble(x.score), x.member)
+			}
+			emitBatched("ZADD", key, items, 2)
+		case TypeHash:
+			hash := record.Value.(*dict[string])
+			items := make([]string, 0, 2*hash.len())
+			for i, field := range hash.keys {
+				items = append(items, field, hash.values[i])
+			}
+			emitBatched("HSET", key, items, 2)
+		}
+
+		if record.ExpiryTime != nil {
+			emit("PEXPIREAT", key, strconv.FormatInt(record.ExpiryTime.UnixMilli(), 10))
+		}
+	})
+	return buf
+}
@@ -0,0 +1,330 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestAppendOnly starts logging to a fresh append-only file and stops
+// again when the test ends.
+func openTestAppendOnly(t *testing.T) string {
+	t.Helper()
+	flushKeyspace()
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	if _, err := LoadAppendOnly(path, FsyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(closeTestAppendOnly)
+	return path
+}
+
+func closeTestAppendOnly() {
+	aofMu.Lock()
+	defer aofMu.Unlock()
+	if aofFile != nil {
+		aofFile.Close()
+		aofFile = nil
+	}
+}
+
+// reload replays path into an empty keyspace.
+func reload(t *testing.T, path string) {
+	t.Helper()
+	closeTestAppendOnly()
+	flushKeyspace()
+	if _, err := replayAppendOnly(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendOnlyReplaysToTheSameState(t *testing.T) {
+	db := databases[0]
+	path := openTestAppendOnly(t)
+	client := NewClient()
+
+	Execute(client, "SET", bulkValues("str", "v", "EX", "100"))
+	Execute(client, "SET", bulkValues("str", "other", "NX", "EX", "5"))
+	Execute(client, "SADD", bulkValues("set", "a", "b", "c"))
+	popped, _ := Execute(client, "SPOP", bulkValues("set"))
+	Execute(client, "EXPIRE", bulkValues("set", "50"))
+	Execute(client, "SET", bulkValues("gone", "x"))
+	Execute(client, "EXPIRE", bulkValues("gone", "-1"))
+
+	// A pop served to a blocked client must be logged after the push
+	done := make(chan resp.Value)
+	go func() {
+		reply, _ := Execute(NewClient(), "BLPOP", bulkValues("queue", "0"))
+		done <- reply
+	}()
+	waitForBlocked(t, "queue", 1)
+	Execute(client, "RPUSH", bulkValues("queue", "job", "next"))
+	<-done
+
+	before := map[string]Record{}
+	for _, key := range []string{"str", "set", "queue"} {
+		before[key], _ = db.lookupKey(key)
+	}
+
+	reload(t, path)
+
+	if record, ok := db.lookupKey("str"); !ok || record.Value != "v" ||
+		record.ExpiryTime.UnixMilli() != before["str"].ExpiryTime.UnixMilli() {
+		t.Fatalf("str was not restored with its absolute expiry: %+v", record)
+	}
+	if reply := handleSIsMember(db, bulkValues("set", popped.Bulk)); reply.Num != 0 {
+		t.Fatalf("the member popped by SPOP came back")
+	}
+	if reply := handleSCard(db, bulkValues("set")); reply.Num != 2 {
+		t.Fatalf("expected 2 members left, got %d", reply.Num)
+	}
+	if record, _ := db.lookupKey("set"); record.ExpiryTime.UnixMilli() != before["set"].ExpiryTime.UnixMilli() {
+		t.Fatal("the relative EXPIRE was not logged as an absolute one")
+	}
+	if _, ok := db.lookupKey("gone"); ok {
+		t.Fatal("a key deleted by EXPIRE in the past came back")
+	}
+	if reply := handleLRange(db, bulkValues("queue", "0", "-1")); len(reply.Array) != 1 || reply.Array[0].Bulk != "next" {
+		t.Fatalf("expected the queue to hold [next], got %+v", reply)
+	}
+}
+
+func TestAppendOnlyTruncatesPartialTail(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	complete := resp.Value{DataType: resp.TypeArray, Array: bulkValues("SET", "k", "v")}.Serialize()
+	partial := []byte("*3\r\n$3\r\nSET\r\n$1\r\nx")
+	if err := os.WriteFile(path, append(complete, partial...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := LoadAppendOnly(path, FsyncNo)
+	t.Cleanup(closeTestAppendOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 1 {
+		t.Fatalf("expected 1 command to be replayed, got %d", replayed)
+	}
+	if info, _ := os.Stat(path); info.Size() != int64(len(complete)) {
+		t.Fatalf("expected the file to be truncated to %d bytes, got %d", len(complete), info.Size())
+	}
+
+	// New commands go after the last complete one
+	Execute(NewClient(), "SET", bulkValues("k", "w"))
+	reload(t, path)
+	if value, _, _ := db.loadString("k"); value != "w" {
+		t.Fatalf("expected w, got %q", value)
+	}
+}
+
+func TestRewriteAppendOnlyCompactsTheLog(t *testing.T) {
+	db := databases[0]
+	path := openTestAppendOnly(t)
+	client := NewClient()
+
+	for i := 0; i < 100; i++ {
+		Execute(client, "INCR", bulkValues("counter"))
+		Execute(client, "RPUSH", bulkValues("list", "x"))
+	}
+	Execute(client, "ZADD", bulkValues("zset", "-inf", "low", "0.1", "tenth"))
+	Execute(client, "HSET", bulkValues("hash", "f", "v"))
+	Execute(client, "PEXPIRE", bulkValues("hash", "100000"))
+	before, _ := os.Stat(path)
+
+	if reply := handleBGRewriteAOF(nil); reply.Str != rewriteStarted {
+		t.Fatalf("BGREWRITEAOF failed: %+v", reply)
+	}
+	Execute(client, "RPUSH", bulkValues("list", "after"))
+	rewriteMu.Lock()
+	rewriteMu.Unlock()
+	Execute(client, "RPUSH", bulkValues("list", "later"))
+
+	if after, _ := os.Stat(path); after.Size() >= before.Size() {
+		t.Fatalf("rewrite did not shrink the log: %d >= %d bytes", after.Size(), before.Size())
+	}
+	hashTTL, _ := db.lookupKey("hash")
+
+	reload(t, path)
+	if value, _, _ := db.loadString("counter"); value != "100" {
+		t.Fatalf("expected counter 100, got %q", value)
+	}
+	if reply := handleLLen(db, bulkValues("list")); reply.Num != 102 {
+		t.Fatalf("expected 102 elements, got %d", reply.Num)
+	}
+	if reply := handleZScore(db, bulkValues("zset", "tenth")); reply.Double != 0.1 {
+		t.Fatalf("expected score 0.1, got %+v", reply)
+	}
+	if record, _ := db.lookupKey("hash"); record.ExpiryTime == nil ||
+		record.ExpiryTime.UnixMilli() != hashTTL.ExpiryTime.UnixMilli() {
+		t.Fatal("hash expiry was not kept by the rewrite")
+	}
+}
+
+func TestRewriteAppendOnlyWhileWriting(t *testing.T) {
+	db := databases[0]
+	path := openTestAppendOnly(t)
+	client := NewClient()
+	for i := 0; i < 200; i++ {
+		Execute(client, "RPUSH", bulkValues("list:"+strconv.Itoa(i), "a"))
+	}
+
+	if reply := handleBGRewriteAOF(nil); reply.Str != rewriteStarted {
+		t.Fatalf("BGREWRITEAOF failed: %+v", reply)
+	}
+	// Each write lands either in the rewritten keyspace or in the tail
+	// buffered for it, never in both
+	for i := 199; i >= 0; i-- {
+		Execute(client, "RPUSH", bulkValues("list:"+strconv.Itoa(i), "b"))
+		Execute(client, "INCR", bulkValues("counter"))
+	}
+	rewriteMu.Lock()
+	rewriteMu.Unlock()
+
+	reload(t, path)
+	for i := 0; i < 200; i++ {
+		if reply := handleLRange(db, bulkValues("list:"+strconv.Itoa(i), "0", "-1")); len(reply.Array) != 2 {
+			t.Fatalf("expected list:%d to hold [a b], got %+v", i, reply.Array)
+		}
+	}
+	if value, _, _ := db.loadString("counter"); value != "200" {
+		t.Fatalf("expected counter 200, got %q", value)
+	}
+}
+
+func TestAppendOnlyReplaysDatabases(t *testing.T) {
+	path := openTestAppendOnly(t)
+	client := NewClient()
+
+	Execute(client, "SET", bulkValues("key", "0"))
+	Execute(client, "SELECT", bulkValues("1"))
+	Execute(client, "SET", bulkValues("key", "1"))
+	Execute(client, "RPUSH", bulkValues("moved", "x"))
+	Execute(client, "MOVE", bulkValues("moved", "2"))
+	Execute(client, "MULTI", nil)
+	Execute(client, "SELECT", bulkValues("3"))
+	Execute(client, "SET", bulkValues("key", "3"))
+	Execute(client, "EXEC", nil)
+	Execute(client, "SWAPDB", bulkValues("0", "3"))
+
+	if reply := handleBGRewriteAOF(nil); reply.Str != rewriteStarted {
+		t.Fatalf("BGREWRITEAOF failed: %+v", reply)
+	}
+	rewriteMu.Lock()
+	rewriteMu.Unlock()
+	// Logged after the rewrite, which leaves the file in another database
+	Execute(client, "SET", bulkValues("after", "3"))
+
+	reload(t, path)
+	for _, check := range []struct {
+		db    int
+		key   string
+		value string
+	}{
+		{0, "key", "3"},
+		{1, "key", "1"},
+		{3, "key", "0"},
+		{3, "after", "3"},
+	} {
+		if value, _, _ := databases[check.db].loadString(check.key); value != check.value {
+			t.Errorf("expected %s in database %d to be %q, got %q", check.key, check.db, check.value, value)
+		}
+	}
+	if list, _ := databases[2].loadList("moved"); list == nil || list.len() != 1 {
+		t.Error("the moved list was not restored in database 2")
+	}
+}
+
+func TestPropagatingAKeyThatJustExpired(t *testing.T) {
+	path := openTestAppendOnly(t)
+	db := databases[0]
+	handleSet(db, bulkValues("pexpire", "v"))
+
+	// The key expires between the command and its propagation
+	for _, command := range [][]string{
+		{"PSETEX", "psetex", "1", "v"},
+		{"SET", "set", "v", "PX", "1"},
+		{"PEXPIRE", "pexpire", "1"},
+	} {
+		name, args := command[0], bulkValues(command[1:]...)
+		result := CommandHandler[name](db, args)
+		past := time.Now().Add(-time.Second)
+		record, _ := db.peekKey(args[0].Bulk)
+		record.ExpiryTime = &past
+		db.storeRecord(args[0].Bulk, record)
+		propagateCommand(db, name, args, result)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "DEL") {
+		t.Fatalf("expected no DEL to be logged, got %q", data)
+	}
+	if strings.Count(string(data), "PXAT") != 2 || strings.Count(string(data), "PEXPIREAT") != 1 {
+		t.Fatalf("expected absolute expiries to be logged, got %q", data)
+	}
+}
+
+func TestWritesThatChangeNothingAreNotPropagated(t *testing.T) {
+	path := openTestAppendOnly(t)
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "SET", bulkValues("str", "v"))
+	Execute(client, "SADD", bulkValues("set", "a"))
+	Execute(client, "RPUSH", bulkValues("list", "a", "b"))
+	Execute(client, "ZADD", bulkValues("zset", "1", "a"))
+	Execute(client, "HSET", bulkValues("hash", "f", "v"))
+
+	watcher := NewClient()
+	defer watcher.Close()
+	Execute(watcher, "WATCH", bulkValues("str", "set", "list", "zset", "hash"))
+	before := dirty.Load()
+	for _, command := range [][]string{
+		{"SET", "str", "other", "NX"},
+		{"DEL", "missing"},
+		{"SADD", "set", "a"},
+		{"SREM", "set", "b"},
+		{"SMOVE", "set", "other", "b"},
+		{"LREM", "list", "0", "c"},
+		{"LTRIM", "list", "0", "-1"},
+		{"ZADD", "zset", "1", "a"},
+		{"ZREM", "zset", "b"},
+		{"HDEL", "hash", "g"},
+		{"HSETNX", "hash", "f", "w"},
+		{"EXPIRE", "missing", "10"},
+		{"PERSIST", "str"},
+	} {
+		if reply, _ := Execute(client, command[0], bulkValues(command[1:]...)); reply.DataType == resp.TypeError {
+			t.Fatalf("%v: %s", command, reply.Err)
+		}
+	}
+	if changes := dirty.Load() - before; changes != 0 {
+		t.Errorf("expected no changes to be counted, got %d", changes)
+	}
+
+	Execute(watcher, "MULTI", nil)
+	Execute(watcher, "GET", bulkValues("str"))
+	if reply, _ := Execute(watcher, "EXEC", nil); reply.IsNull {
+		t.Error("a write that changed nothing aborted a transaction watching its key")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"DEL", "SREM", "SMOVE", "LREM", "LTRIM", "ZREM", "HDEL", "HSETNX", "EXPIRE", "PERSIST"} {
+		if strings.Contains(string(data), name) {
+			t.Errorf("expected %s to change nothing and not be logged, got %q", name, data)
+		}
+	}
+	if strings.Count(string(data), "SADD") != 1 || strings.Count(string(data), "ZADD") != 1 || strings.Count(string(data), "\r\nSET\r\n") != 1 {
+		t.Errorf("expected only the writes that changed something to be logged, got %q", data)
+	}
+}
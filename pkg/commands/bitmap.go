@@ -0,0 +1,607 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const (
+	errBitOffset    = "ERR bit offset is not an integer or out of range"
+	errBitValue     = "ERR bit is not an integer or out of range"
+	errBitPosValue  = "ERR The bit argument must be 1 or 0."
+	errBitOpNot     = "ERR BITOP NOT must be called with a single source key."
+	errBitfieldType = "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."
+	errOverflowType = "ERR Invalid OVERFLOW type specified"
+	errBitfieldRO   = "ERR BITFIELD_RO only supports the GET subcommand"
+)
+
+// Bitmaps are strings addressed bit by bit: bit 0 is the most significant
+// bit of the first byte. The commands writing them store the string as a
+// []byte, which later writes grow and modify in place, so setting a bit
+// costs the same however long the string is.
+
+// parseBitOffset parses the offset of a bit, which must fall within the
+// longest string allowed.
+func parseBitOffset(s string) (int64, bool) {
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || !validBitOffset(offset) {
+		return 0, false
+	}
+	return offset, true
+}
+
+func validBitOffset(offset int64) bool {
+	return offset >= 0 && offset>>3 < int64(resp.MaxBulkLen())
+}
+
+// loadBitmap returns the string stored at key as a bitmap to modify, along
+// with its record so it can be stored back with its TTL. Only a string that
+// is not stored as a bitmap yet is copied.
+func (db *Database) loadBitmap(key string) (bitmap []byte, record Record, wrongType bool) {
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return nil, record, false
+	}
+	if record.Type != TypeString {
+		return nil, record, true
+	}
+	if bitmap, ok := record.Value.([]byte); ok {
+		return bitmap, record, false
+	}
+	return []byte(record.Value.(string)), record, false
+}
+
+// storeBitmap stores bitmap at key, keeping the TTL of the string it
+// replaces. record is the record loadBitmap returned for key.
+func (db *Database) storeBitmap(key string, record Record, bitmap []byte) {
+	db.storeRecord(key, Record{Type: TypeString, Value: bitmap, ExpiryTime: record.ExpiryTime})
+}
+
+// bitmapView reads a string however it is stored, without copying it.
+type bitmapView struct {
+	str     string
+	bytes   []byte
+	isBytes bool
+}
+
+func (v bitmapView) len() int {
+	if v.isBytes {
+		return len(v.bytes)
+	}
+	return len(v.str)
+}
+
+func (v bitmapView) at(i int64) byte {
+	if v.isBytes {
+		return v.bytes[i]
+	}
+	return v.str[i]
+}
+
+// slice returns the bytes from start up to end as a string, copying only
+// those.
+func (v bitmapView) slice(start, end int) string {
+	if v.isBytes {
+		return string(v.bytes[start:end])
+	}
+	return v.str[start:end]
+}
+
+func bytesView(bitmap []byte) bitmapView {
+	return bitmapView{bytes: bitmap, isBytes: true}
+}
+
+// loadBitmapView returns the string stored at key for reading. exists is
+// false for a missing key, while a key holding another type reports
+// wrongType.
+func (db *Database) loadBitmapView(key string) (view bitmapView, exists bool, wrongType bool) {
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return bitmapView{}, false, false
+	}
+	if record.Type != TypeString {
+		return bitmapView{}, false, true
+	}
+	if bitmap, ok := record.Value.([]byte); ok {
+		return bytesView(bitmap), true, false
+	}
+	return bitmapView{str: record.Value.(string)}, true, false
+}
+
+func getBit(bitmap bitmapView, offset int64) int {
+	i := offset >> 3
+	if i >= int64(bitmap.len()) {
+		return 0
+	}
+	return int(bitmap.at(i)>>(7-offset&7)) & 1
+}
+
+// setBit sets a bit of bitmap, which must be long enough to hold it.
+func setBit(bitmap []byte, offset int64, bit int) {
+	mask := byte(1) << (7 - offset&7)
+	if bit == 1 {
+		bitmap[offset>>3] |= mask
+	} else {
+		bitmap[offset>>3] &^= mask
+	}
+}
+
+// growBitmap pads bitmap with zero bytes until it holds n bytes.
+func growBitmap(bitmap []byte, n int64) []byte {
+	if n <= int64(len(bitmap)) {
+		return bitmap
+	}
+	return append(bitmap, make([]byte, n-int64(len(bitmap)))...)
+}
+
+// handleSetBit sets or clears a bit, growing the string as needed, and
+// returns the bit's previous value.
+func handleSetBit(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	offset, ok := parseBitOffset(args[1].Bulk)
+	if !ok {
+		return resp.Value{DataType: resp.TypeError, Err: errBitOffset}
+	}
+	bit, err := strconv.Atoi(args[2].Bulk)
+	if err != nil || (bit != 0 && bit != 1) {
+		return resp.Value{DataType: resp.TypeError, Err: errBitValue}
+	}
+
+	bitmap, record, wrongType := db.loadBitmap(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	bitmap = growBitmap(bitmap, offset>>3+1)
+	previous := getBit(bytesView(bitmap), offset)
+	setBit(bitmap, offset, bit)
+	db.storeBitmap(key, record, bitmap)
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(previous)}
+}
+
+func handleGetBit(db *Database, args []resp.Value) resp.Value {
+	offset, ok := parseBitOffset(args[1].Bulk)
+	if !ok {
+		return resp.Value{DataType: resp.TypeError, Err: errBitOffset}
+	}
+	bitmap, _, wrongType := db.loadBitmapView(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(getBit(bitmap, offset))}
+}
+
+// bitRange is a range of bits resolved from the "start end [BYTE|BIT]"
+// arguments of BITCOUNT and BITPOS.
+type bitRange struct {
+	first, last int64
+	empty       bool
+}
+
+// parseBitRange parses "start end [BYTE|BIT]" against a string of n bytes.
+// Offsets count from the end when negative and are clamped to the string,
+// in bytes unless BIT is given. A missing end is the end of the string.
+func parseBitRange(args []resp.Value, n int) (bitRange, string) {
+	start, err := strconv.ParseInt(args[0].Bulk, 10, 64)
+	if err != nil {
+		return bitRange{}, errNotInteger
+	}
+	end := int64(-1)
+	if len(args) > 1 {
+		if end, err = strconv.ParseInt(args[1].Bulk, 10, 64); err != nil {
+			return bitRange{}, errNotInteger
+		}
+	}
+	inBits := false
+	if len(args) > 2 {
+		switch strings.ToUpper(args[2].Bulk) {
+		case "BYTE":
+		case "BIT":
+			inBits = true
+		default:
+			return bitRange{}, errSyntax
+		}
+	}
+	if len(args) > 3 {
+		return bitRange{}, errSyntax
+	}
+
+	total := int64(n)
+	if inBits {
+		total *= 8
+	}
+	if start < 0 && end < 0 && start > end {
+		return bitRange{empty: true}, ""
+	}
+	if start < 0 {
+		start = max(total+start, 0)
+	}
+	if end < 0 {
+		end = max(total+end, 0)
+	}
+	end = min(end, total-1)
+	if start > end {
+		return bitRange{empty: true}, ""
+	}
+	if !inBits {
+		start, end = start*8, end*8+7
+	}
+	return bitRange{first: start, last: end}, ""
+}
+
+// handleBitCount counts the set bits of a string, or of part of it.
+func handleBitCount(db *Database, args []resp.Value) resp.Value {
+	if len(args) == 2 {
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+	bitmap, _, wrongType := db.loadBitmapView(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	r := bitRange{first: 0, last: int64(bitmap.len())*8 - 1, empty: bitmap.len() == 0}
+	if len(args) > 1 {
+		var errMsg string
+		if r, errMsg = parseBitRange(args[1:], bitmap.len()); errMsg != "" {
+			return resp.Value{DataType: resp.TypeError, Err: errMsg}
+		}
+	}
+	if r.empty {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+
+	count := 0
+	for i := r.first >> 3; i <= r.last>>3; i++ {
+		b := bitmap.at(i)
+		if i == r.first>>3 {
+			b &= 0xFF >> (r.first & 7)
+		}
+		if i == r.last>>3 {
+			b &= 0xFF << (7 - r.last&7)
+		}
+		count += bits.OnesCount8(b)
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(count)}
+}
+
+// handleBitPos returns the position of the first bit set to 0 or 1. When
+// looking for a 0 without an end, the string counts as padded with zeros,
+// so a string of all ones yields the first bit past it.
+func handleBitPos(db *Database, args []resp.Value) resp.Value {
+	bit, err := strconv.Atoi(args[1].Bulk)
+	if err != nil || (bit != 0 && bit != 1) {
+		return resp.Value{DataType: resp.TypeError, Err: errBitPosValue}
+	}
+	bitmap, exists, wrongType := db.loadBitmapView(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if !exists {
+		return resp.Value{DataType: resp.TypeInteger, Num: int64(-bit)}
+	}
+
+	r := bitRange{first: 0, last: int64(bitmap.len())*8 - 1, empty: bitmap.len() == 0}
+	if len(args) > 2 {
+		var errMsg string
+		if r, errMsg = parseBitRange(args[2:], bitmap.len()); errMsg != "" {
+			return resp.Value{DataType: resp.TypeError, Err: errMsg}
+		}
+	}
+	if r.empty {
+		return resp.Value{DataType: resp.TypeInteger, Num: -1}
+	}
+
+	for offset := r.first; offset <= r.last; offset++ {
+		// Skip whole bytes that cannot hold the bit
+		if offset&7 == 0 && offset+7 <= r.last && bitmap.at(offset>>3) == byte(0xFF*(1-bit)) {
+			offset += 7
+			continue
+		}
+		if getBit(bitmap, offset) == bit {
+			return resp.Value{DataType: resp.TypeInteger, Num: offset}
+		}
+	}
+	if bit == 0 && len(args) < 4 {
+		return resp.Value{DataType: resp.TypeInteger, Num: r.last + 1}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: -1}
+}
+
+// handleBitOp stores the bitwise AND, OR or XOR of strings, or the NOT of
+// one, at destination. Shorter strings count as padded with zero bytes. An
+// empty result deletes destination. It returns the result's length.
+func handleBitOp(db *Database, args []resp.Value) resp.Value {
+	op := strings.ToUpper(args[0].Bulk)
+	destination, keys := args[1].Bulk, args[2:]
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(keys) != 1 {
+			return resp.Value{DataType: resp.TypeError, Err: errBitOpNot}
+		}
+	default:
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+
+	sources := make([]string, len(keys))
+	length := 0
+	for i, key := range keys {
+		value, _, wrongType := db.loadString(key.Bulk)
+		if wrongType {
+			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+		}
+		sources[i] = value
+		length = max(length, len(value))
+	}
+
+	result := make([]byte, length)
+	for i := range result {
+		var b byte
+		for j, source := range sources {
+			var s byte
+			if i < len(source) {
+				s = source[i]
+			}
+			switch {
+			case j == 0 || op == "NOT":
+				b = s
+			case op == "AND":
+				b &= s
+			case op == "OR":
+				b |= s
+			case op == "XOR":
+				b ^= s
+			}
+		}
+		if op == "NOT" {
+			b = ^b
+		}
+		result[i] = b
+	}
+
+	if length == 0 {
+		db.deleteKey(destination)
+	} else {
+		db.storeRecord(destination, Record{Type: TypeString, Value: result})
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(length)}
+}
+
+// bitfieldType is an integer type of BITFIELD, such as i16 or u8.
+type bitfieldType struct {
+	signed bool
+	bits   uint
+}
+
+func parseBitfieldType(s string) (bitfieldType, bool) {
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'I' && s[0] != 'u' && s[0] != 'U') {
+		return bitfieldType{}, false
+	}
+	t := bitfieldType{signed: s[0] == 'i' || s[0] == 'I'}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n < 1 || (t.signed && n > 64) || (!t.signed && n > 63) {
+		return bitfieldType{}, false
+	}
+	t.bits = uint(n)
+	return t, true
+}
+
+// parseBitfieldOffset parses the offset of a field, in bits or, prefixed
+// with #, in multiples of the field's width.
+func parseBitfieldOffset(s string, t bitfieldType) (int64, bool) {
+	if strings.HasPrefix(s, "#") {
+		n, err := strconv.ParseInt(s[1:], 10, 64)
+		if err != nil || n < 0 || n > int64(resp.MaxBulkLen())*8/int64(t.bits) || !validBitOffset(n*int64(t.bits)) {
+			return 0, false
+		}
+		return n * int64(t.bits), true
+	}
+	return parseBitOffset(s)
+}
+
+// get reads the field at offset, sign extending it for a signed type.
+func (t bitfieldType) get(bitmap bitmapView, offset int64) int64 {
+	var value uint64
+	for i := int64(0); i < int64(t.bits); i++ {
+		value = value<<1 | uint64(getBit(bitmap, offset+i))
+	}
+	if t.signed && t.bits < 64 && value&(1<<(t.bits-1)) != 0 {
+		value |= ^uint64(0) << t.bits
+	}
+	return int64(value)
+}
+
+// set writes the low bits of value to the field at offset.
+func (t bitfieldType) set(bitmap []byte, offset int64, value int64) {
+	for i := int64(0); i < int64(t.bits); i++ {
+		setBit(bitmap, offset+i, int(uint64(value)>>(int64(t.bits)-1-i))&1)
+	}
+}
+
+// The ways BITFIELD handles a value that does not fit its field.
+const (
+	overflowWrap = iota
+	overflowSat
+	overflowFail
+)
+
+// add adds increment to value, a value of the type, reporting whether the
+// result fits the type. A result that does not is wrapped around or
+// saturated as overflow says; with overflowFail, ok is false.
+func (t bitfieldType) add(value, increment int64, overflow int) (result int64, ok bool) {
+	if t.signed {
+		maxValue := int64(uint64(1)<<(t.bits-1) - 1)
+		minValue := -maxValue - 1
+		sum := value + increment
+		tooHigh := (increment > 0 && (sum < value || sum > maxValue)) || (increment == 0 && value > maxValue)
+		tooLow := (increment < 0 && (sum > value || sum < minValue)) || (increment == 0 && value < minValue)
+		switch {
+		case !tooHigh && !tooLow:
+			return sum, true
+		case overflow == overflowSat && tooHigh:
+			return maxValue, true
+		case overflow == overflowSat:
+			return minValue, true
+		case overflow == overflowWrap:
+			wrapped := uint64(sum)
+			if t.bits < 64 {
+				mask := ^uint64(0) << t.bits
+				if wrapped&(1<<(t.bits-1)) != 0 {
+					wrapped |= mask
+				} else {
+					wrapped &^= mask
+				}
+			}
+			return int64(wrapped), true
+		}
+		return 0, false
+	}
+
+	maxValue := uint64(1)<<t.bits - 1
+	current := uint64(value)
+	sum := current + uint64(increment)
+	tooHigh := current > maxValue || (increment > 0 && uint64(increment) > maxValue-current)
+	tooLow := !tooHigh && increment < 0 && uint64(-increment) > current
+	switch {
+	case !tooHigh && !tooLow:
+		return int64(sum), true
+	case overflow == overflowSat && tooHigh:
+		return int64(maxValue), true
+	case overflow == overflowSat:
+		return 0, true
+	case overflow == overflowWrap:
+		return int64(sum & maxValue), true
+	}
+	return 0, false
+}
+
+// bitfieldOp is one GET, SET or INCRBY of a BITFIELD command.
+type bitfieldOp struct {
+	name     string
+	t        bitfieldType
+	offset   int64
+	value    int64
+	overflow int
+}
+
+func handleBitfield(db *Database, args []resp.Value) resp.Value {
+	return bitfieldCommand(db, args, false)
+}
+
+func handleBitfieldRO(db *Database, args []resp.Value) resp.Value {
+	return bitfieldCommand(db, args, true)
+}
+
+// bitfieldCommand backs BITFIELD and BITFIELD_RO, which treat a string as
+// an array of integers of arbitrary widths and offsets. Each operation
+// replies with the field's value: the old one for SET, the new one for
+// INCRBY, or nil if OVERFLOW FAIL kept it from being written. OVERFLOW
+// applies to the operations that follow it.
+func bitfieldCommand(db *Database, args []resp.Value, readOnly bool) resp.Value {
+	var ops []bitfieldOp
+	overflow := overflowWrap
+	writes := false
+	var bytesNeeded int64
+	for i := 1; i < len(args); i++ {
+		name := strings.ToUpper(args[i].Bulk)
+		if readOnly && name != "GET" {
+			return resp.Value{DataType: resp.TypeError, Err: errBitfieldRO}
+		}
+
+		operands := 2
+		switch name {
+		case "GET":
+		case "SET", "INCRBY":
+			operands = 3
+		case "OVERFLOW":
+			if i+1 >= len(args) {
+				return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+			}
+			switch strings.ToUpper(args[i+1].Bulk) {
+			case "WRAP":
+				overflow = overflowWrap
+			case "SAT":
+				overflow = overflowSat
+			case "FAIL":
+				overflow = overflowFail
+			default:
+				return resp.Value{DataType: resp.TypeError, Err: errOverflowType}
+			}
+			i++
+			continue
+		default:
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+		if i+operands >= len(args) {
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+
+		op := bitfieldOp{name: name, overflow: overflow}
+		var ok bool
+		if op.t, ok = parseBitfieldType(args[i+1].Bulk); !ok {
+			return resp.Value{DataType: resp.TypeError, Err: errBitfieldType}
+		}
+		if op.offset, ok = parseBitfieldOffset(args[i+2].Bulk, op.t); !ok {
+			return resp.Value{DataType: resp.TypeError, Err: errBitOffset}
+		}
+		if operands == 3 {
+			value, err := strconv.ParseInt(args[i+3].Bulk, 10, 64)
+			if err != nil {
+				return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+			}
+			op.value = value
+			writes = true
+			bytesNeeded = max(bytesNeeded, (op.offset+int64(op.t.bits)-1)>>3+1)
+		}
+		ops = append(ops, op)
+		i += operands
+	}
+
+	// Only a BITFIELD that writes gets the string as a bitmap to modify
+	key := args[0].Bulk
+	var bitmap []byte
+	var record Record
+	var view bitmapView
+	var wrongType bool
+	if writes {
+		bitmap, record, wrongType = db.loadBitmap(key)
+	} else {
+		view, _, wrongType = db.loadBitmapView(key)
+	}
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if writes {
+		bitmap = growBitmap(bitmap, bytesNeeded)
+		view = bytesView(bitmap)
+	}
+
+	result := make([]resp.Value, len(ops))
+	for i, op := range ops {
+		current := op.t.get(view, op.offset)
+		reply := current
+		var value int64
+		ok := true
+		switch op.name {
+		case "SET":
+			value, ok = op.t.add(op.value, 0, op.overflow)
+		case "INCRBY":
+			value, ok = op.t.add(current, op.value, op.overflow)
+			reply = value
+		}
+		if !ok {
+			result[i] = resp.Value{DataType: resp.TypeNull, IsNull: true}
+			continue
+		}
+		if op.name != "GET" {
+			op.t.set(bitmap, op.offset, value)
+		}
+		result[i] = resp.Value{DataType: resp.TypeInteger, Num: reply}
+	}
+
+	if writes {
+		db.storeBitmap(key, record, bitmap)
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"testing"
+)
+
+func TestSetBitModifiesInPlace(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("key", "\x00\x00"))
+
+	handleSetBit(db, bulkValues("key", "0", "1"))
+	record, _ := db.lookupKey("key")
+	bitmap := record.Value.([]byte)
+	handleSetBit(db, bulkValues("key", "15", "1"))
+	record, _ = db.lookupKey("key")
+	if &record.Value.([]byte)[0] != &bitmap[0] {
+		t.Fatal("SETBIT copied the bitmap")
+	}
+
+	// Everything reading strings sees a bitmap as the string it holds
+	handleCopy(db, bulkValues("key", "copy"))
+	handleSetBit(db, bulkValues("key", "1", "1"))
+	for _, check := range []struct {
+		reply    resp.Value
+		expected string
+	}{
+		{handleGet(db, bulkValues("key")), "\xc0\x01"},
+		{handleGet(db, bulkValues("copy")), "\x80\x01"},
+		{handleGetRange(db, bulkValues("key", "1", "1")), "\x01"},
+	} {
+		if check.reply.Bulk != check.expected {
+			t.Errorf("expected %q, got %+v", check.expected, check.reply)
+		}
+	}
+	if reply := handleStrLen(db, bulkValues("key")); reply.Num != 2 {
+		t.Fatalf("expected 2, got %+v", reply)
+	}
+}
+
+func TestSetBitAndGetBit(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("ttl", "", "EX", "100"))
+
+	if reply := handleSetBit(db, bulkValues("key", "7", "1")); reply.Num != 0 {
+		t.Fatalf("expected 0, got %+v", reply)
+	}
+	if reply := handleSetBit(db, bulkValues("key", "7", "0")); reply.Num != 1 {
+		t.Fatalf("expected the previous bit 1, got %+v", reply)
+	}
+	handleSetBit(db, bulkValues("key", "0", "1"))
+	handleSetBit(db, bulkValues("key", "17", "1"))
+	if value, _, _ := db.loadString("key"); value != "\x80\x00\x40" {
+		t.Fatalf("expected the string to grow with zero bytes, got %q", value)
+	}
+	for offset, expected := range map[string]int64{"0": 1, "7": 0, "17": 1, "1000": 0} {
+		if reply := handleGetBit(db, bulkValues("key", offset)); reply.Num != expected {
+			t.Errorf("expected bit %s to be %d, got %+v", offset, expected, reply)
+		}
+	}
+	if reply := handleGetBit(db, bulkValues("missing", "3")); reply.Num != 0 {
+		t.Fatalf("expected 0 for a missing key, got %+v", reply)
+	}
+
+	handleSetBit(db, bulkValues("ttl", "3", "1"))
+	if record, _ := db.lookupKey("ttl"); record.ExpiryTime == nil {
+		t.Fatal("SETBIT dropped the TTL")
+	}
+
+	testCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{"Negative Offset", []string{"key", "-1", "1"}, errBitOffset},
+		{"Offset Too Large", []string{"key", "4294967296", "1"}, errBitOffset},
+		{"Bit Not 0 Or 1", []string{"key", "1", "2"}, errBitValue},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if reply := handleSetBit(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+				t.Errorf("expected %q, got %+v", tc.expectedErr, reply)
+			}
+		})
+	}
+}
+
+func TestBitCountAndBitPos(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleMSet(db, bulkValues("foobar", "foobar", "ones", "\xff\xf0\x00", "zeros", "\x00\xff\xf0", "full", "\xff\xff\xff"))
+
+	testCases := []struct {
+		name     string
+		handler  func(*Database, []resp.Value) resp.Value
+		args     []string
+		expected resp.Value
+	}{
+		{"BitCount", handleBitCount, []string{"foobar"}, resp.Value{DataType: resp.TypeInteger, Num: 26}},
+		{"BitCount First Byte", handleBitCount, []string{"foobar", "0", "0"}, resp.Value{DataType: resp.TypeInteger, Num: 4}},
+		{"BitCount Byte Range", handleBitCount, []string{"foobar", "1", "1", "BYTE"}, resp.Value{DataType: resp.TypeInteger, Num: 6}},
+		{"BitCount Bit Range", handleBitCount, []string{"foobar", "5", "30", "BIT"}, resp.Value{DataType: resp.TypeInteger, Num: 17}},
+		{"BitCount Negative Range", handleBitCount, []string{"foobar", "-2", "-1"}, resp.Value{DataType: resp.TypeInteger, Num: 7}},
+		{"BitCount Empty Range", handleBitCount, []string{"foobar", "4", "2"}, resp.Value{DataType: resp.TypeInteger, Num: 0}},
+		{"BitCount Missing", handleBitCount, []string{"missing"}, resp.Value{DataType: resp.TypeInteger, Num: 0}},
+		{"BitCount Start Only", handleBitCount, []string{"foobar", "1"}, resp.Value{DataType: resp.TypeError, Err: errSyntax}},
+		{"BitCount Unknown Unit", handleBitCount, []string{"foobar", "0", "1", "WORD"}, resp.Value{DataType: resp.TypeError, Err: errSyntax}},
+		{"BitPos Clear", handleBitPos, []string{"ones", "0"}, resp.Value{DataType: resp.TypeInteger, Num: 12}},
+		{"BitPos Set", handleBitPos, []string{"zeros", "1", "0"}, resp.Value{DataType: resp.TypeInteger, Num: 8}},
+		{"BitPos From Byte", handleBitPos, []string{"zeros", "1", "2"}, resp.Value{DataType: resp.TypeInteger, Num: 16}},
+		{"BitPos Byte Range", handleBitPos, []string{"zeros", "1", "2", "-1", "BYTE"}, resp.Value{DataType: resp.TypeInteger, Num: 16}},
+		{"BitPos Bit Range", handleBitPos, []string{"zeros", "1", "7", "15", "BIT"}, resp.Value{DataType: resp.TypeInteger, Num: 8}},
+		{"BitPos Not Found", handleBitPos, []string{"zeros", "1", "0", "0"}, resp.Value{DataType: resp.TypeInteger, Num: -1}},
+		{"BitPos Clear Past The End", handleBitPos, []string{"full", "0"}, resp.Value{DataType: resp.TypeInteger, Num: 24}},
+		{"BitPos Clear With End", handleBitPos, []string{"full", "0", "0", "-1"}, resp.Value{DataType: resp.TypeInteger, Num: -1}},
+		{"BitPos Set Missing", handleBitPos, []string{"missing", "1"}, resp.Value{DataType: resp.TypeInteger, Num: -1}},
+		{"BitPos Clear Missing", handleBitPos, []string{"missing", "0"}, resp.Value{DataType: resp.TypeInteger, Num: 0}},
+		{"BitPos Invalid Bit", handleBitPos, []string{"zeros", "2"}, resp.Value{DataType: resp.TypeError, Err: errBitPosValue}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := tc.handler(db, bulkValues(tc.args...))
+			if reply.DataType != tc.expected.DataType || reply.Num != tc.expected.Num || reply.Err != tc.expected.Err {
+				t.Errorf("expected %+v, got %+v", tc.expected, reply)
+			}
+		})
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleMSet(db, bulkValues("a", "foobar", "b", "abcdef", "short", "\xff"))
+	handleSAdd(db, bulkValues("set", "x"))
+
+	testCases := []struct {
+		op       string
+		keys     []string
+		expected string
+	}{
+		{"AND", []string{"a", "b"}, "`bc`ab"},
+		{"OR", []string{"a", "b"}, "goofev"},
+		{"XOR", []string{"a", "b"}, "\x07\x0d\x0c\x06\x04\x14"},
+		{"NOT", []string{"short"}, "\x00"},
+		{"AND", []string{"a", "short"}, "f\x00\x00\x00\x00\x00"},
+		{"OR", []string{"short", "missing"}, "\xff"},
+	}
+	for _, tc := range testCases {
+		reply := handleBitOp(db, bulkValues(append([]string{tc.op, "dest"}, tc.keys...)...))
+		if reply.Num != int64(len(tc.expected)) {
+			t.Errorf("BITOP %s %v: expected length %d, got %+v", tc.op, tc.keys, len(tc.expected), reply)
+		}
+		if value, _, _ := db.loadString("dest"); value != tc.expected {
+			t.Errorf("BITOP %s %v: expected %q, got %q", tc.op, tc.keys, tc.expected, value)
+		}
+	}
+
+	if reply := handleBitOp(db, bulkValues("AND", "dest", "missing", "other")); reply.Num != 0 {
+		t.Fatalf("expected 0, got %+v", reply)
+	}
+	if _, ok := db.lookupKey("dest"); ok {
+		t.Fatal("expected an empty result to delete the destination")
+	}
+	if reply := handleBitOp(db, bulkValues("NOT", "dest", "a", "b")); reply.Err != errBitOpNot {
+		t.Fatalf("expected %q, got %+v", errBitOpNot, reply)
+	}
+	if reply := handleBitOp(db, bulkValues("NAND", "dest", "a")); reply.Err != errSyntax {
+		t.Fatalf("expected %q, got %+v", errSyntax, reply)
+	}
+	if reply := handleBitOp(db, bulkValues("OR", "dest", "a", "set")); reply.Err != errWrongType {
+		t.Fatalf("expected %q, got %+v", errWrongType, reply)
+	}
+}
+
+// bitfieldReply flattens a BITFIELD reply, with nil as "nil".
+func bitfieldReply(reply resp.Value) []any {
+	values := make([]any, len(reply.Array))
+	for i, v := range reply.Array {
+		if v.IsNull {
+			values[i] = "nil"
+		} else {
+			values[i] = v.Num
+		}
+	}
+	return values
+}
+
+func TestBitfield(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []any
+	}{
+		{"Incr And Get", []string{"INCRBY", "i5", "100", "1", "GET", "u4", "0"}, []any{int64(1), int64(0)}},
+		{"Set Returns Old Value", []string{"SET", "i8", "#1", "-100", "SET", "i8", "#1", "5"}, []any{int64(0), int64(-100)}},
+		{"Signed Wrap", []string{"SET", "i8", "0", "200", "GET", "i8", "0"}, []any{int64(0), int64(-56)}},
+		{"Signed Sat", []string{"OVERFLOW", "SAT", "SET", "i8", "0", "200", "GET", "i8", "0"}, []any{int64(-56), int64(127)}},
+		{"Signed Incr Sat Low", []string{"OVERFLOW", "SAT", "INCRBY", "i8", "0", "-1000"}, []any{int64(-128)}},
+		{"Unsigned Wrap", []string{"SET", "u2", "100", "3", "INCRBY", "u2", "100", "1"}, []any{int64(0), int64(0)}},
+		{"Unsigned Sat", []string{"SET", "u2", "100", "3", "OVERFLOW", "SAT", "INCRBY", "u2", "100", "1"}, []any{int64(0), int64(3)}},
+		{"Unsigned Negative Set", []string{"SET", "u8", "200", "-1", "GET", "u8", "200"}, []any{int64(0), int64(255)}},
+		{"Unsigned Decr Below Zero Sat", []string{"OVERFLOW", "SAT", "INCRBY", "u8", "200", "-300"}, []any{int64(0)}},
+		{"Fail", []string{"SET", "u2", "102", "3", "OVERFLOW", "FAIL", "INCRBY", "u2", "102", "1", "GET", "u2", "102"}, []any{int64(0), "nil", int64(3)}},
+		{"Overflow Applies To Later Ops", []string{"INCRBY", "u2", "102", "1", "OVERFLOW", "FAIL", "INCRBY", "u2", "102", "4"}, []any{int64(0), "nil"}},
+		{"i64", []string{"SET", "i64", "300", "9223372036854775807", "INCRBY", "i64", "300", "1"}, []any{int64(0), int64(-9223372036854775808)}},
+		{"u63 Sat", []string{"OVERFLOW", "SAT", "INCRBY", "u63", "400", "9223372036854775807", "INCRBY", "u63", "400", "1"}, []any{int64(9223372036854775807), int64(9223372036854775807)}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := handleBitfield(db, bulkValues(append([]string{"key"}, tc.args...)...))
+			got := bitfieldReply(reply)
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %v, got %+v", tc.expected, reply)
+			}
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Fatalf("expected %v, got %v", tc.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestBitfieldErrorsAndReadOnly(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	testCases := []struct {
+		name        string
+		handler     func(*Database, []resp.Value) resp.Value
+		args        []string
+		expectedErr string
+	}{
+		{"Invalid Type", handleBitfield, []string{"GET", "x8", "0"}, errBitfieldType},
+		{"u64", handleBitfield, []string{"GET", "u64", "0"}, errBitfieldType},
+		{"i65", handleBitfield, []string{"GET", "i65", "0"}, errBitfieldType},
+		{"Negative Offset", handleBitfield, []string{"GET", "u8", "-1"}, errBitOffset},
+		{"Invalid Overflow", handleBitfield, []string{"OVERFLOW", "CLAMP"}, errOverflowType},
+		{"Missing Operand", handleBitfield, []string{"SET", "u8", "0"}, errSyntax},
+		{"Unknown Subcommand", handleBitfield, []string{"DEL", "u8", "0"}, errSyntax},
+		{"Value Not A Number", handleBitfield, []string{"SET", "u8", "0", "x"}, errNotInteger},
+		{"Read Only Write", handleBitfieldRO, []string{"GET", "u8", "0", "SET", "u8", "0", "1"}, errBitfieldRO},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if reply := tc.handler(db, bulkValues(append([]string{"key"}, tc.args...)...)); reply.Err != tc.expectedErr {
+				t.Errorf("expected %q, got %+v", tc.expectedErr, reply)
+			}
+		})
+	}
+	if _, ok := db.lookupKey("key"); ok {
+		t.Fatal("a failed BITFIELD created the key")
+	}
+
+	handleBitfield(db, bulkValues("key", "GET", "u8", "0"))
+	if _, ok := db.lookupKey("key"); ok {
+		t.Fatal("a BITFIELD that only reads created the key")
+	}
+	handleSet(db, bulkValues("key", "\x0f"))
+	if reply := handleBitfieldRO(db, bulkValues("key", "GET", "u4", "4", "GET", "i4", "4")); len(reply.Array) != 2 ||
+		reply.Array[0].Num != 15 || reply.Array[1].Num != -1 {
+		t.Fatalf("expected [15 -1], got %+v", reply)
+	}
+}
@@ -0,0 +1,292 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	errTimeoutNotFloat = "ERR timeout is not a float or out of range"
+	errTimeoutNegative = "ERR timeout is negative"
+)
+
+// blockedClient is a connection parked in one of the blocking list
+// commands. Whoever makes one of its keys non-empty calls serve on its
+// behalf and hands the reply over through the reply channel.
+type blockedClient struct {
+	client *Client
+	db     *Database
+	keys   []string
+	serve  func(db *Database, key string) resp.Value
+	reply  chan resp.Value
+}
+
+var (
+	// blockingMu guards blockedOnKey and is held while serving waiters, so
+	// each waiter is served at most once and in FIFO order.
+	blockingMu   sync.Mutex
+	blockedOnKey = make(map[dbKey][]*blockedClient)
+
+	// readyKeys collects keys that received data since the last call to
+	// serveBlockedClients. It has its own lock because serving a BLMOVE
+	// waiter pushes to, and so signals, another key.
+	readyMu   sync.Mutex
+	readyKeys []dbKey
+)
+
+// signalKeyReady records that a list in db was pushed to. Execute calls
+// serveBlockedClients once the pushing command has been propagated, so a
+// waiter's pop is logged after the push that fed it.
+func signalKeyReady(db *Database, key string) {
+	readyMu.Lock()
+	readyKeys = append(readyKeys, dbKey{db.id, key})
+	readyMu.Unlock()
+}
+
+// hasReadyKeys reports whether any key was signalled as ready and not yet
+// served.
+func hasReadyKeys() bool {
+	readyMu.Lock()
+	defer readyMu.Unlock()
+	return len(readyKeys) > 0
+}
+
+// signalDatabaseReady signals every key clients are blocked on in db, whose
+// contents were replaced as a whole.
+func signalDatabaseReady(db *Database) {
+	blockingMu.Lock()
+	defer blockingMu.Unlock()
+	for blocked := range blockedOnKey {
+		if blocked.db == db.id {
+			signalKeyReady(db, blocked.key)
+		}
+	}
+}
+
+// serveBlockedClients hands elements of every list signalled as ready to
+// the clients blocked on it, oldest waiter first, for as long as the list
+// has elements left.
+func serveBlockedClients() {
+	blockingMu.Lock()
+	defer blockingMu.Unlock()
+
+	for {
+		readyMu.Lock()
+		if len(readyKeys) == 0 {
+			readyMu.Unlock()
+			return
+		}
+		ready := readyKeys[0]
+		readyKeys = readyKeys[1:]
+		readyMu.Unlock()
+
+		db := databases[ready.db]
+		for len(blockedOnKey[ready]) > 0 {
+			list, wrongType := db.loadList(ready.key)
+			if wrongType || list == nil {
+				break
+			}
+
+			waiter := blockedOnKey[ready][0]
+			unblockLocked(waiter)
+			select {
+			case <-waiter.client.Done():
+				// Nobody would read the reply, so leave the data in place
+				continue
+			default:
+			}
+			waiter.reply <- waiter.serve(db, ready.key)
+		}
+	}
+}
+
+func unblockLocked(waiter *blockedClient) {
+	for _, key := range waiter.keys {
+		key := dbKey{waiter.db.id, key}
+		queue := blockedOnKey[key]
+		for i := 0; i < len(queue); i++ {
+			if queue[i] == waiter {
+				queue = append(queue[:i], queue[i+1:]...)
+				i--
+			}
+		}
+		if len(queue) == 0 {
+			delete(blockedOnKey, key)
+		} else {
+			blockedOnKey[key] = queue
+		}
+	}
+}
+
+// blockOnKeys serves the request right away when one of keys holds a
+// non-empty list in the client's database and otherwise parks the client
+// until another client pushes to one of them, the timeout expires or the
+// client goes away. A zero timeout blocks forever. serve is only ever
+// called for a key holding a non-empty list, and propagates the change it
+// makes itself.
+func blockOnKeys(client *Client, keys []string, timeout time.Duration, serve func(db *Database, key string) resp.Value) resp.Value {
+	// Blocking commands take the keyspace lock themselves so that it is not
+	// held while they wait. A waiter is served by the pushing command, under
+	// that command's hold on the lock.
+	// Inside EXEC the lock is already held and the command cannot wait, so
+	// it behaves like its non-blocking variant.
+	if !client.inExec {
+		lockKeyspace()
+	}
+	unlock := func() {
+		blockingMu.Unlock()
+		if !client.inExec {
+			unlockKeyspace()
+		}
+	}
+	db := client.database()
+	blockingMu.Lock()
+	for _, key := range keys {
+		list, wrongType := db.loadList(key)
+		if wrongType {
+			unlock()
+			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+		}
+		if list != nil {
+			reply := serve(db, key)
+			unlock()
+			return reply
+		}
+	}
+	if client.inExec {
+		unlock()
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+
+	waiter := &blockedClient{
+		client: client,
+		db:     db,
+		keys:   keys,
+		serve:  serve,
+		reply:  make(chan resp.Value, 1),
+	}
+	for _, key := range keys {
+		blocked := dbKey{db.id, key}
+		blockedOnKey[blocked] = append(blockedOnKey[blocked], waiter)
+	}
+	unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case reply := <-waiter.reply:
+		return reply
+	case <-expired:
+	case <-client.Done():
+	}
+
+	blockingMu.Lock()
+	defer blockingMu.Unlock()
+	// We may have been served while waiting for the lock
+	select {
+	case reply := <-waiter.reply:
+		return reply
+	default:
+	}
+	unblockLocked(waiter)
+	return resp.Value{DataType: resp.TypeNull, IsNull: true}
+}
+
+// parseTimeout parses a blocking timeout given in (possibly fractional)
+// seconds.
+func parseTimeout(s string) (time.Duration, string) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) ||
+		seconds > float64(math.MaxInt64/int64(time.Second)) {
+		return 0, errTimeoutNotFloat
+	}
+	if seconds < 0 {
+		return 0, errTimeoutNegative
+	}
+	return time.Duration(seconds * float64(time.Second)), ""
+}
+
+func handleBLPop(client *Client, args []resp.Value) resp.Value {
+	return blockingPopCommand(client, args, true)
+}
+
+func handleBRPop(client *Client, args []resp.Value) resp.Value {
+	return blockingPopCommand(client, args, false)
+}
+
+func blockingPopCommand(client *Client, args []resp.Value, left bool) resp.Value {
+	timeout, errMsg := parseTimeout(args[len(args)-1].Bulk)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[:len(args)-1] {
+		keys[i] = arg.Bulk
+	}
+
+	return blockOnKeys(client, keys, timeout, func(db *Database, key string) resp.Value {
+		list, _ := db.loadList(key)
+		popped := popElements(list, left, 1)
+		db.deleteListIfEmpty(key, list)
+		if left {
+			propagate(db, bulkValues("LPOP", key))
+		} else {
+			propagate(db, bulkValues("RPOP", key))
+		}
+		return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{
+			{DataType: resp.TypeBulk, Bulk: key},
+			{DataType: resp.TypeBulk, Bulk: popped[0]},
+		}}
+	})
+}
+
+func handleBLMove(client *Client, args []resp.Value) resp.Value {
+	source, destination := args[0].Bulk, args[1].Bulk
+	fromLeft, okFrom := parseListSide(args[2].Bulk)
+	toLeft, okTo := parseListSide(args[3].Bulk)
+	if !okFrom || !okTo {
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+	timeout, errMsg := parseTimeout(args[4].Bulk)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+
+	return blockOnKeys(client, []string{source}, timeout, func(db *Database, key string) resp.Value {
+		element, _, wrongType := db.moveElement(source, destination, fromLeft, toLeft)
+		if wrongType {
+			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+		}
+		propagate(db, bulkValues("LMOVE", source, destination, args[2].Bulk, args[3].Bulk))
+		return resp.Value{DataType: resp.TypeBulk, Bulk: element}
+	})
+}
+
+func handleBLMPop(client *Client, args []resp.Value) resp.Value {
+	timeout, errMsg := parseTimeout(args[0].Bulk)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	parsed, errMsg := parseLMPopArgs(args[1:])
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+
+	return blockOnKeys(client, parsed.keys, timeout, func(db *Database, key string) resp.Value {
+		result, _ := db.popFirstNonEmpty([]string{key}, parsed.left, parsed.count)
+		side := "RIGHT"
+		if parsed.left {
+			side = "LEFT"
+		}
+		propagate(db, bulkValues("LMPOP", "1", key, side, "COUNT", strconv.Itoa(parsed.count)))
+		return result
+	})
+}
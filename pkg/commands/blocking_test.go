@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"testing"
+	"time"
+)
+
+// waitForBlocked waits until n clients are blocked on key in database 0.
+func waitForBlocked(t *testing.T, key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		blockingMu.Lock()
+		blocked := len(blockedOnKey[dbKey{0, key}])
+		blockingMu.Unlock()
+		if blocked == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d clients blocked on %q", n, key)
+}
+
+func TestBLPopServesWaitersInFIFOOrder(t *testing.T) {
+	db := databases[0]
+	db.deleteKey("queue")
+
+	replies := make([]chan resp.Value, 3)
+	for i := range replies {
+		replies[i] = make(chan resp.Value, 1)
+		go func(reply chan resp.Value) {
+			reply <- handleBLPop(NewClient(), bulkValues("queue", "0"))
+		}(replies[i])
+		waitForBlocked(t, "queue", i+1)
+	}
+
+	Execute(NewClient(), "RPUSH", bulkValues("queue", "a", "b", "c"))
+
+	for i, expected := range []string{"a", "b", "c"} {
+		select {
+		case reply := <-replies[i]:
+			if len(reply.Array) != 2 || reply.Array[0].Bulk != "queue" || reply.Array[1].Bulk != expected {
+				t.Fatalf("waiter %d got %+v, expected [queue %s]", i, reply, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("waiter %d was never served", i)
+		}
+	}
+	if _, ok := db.lookupKey("queue"); ok {
+		t.Fatal("expected the drained list to be deleted")
+	}
+}
+
+func TestBLPopTimeoutAndDisconnect(t *testing.T) {
+	db := databases[0]
+	db.deleteKey("empty")
+
+	start := time.Now()
+	reply := handleBLPop(NewClient(), bulkValues("empty", "0.05"))
+	if !reply.IsNull {
+		t.Fatalf("expected a null reply on timeout, got %+v", reply)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("returned after %v, before the timeout", elapsed)
+	}
+
+	client := NewClient()
+	done := make(chan resp.Value)
+	go func() {
+		done <- handleBLPop(client, bulkValues("empty", "0"))
+	}()
+	waitForBlocked(t, "empty", 1)
+	client.Close()
+	if reply := <-done; !reply.IsNull {
+		t.Fatalf("expected a null reply after disconnect, got %+v", reply)
+	}
+	waitForBlocked(t, "empty", 0)
+}
+
+func TestBLMoveIsWokenByPush(t *testing.T) {
+	db := databases[0]
+	db.deleteKey("src")
+	db.deleteKey("dst")
+
+	done := make(chan resp.Value)
+	go func() {
+		done <- handleBLMove(NewClient(), bulkValues("src", "dst", "LEFT", "RIGHT", "0"))
+	}()
+	waitForBlocked(t, "src", 1)
+
+	Execute(NewClient(), "LPUSH", bulkValues("src", "job"))
+	if reply := <-done; reply.Bulk != "job" {
+		t.Fatalf("expected job, got %+v", reply)
+	}
+	if reply := handleLRange(db, bulkValues("dst", "0", "-1")); len(reply.Array) != 1 || reply.Array[0].Bulk != "job" {
+		t.Fatalf("expected dst to hold [job], got %+v", reply)
+	}
+}
+
+func TestClientCommandWithNothingToServeSkipsTheLock(t *testing.T) {
+	client := NewClient()
+	defer client.Close()
+
+	// A reader holding the lock keeps a writer out, but not HELLO
+	keyspaceMu.RLock()
+	done := make(chan resp.Value, 1)
+	go func() {
+		reply, _ := Execute(client, "HELLO", nil)
+		done <- reply
+	}()
+	select {
+	case reply := <-done:
+		if reply.DataType != resp.TypeMap {
+			t.Errorf("expected a map, got %+v", reply)
+		}
+	case <-time.After(time.Second):
+		keyspaceMu.RUnlock()
+		<-done
+		t.Fatal("HELLO waited for the exclusive keyspace lock")
+	}
+	keyspaceMu.RUnlock()
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// clientOutputBuffer is how many replies and messages may be queued for a
+// client before a publisher gives up on it.
+const clientOutputBuffer = 1024
+
+// nextClientID numbers the connections, as reported by HELLO.
+var nextClientID atomic.Int64
+
+// Client holds the per-connection state needed by commands that do more
+// than transform their arguments, such as the blocking list pops, pub/sub
+// and transactions.
+type Client struct {
+	id        int64
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// protocol is the RESP version negotiated with HELLO. Replies and
+	// messages are converted for it as they are queued, which publishers do
+	// from their own connection.
+	protocol atomic.Int32
+	name     string
+
+	// db is the number of the database selected with SELECT.
+	db int
+
+	// out queues everything that is to be written to the connection, in
+	// order. A single writer drains it, so messages pushed by publishers
+	// never interleave with a reply.
+	out chan resp.Value
+	// flush asks the writer to send what it has written so far. Replies
+	// are only flushed once a batch of pipelined requests is answered.
+	flush chan struct{}
+
+	// channels and patterns are the client's subscriptions. They are
+	// guarded by pubsubMu.
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	// The transaction state is only used by the client's own connection.
+	inMulti    bool
+	inExec     bool
+	multiError bool
+	queued     []queuedCommand
+
+	// watched and dirtyCAS are guarded by watchMu.
+	watched  map[dbKey]struct{}
+	dirtyCAS bool
+}
+
+func NewClient() *Client {
+	client := &Client{
+		id:       nextClientID.Add(1),
+		closed:   make(chan struct{}),
+		out:      make(chan resp.Value, clientOutputBuffer),
+		flush:    make(chan struct{}, 1),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		watched:  make(map[dbKey]struct{}),
+	}
+	client.protocol.Store(2)
+	return client
+}
+
+// Protocol returns the RESP version the client speaks, 2 or 3.
+func (c *Client) Protocol() int {
+	return int(c.protocol.Load())
+}
+
+// Close marks the connection as gone, waking up any command that is
+// blocked on its behalf and dropping its subscriptions and watched keys. It
+// is safe to call more than once.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		unsubscribeAll(c)
+		unwatchAll(c)
+	})
+}
+
+// database returns the database the client has selected.
+func (c *Client) database() *Database {
+	return databases[c.db]
+}
+
+// Done returns a channel that is closed once the client has been closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Reply queues a reply for the connection, waiting for room if the client
+// is slow to read. It returns false if the client was closed first. The
+// reply is sent with the next Flush.
+func (c *Client) Reply(v resp.Value) bool {
+	select {
+	case c.out <- v.ForProtocol(c.Protocol()):
+		return true
+	case <-c.closed:
+		return false
+	}
+}
+
+// Replies returns the queue the connection writer drains.
+func (c *Client) Replies() <-chan resp.Value {
+	return c.out
+}
+
+// Flush asks the connection writer to send everything queued so far.
+func (c *Client) Flush() {
+	select {
+	case c.flush <- struct{}{}:
+	default:
+		// A flush is already pending, and it will cover this one
+	}
+}
+
+// Flushes returns the channel on which the connection writer is asked to
+// flush. Before flushing, it must write everything that is queued.
+func (c *Client) Flushes() <-chan struct{} {
+	return c.flush
+}
+
+// push queues a message the client did not ask for. Publishers must not be
+// held up by a slow subscriber, so a client whose queue is full is
+// disconnected instead, like Redis does past its output buffer limit.
+func (c *Client) push(v resp.Value) {
+	select {
+	case c.out <- v.ForProtocol(c.Protocol()):
+		c.Flush()
+	default:
+		log.Println("Closing client that exceeded its output buffer")
+		c.Close()
+	}
+}
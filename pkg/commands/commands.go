@@ -1,8 +1,9 @@
 package commands
 
 import (
+	"fmt"
 	"go-redis/pkg/resp"
-	"sync"
+	"strings"
 	"time"
 )
 
@@ -22,17 +23,16 @@ type Record struct {
 	ExpiryTime *time.Time
 }
 
-var dataSet sync.Map
-
 const (
-	errWrongArgsCount = "ERR wrong number of arguments for command"
-	errSyntax         = "ERR syntax error"
-	errNotInteger     = "ERR value is not an integer or out of range"
-	errWrongType      = "WRONGTYPE Operation against a key holding the wrong kind of value"
-	okResponse        = "OK"
+	errSyntax     = "ERR syntax error"
+	errNotInteger = "ERR value is not an integer or out of range"
+	errWrongType  = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	okResponse    = "OK"
 )
 
-var CommandHandler = map[string]func([]resp.Value) resp.Value{
+// CommandHandler holds the commands that only need the database selected
+// by the calling connection in addition to their arguments.
+var CommandHandler = map[string]func(*Database, []resp.Value) resp.Value{
 	"PING":   handlePing,
 	"ECHO":   handleEcho,
 	"GET":    handleGet,
@@ -41,7 +41,360 @@ var CommandHandler = map[string]func([]resp.Value) resp.Value{
 	"DEL":    handleDelete,
 	"INCR":   handleIncr,
 	"DECR":   handleDecr,
+
+	"INCRBY":      handleIncrBy,
+	"DECRBY":      handleDecrBy,
+	"INCRBYFLOAT": handleIncrByFloat,
+
+	"GETDEL":      handleGetDel,
+	"GETEX":       handleGetEx,
+	"EXPIRE":      handleExpire,
+	"PEXPIRE":     handlePExpire,
+	"EXPIREAT":    handleExpireAt,
+	"PEXPIREAT":   handlePExpireAt,
+	"TTL":         handleTTL,
+	"PTTL":        handlePTTL,
+	"EXPIRETIME":  handleExpireTime,
+	"PEXPIRETIME": handlePExpireTime,
+	"PERSIST":     handlePersist,
+
 	"LPUSH":  handleLPush,
 	"RPUSH":  handleRPush,
 	"LRANGE": handleLRange,
+
+	"LPUSHX":    handleLPushX,
+	"RPUSHX":    handleRPushX,
+	"LPOP":      handleLPop,
+	"RPOP":      handleRPop,
+	"LLEN":      handleLLen,
+	"LINDEX":    handleLIndex,
+	"LSET":      handleLSet,
+	"LINSERT":   handleLInsert,
+	"LREM":      handleLRem,
+	"LTRIM":     handleLTrim,
+	"LPOS":      handleLPos,
+	"LMOVE":     handleLMove,
+	"RPOPLPUSH": handleRPopLPush,
+	"LMPOP":     handleLMPop,
+
+	"HSET":         handleHSet,
+	"HMSET":        handleHMSet,
+	"HSETNX":       handleHSetNX,
+	"HGET":         handleHGet,
+	"HMGET":        handleHMGet,
+	"HDEL":         handleHDel,
+	"HEXISTS":      handleHExists,
+	"HLEN":         handleHLen,
+	"HSTRLEN":      handleHStrLen,
+	"HKEYS":        handleHKeys,
+	"HVALS":        handleHVals,
+	"HGETALL":      handleHGetAll,
+	"HINCRBY":      handleHIncrBy,
+	"HINCRBYFLOAT": handleHIncrByFloat,
+	"HRANDFIELD":   handleHRandField,
+	"HSCAN":        handleHScan,
+
+	"SADD":        handleSAdd,
+	"SREM":        handleSRem,
+	"SISMEMBER":   handleSIsMember,
+	"SMISMEMBER":  handleSMIsMember,
+	"SMEMBERS":    handleSMembers,
+	"SCARD":       handleSCard,
+	"SPOP":        handleSPop,
+	"SRANDMEMBER": handleSRandMember,
+	"SMOVE":       handleSMove,
+	"SINTER":      handleSInter,
+	"SUNION":      handleSUnion,
+	"SDIFF":       handleSDiff,
+	"SINTERSTORE": handleSInterStore,
+	"SUNIONSTORE": handleSUnionStore,
+	"SDIFFSTORE":  handleSDiffStore,
+	"SINTERCARD":  handleSInterCard,
+	"SSCAN":       handleSScan,
+
+	"ZADD":        handleZAdd,
+	"ZINCRBY":     handleZIncrBy,
+	"ZREM":        handleZRem,
+	"ZSCORE":      handleZScore,
+	"ZCARD":       handleZCard,
+	"ZRANK":       handleZRank,
+	"ZREVRANK":    handleZRevRank,
+	"ZCOUNT":      handleZCount,
+	"ZRANGE":      handleZRange,
+	"ZRANGESTORE": handleZRangeStore,
+	"ZPOPMIN":     handleZPopMin,
+	"ZPOPMAX":     handleZPopMax,
+	"ZUNIONSTORE": handleZUnionStore,
+	"ZINTERSTORE": handleZInterStore,
+	"ZSCAN":       handleZScan,
+
+	"PUBLISH": handlePublish,
+	"PUBSUB":  handlePubSub,
+
+	"TYPE":      handleType,
+	"KEYS":      handleKeys,
+	"SCAN":      handleScan,
+	"RANDOMKEY": handleRandomKey,
+
+	"DBSIZE":   handleDBSize,
+	"MOVE":     handleMove,
+	"SWAPDB":   handleSwapDB,
+	"FLUSHDB":  handleFlushDB,
+	"FLUSHALL": handleFlushAll,
+
+	"RENAME":   handleRename,
+	"RENAMENX": handleRenameNX,
+	"COPY":     handleCopy,
+	"UNLINK":   handleUnlink,
+	"TOUCH":    handleTouch,
+
+	"APPEND":   handleAppend,
+	"STRLEN":   handleStrLen,
+	"GETRANGE": handleGetRange,
+	"SETRANGE": handleSetRange,
+	"MGET":     handleMGet,
+	"MSET":     handleMSet,
+	"MSETNX":   handleMSetNX,
+	"GETSET":   handleGetSet,
+	"SETNX":    handleSetNX,
+	"SETEX":    handleSetEx,
+	"PSETEX":   handlePSetEx,
+	"LCS":      handleLCS,
+
+	"SETBIT":      handleSetBit,
+	"GETBIT":      handleGetBit,
+	"BITCOUNT":    handleBitCount,
+	"BITPOS":      handleBitPos,
+	"BITOP":       handleBitOp,
+	"BITFIELD":    handleBitfield,
+	"BITFIELD_RO": handleBitfieldRO,
+}
+
+// ClientCommandHandler holds the commands that need the state of the
+// calling connection in addition to their arguments.
+var ClientCommandHandler = map[string]func(*Client, []resp.Value) resp.Value{
+	"BLPOP":  handleBLPop,
+	"BRPOP":  handleBRPop,
+	"BLMOVE": handleBLMove,
+	"BLMPOP": handleBLMPop,
+
+	"SUBSCRIBE":    handleSubscribe,
+	"UNSUBSCRIBE":  handleUnsubscribe,
+	"PSUBSCRIBE":   handlePSubscribe,
+	"PUNSUBSCRIBE": handlePUnsubscribe,
+
+	"HELLO":  handleHello,
+	"SELECT": handleSelect,
+
+	"MULTI":   handleMulti,
+	"EXEC":    handleExec,
+	"DISCARD": handleDiscard,
+	"WATCH":   handleWatch,
+	"UNWATCH": handleUnwatch,
+}
+
+// AdminCommandHandler holds the commands that act on the keyspace as a
+// whole. They run without the keyspace lock and take it themselves.
+var AdminCommandHandler = map[string]func([]resp.Value) resp.Value{
+	"SAVE":         handleSave,
+	"BGSAVE":       handleBGSave,
+	"LASTSAVE":     handleLastSave,
+	"BGREWRITEAOF": handleBGRewriteAOF,
+}
+
+// writeCommands maps each command that may modify the keyspace to a
+// function returning the keys it writes. The blocking pops are listed for
+// the sake of those keys only: they propagate the pops they perform
+// themselves.
+var writeCommands = map[string]func([]resp.Value) []string{
+	"SET": firstKey, "DEL": allKeys, "INCR": firstKey, "DECR": firstKey,
+	"INCRBY": firstKey, "DECRBY": firstKey, "INCRBYFLOAT": firstKey,
+	"GETDEL": firstKey, "GETEX": firstKey, "EXPIRE": firstKey, "PEXPIRE": firstKey,
+	"EXPIREAT": firstKey, "PEXPIREAT": firstKey, "PERSIST": firstKey,
+	"APPEND": firstKey, "SETRANGE": firstKey, "MSET": alternateKeys, "MSETNX": alternateKeys,
+	"GETSET": firstKey, "SETNX": firstKey, "SETEX": firstKey, "PSETEX": firstKey,
+	"SETBIT": firstKey, "BITOP": secondKey, "BITFIELD": firstKey,
+
+	"LPUSH": firstKey, "RPUSH": firstKey, "LPUSHX": firstKey, "RPUSHX": firstKey,
+	"LPOP": firstKey, "RPOP": firstKey, "LSET": firstKey, "LINSERT": firstKey, "LREM": firstKey,
+	"LTRIM": firstKey, "LMOVE": firstTwoKeys, "RPOPLPUSH": firstTwoKeys, "LMPOP": numKeysKeys,
+
+	"HSET": firstKey, "HMSET": firstKey, "HSETNX": firstKey, "HDEL": firstKey,
+	"HINCRBY": firstKey, "HINCRBYFLOAT": firstKey,
+
+	"SADD": firstKey, "SREM": firstKey, "SPOP": firstKey, "SMOVE": firstTwoKeys,
+	"SINTERSTORE": firstKey, "SUNIONSTORE": firstKey, "SDIFFSTORE": firstKey,
+
+	"ZADD": firstKey, "ZINCRBY": firstKey, "ZREM": firstKey, "ZRANGESTORE": firstKey,
+	"ZPOPMIN": firstKey, "ZPOPMAX": firstKey, "ZUNIONSTORE": firstKey, "ZINTERSTORE": firstKey,
+
+	"EVAL": scriptKeys, "EVALSHA": scriptKeys,
+
+	"MOVE": firstKey, "SWAPDB": noKeys, "FLUSHDB": noKeys, "FLUSHALL": noKeys,
+
+	"RENAME": firstTwoKeys, "RENAMENX": firstTwoKeys, "COPY": noKeys, "UNLINK": allKeys,
+}
+
+// commandArity is the number of arguments each command takes, counting the
+// command name itself. A negative arity is a minimum. Execute checks every
+// command against it before running or queueing it, so handlers only need
+// to check what an arity cannot express.
+var commandArity = map[string]int{
+	"PING": -1, "ECHO": 2, "GET": 2, "SET": -3, "EXISTS": -2, "DEL": -2,
+	"INCR": 2, "DECR": 2, "INCRBY": 3, "DECRBY": 3, "INCRBYFLOAT": 3,
+
+	"GETDEL": 2, "GETEX": -2, "EXPIRE": -3, "PEXPIRE": -3, "EXPIREAT": -3,
+	"PEXPIREAT": -3, "TTL": 2, "PTTL": 2, "EXPIRETIME": 2, "PEXPIRETIME": 2,
+	"PERSIST": 2,
+
+	"APPEND": 3, "STRLEN": 2, "GETRANGE": 4, "SETRANGE": 4, "MGET": -2, "MSET": -3,
+	"MSETNX": -3, "GETSET": 3, "SETNX": 3, "SETEX": 4, "PSETEX": 4, "LCS": -3,
+
+	"SETBIT": 4, "GETBIT": 3, "BITCOUNT": -2, "BITPOS": -3, "BITOP": -4,
+	"BITFIELD": -2, "BITFIELD_RO": -2,
+
+	"LPUSH": -3, "RPUSH": -3, "LRANGE": 4, "LPUSHX": -3, "RPUSHX": -3,
+	"LPOP": -2, "RPOP": -2, "LLEN": 2, "LINDEX": 3, "LSET": 4, "LINSERT": 5,
+	"LREM": 4, "LTRIM": 4, "LPOS": -3, "LMOVE": 5, "RPOPLPUSH": 3, "LMPOP": -4,
+
+	"HSET": -4, "HMSET": -4, "HSETNX": 4, "HGET": 3, "HMGET": -3, "HDEL": -3,
+	"HEXISTS": 3, "HLEN": 2, "HSTRLEN": 3, "HKEYS": 2, "HVALS": 2, "HGETALL": 2,
+	"HINCRBY": 4, "HINCRBYFLOAT": 4, "HRANDFIELD": -2, "HSCAN": -3,
+
+	"SADD": -3, "SREM": -3, "SISMEMBER": 3, "SMISMEMBER": -3, "SMEMBERS": 2,
+	"SCARD": 2, "SPOP": -2, "SRANDMEMBER": -2, "SMOVE": 4, "SINTER": -2,
+	"SUNION": -2, "SDIFF": -2, "SINTERSTORE": -3, "SUNIONSTORE": -3,
+	"SDIFFSTORE": -3, "SINTERCARD": -3, "SSCAN": -3,
+
+	"ZADD": -4, "ZINCRBY": 4, "ZREM": -3, "ZSCORE": 3, "ZCARD": 2, "ZRANK": -3,
+	"ZREVRANK": -3, "ZCOUNT": 4, "ZRANGE": -4, "ZRANGESTORE": -5, "ZPOPMIN": -2,
+	"ZPOPMAX": -2, "ZUNIONSTORE": -4, "ZINTERSTORE": -4, "ZSCAN": -3,
+
+	"PUBLISH": 3, "PUBSUB": -2,
+
+	"TYPE": 2, "KEYS": 2, "SCAN": -2, "RANDOMKEY": 1,
+
+	"SELECT": 2, "DBSIZE": 1, "MOVE": 3, "SWAPDB": 3, "FLUSHDB": -1, "FLUSHALL": -1,
+	"RENAME": 3, "RENAMENX": 3, "COPY": -3, "UNLINK": -2, "TOUCH": -2,
+
+	"EVAL": -3, "EVALSHA": -3, "SCRIPT": -2,
+
+	"BLPOP": -3, "BRPOP": -3, "BLMOVE": 6, "BLMPOP": -5,
+	"SUBSCRIBE": -2, "UNSUBSCRIBE": -1, "PSUBSCRIBE": -2, "PUNSUBSCRIBE": -1,
+	"HELLO": -1, "MULTI": 1, "EXEC": 1, "DISCARD": 1, "WATCH": -2, "UNWATCH": 1,
+
+	"SAVE": 1, "BGSAVE": -1, "LASTSAVE": 1, "BGREWRITEAOF": 1,
+}
+
+// Execute runs the named command on behalf of client. The name may be in
+// any case. It reports false, along with the error reply, if there is no
+// such command.
+func Execute(client *Client, name string, args []resp.Value) (resp.Value, bool) {
+	command := strings.ToUpper(name)
+	_, isCommand := CommandHandler[command]
+	_, isClientCommand := ClientCommandHandler[command]
+	_, isAdminCommand := AdminCommandHandler[command]
+	if !isCommand && !isClientCommand && !isAdminCommand {
+		if client.inMulti {
+			client.multiError = true
+		}
+		return resp.Value{DataType: resp.TypeError, Err: unknownCommandError(name, args)}, false
+	}
+	if !validArity(command, len(args)) {
+		if client.inMulti {
+			client.multiError = true
+		}
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError(command)}, true
+	}
+	name = command
+
+	if reply, ok := subscribedModeReply(client, name, args); ok {
+		return reply, true
+	}
+	if client.inMulti && !transactionCommands[name] {
+		return queueCommand(client, name, args), true
+	}
+
+	if handler, ok := AdminCommandHandler[name]; ok {
+		return handler(args), true
+	}
+
+	handler, isCommand := CommandHandler[name]
+	if _, isWrite := writeCommands[name]; isCommand && !isWrite {
+		keyspaceMu.RLock()
+		defer keyspaceMu.RUnlock()
+		return callCommand(client.database(), name, handler, args), true
+	}
+
+	var result resp.Value
+	if isCommand {
+		lockKeyspace()
+		defer unlockKeyspace()
+		result = callCommand(client.database(), name, handler, args)
+	} else {
+		result = ClientCommandHandler[name](client, args)
+		// Most client commands push to no list, and so need not wait for
+		// the exclusive lock. Keys signalled by another command after
+		// this check are served by that command.
+		if !hasReadyKeys() {
+			return result, true
+		}
+		lockKeyspace()
+		defer unlockKeyspace()
+	}
+
+	// Only now that the command has been propagated may clients blocked on
+	// the lists it pushed to pop from them
+	serveBlockedClients()
+	return result, true
+}
+
+// callCommand runs a command from CommandHandler on db and propagates it if
+// it modified the keyspace. The caller holds the keyspace lock.
+func callCommand(db *Database, name string, handler func(*Database, []resp.Value) resp.Value, args []resp.Value) resp.Value {
+	before := modifications.Load()
+	result := handler(db, args)
+	if _, ok := writeCommands[name]; ok && result.DataType != resp.TypeError && modifications.Load() != before {
+		propagateCommand(db, name, args, result)
+	}
+	return result
+}
+
+// validArity reports whether the named command accepts argCount arguments
+// besides its name.
+func validArity(name string, argCount int) bool {
+	arity := commandArity[name]
+	if arity < 0 {
+		return argCount+1 >= -arity
+	}
+	return argCount+1 == arity
+}
+
+func wrongArityError(name string) string {
+	return "ERR wrong number of arguments for '" + strings.ToLower(name) + "' command"
+}
+
+// unknownCommandError quotes the command as it was sent along with the
+// start of its arguments, the way Redis does.
+func unknownCommandError(name string, args []resp.Value) string {
+	var quoted strings.Builder
+	for _, arg := range args {
+		if quoted.Len() >= maxQuotedArgs {
+			break
+		}
+		fmt.Fprintf(&quoted, "'%s' ", truncate(arg.Bulk, maxQuotedArgs-quoted.Len()))
+	}
+	err := fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", truncate(name, maxQuotedArgs), quoted.String())
+	// The reply is a simple string, which cannot span lines
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(err)
+}
+
+// maxQuotedArgs bounds how much of an unknown command is quoted back.
+const maxQuotedArgs = 128
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
 }
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strings"
+	"testing"
+)
+
+func TestExecuteErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		command     string
+		args        []string
+		expectedErr string
+		known       bool
+	}{
+		{
+			name:        "Unknown Command",
+			command:     "foo",
+			args:        []string{"a", "b"},
+			expectedErr: "ERR unknown command 'foo', with args beginning with: 'a' 'b' ",
+		},
+		{
+			name:        "Unknown Command Without Arguments",
+			command:     "Nope",
+			expectedErr: "ERR unknown command 'Nope', with args beginning with: ",
+		},
+		{
+			name:        "Unknown Command With Newlines",
+			command:     "foo\r\n",
+			args:        []string{"a\nb"},
+			expectedErr: "ERR unknown command 'foo  ', with args beginning with: 'a b' ",
+		},
+		{
+			name:        "Unknown Command With Long Arguments",
+			command:     "foo",
+			args:        []string{strings.Repeat("a", 100), strings.Repeat("b", 100), "c"},
+			expectedErr: "ERR unknown command 'foo', with args beginning with: '" + strings.Repeat("a", 100) + "' '" + strings.Repeat("b", 25) + "' ",
+		},
+		{
+			name:        "Too Few Arguments",
+			command:     "get",
+			expectedErr: "ERR wrong number of arguments for 'get' command",
+			known:       true,
+		},
+		{
+			name:        "Too Many Arguments",
+			command:     "LLEN",
+			args:        []string{"list", "extra"},
+			expectedErr: "ERR wrong number of arguments for 'llen' command",
+			known:       true,
+		},
+		{
+			name:        "Below The Minimum Arity",
+			command:     "ZADD",
+			args:        []string{"zset", "1"},
+			expectedErr: "ERR wrong number of arguments for 'zadd' command",
+			known:       true,
+		},
+		{
+			name:        "Client Command",
+			command:     "BLMOVE",
+			args:        []string{"a", "b", "LEFT", "LEFT"},
+			expectedErr: "ERR wrong number of arguments for 'blmove' command",
+			known:       true,
+		},
+		{
+			name:        "Uneven Field Value Pairs",
+			command:     "hmset",
+			args:        []string{"hash", "f1", "v1", "f2"},
+			expectedErr: "ERR wrong number of arguments for 'hmset' command",
+			known:       true,
+		},
+		{
+			name:        "Above The Maximum Arity",
+			command:     "ZPOPMAX",
+			args:        []string{"zset", "1", "2"},
+			expectedErr: "ERR wrong number of arguments for 'zpopmax' command",
+			known:       true,
+		},
+		{
+			name:        "Above The Maximum Arity Of A Shared Handler",
+			command:     "RPOP",
+			args:        []string{"list", "1", "2"},
+			expectedErr: "ERR wrong number of arguments for 'rpop' command",
+			known:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := NewClient()
+			defer client.Close()
+			reply, known := Execute(client, tc.command, bulkValues(tc.args...))
+			if known != tc.known {
+				t.Errorf("Expected known %v, but got %v", tc.known, known)
+			}
+			if reply.DataType != resp.TypeError || reply.Err != tc.expectedErr {
+				t.Errorf("Expected error %q, but got %+v", tc.expectedErr, reply)
+			}
+		})
+	}
+}
+
+func TestExecuteIgnoresCase(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+
+	Execute(client, "set", bulkValues("key", "value"))
+	if reply, _ := Execute(client, "Get", bulkValues("key")); reply.Bulk != "value" {
+		t.Fatalf("expected value, got %+v", reply)
+	}
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"bytes"
+	"go-redis/pkg/resp"
+	"strings"
+)
+
+// handleCopy copies the value at source, along with its TTL, to
+// destination, which may be in another database. An existing destination
+// is only overwritten with REPLACE.
+func handleCopy(db *Database, args []resp.Value) resp.Value {
+	source, destination := args[0].Bulk, args[1].Bulk
+	target, replace := db, false
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i].Bulk); {
+		case option == "DB" && i+1 < len(args):
+			id, errMsg := parseDBIndex(args[i+1].Bulk, errNotInteger)
+			if errMsg != "" {
+				return resp.Value{DataType: resp.TypeError, Err: errMsg}
+			}
+			target = databases[id]
+			i++
+		case option == "REPLACE":
+			replace = true
+		default:
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+	}
+	if target == db && source == destination {
+		return resp.Value{DataType: resp.TypeError, Err: errSameObject}
+	}
+
+	record, ok := db.lookupKey(source)
+	if !ok {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	if _, exists := target.lookupKey(destination); exists && !replace {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	target.storeRecord(destination, record.clone())
+
+	// The destination may be in another database than the one COPY is
+	// logged in, so it is touched here rather than through writeCommands
+	touchKeys(target, []string{destination})
+	if record.Type == TypeList {
+		signalKeyReady(target, destination)
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: 1}
+}
+
+// clone returns a deep copy of the record, which shares nothing with it.
+func (r Record) clone() Record {
+	clone := Record{Type: r.Type}
+	if r.ExpiryTime != nil {
+		expiryTime := *r.ExpiryTime
+		clone.ExpiryTime = &expiryTime
+	}
+
+	switch r.Type {
+	case TypeString:
+		clone.Value = r.Value
+		if bitmap, ok := r.Value.([]byte); ok {
+			clone.Value = bytes.Clone(bitmap)
+		}
+	case TypeList:
+		list, copied := r.Value.(*deque), newDeque()
+		for i := 0; i < list.len(); i++ {
+			copied.pushBack(list.at(i))
+		}
+		clone.Value = copied
+	case TypeSet:
+		clone.Value = r.Value.(*stringSet).clone()
+	case TypeZSet:
+		zset, copied := r.Value.(*sortedSet), newSortedSet()
+		for x := zset.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+			copied.add(x.member, x.score)
+		}
+		clone.Value = copied
+	case TypeHash:
+		clone.Value = r.Value.(*dict[string]).clone()
+	}
+	return clone
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"testing"
+)
+
+func TestCopyIsIndependentOfTheSource(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleRPush(db, bulkValues("list", "a", "b"))
+	handleSAdd(db, bulkValues("set", "a"))
+	handleZAdd(db, bulkValues("zset", "1", "a"))
+	handleHSet(db, bulkValues("hash", "f", "v"))
+	handleSet(db, bulkValues("str", "v", "EX", "100"))
+
+	for _, key := range []string{"list", "set", "zset", "hash", "str"} {
+		if reply := handleCopy(db, bulkValues(key, key+":copy")); reply.Num != 1 {
+			t.Fatalf("expected COPY %s to copy, got %+v", key, reply)
+		}
+	}
+	handleRPush(db, bulkValues("list", "c"))
+	handleSAdd(db, bulkValues("set", "b"))
+	handleZAdd(db, bulkValues("zset", "2", "b"))
+	handleHSet(db, bulkValues("hash", "g", "v"))
+
+	for key, reply := range map[string]resp.Value{
+		"list:copy": handleLLen(db, bulkValues("list:copy")),
+		"set:copy":  handleSCard(db, bulkValues("set:copy")),
+		"zset:copy": handleZCard(db, bulkValues("zset:copy")),
+		"hash:copy": handleHLen(db, bulkValues("hash:copy")),
+	} {
+		expected := int64(1)
+		if key == "list:copy" {
+			expected = 2
+		}
+		if reply.Num != expected {
+			t.Errorf("%s changed along with its source: %+v", key, reply)
+		}
+	}
+	source, _ := db.lookupKey("str")
+	if record, _ := db.lookupKey("str:copy"); record.ExpiryTime == nil ||
+		record.ExpiryTime == source.ExpiryTime || !record.ExpiryTime.Equal(*source.ExpiryTime) {
+		t.Fatalf("the TTL was not copied on its own: %+v", record)
+	}
+}
+
+func TestCopyOptions(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "SET", bulkValues("src", "new"))
+	Execute(client, "SET", bulkValues("dst", "old"))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected resp.Value
+	}{
+		{"Existing Destination", []string{"src", "dst"}, resp.Value{DataType: resp.TypeInteger, Num: 0}},
+		{"Missing Source", []string{"missing", "new"}, resp.Value{DataType: resp.TypeInteger, Num: 0}},
+		{"Same Key", []string{"src", "src"}, resp.Value{DataType: resp.TypeError, Err: errSameObject}},
+		{"Same Key Other Database", []string{"src", "src", "DB", "4"}, resp.Value{DataType: resp.TypeInteger, Num: 1}},
+		{"Replace", []string{"src", "dst", "REPLACE"}, resp.Value{DataType: resp.TypeInteger, Num: 1}},
+		{"DB Out Of Range", []string{"src", "x", "DB", "16"}, resp.Value{DataType: resp.TypeError, Err: errDBIndexOutOfRange}},
+		{"DB Not A Number", []string{"src", "x", "DB", "one"}, resp.Value{DataType: resp.TypeError, Err: errNotInteger}},
+		{"DB Without Index", []string{"src", "x", "DB"}, resp.Value{DataType: resp.TypeError, Err: errSyntax}},
+		{"Unknown Option", []string{"src", "x", "FORCE"}, resp.Value{DataType: resp.TypeError, Err: errSyntax}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reply, _ := Execute(client, "COPY", bulkValues(tc.args...))
+			if reply.DataType != tc.expected.DataType || reply.Num != tc.expected.Num || reply.Err != tc.expected.Err {
+				t.Errorf("expected %+v, got %+v", tc.expected, reply)
+			}
+		})
+	}
+
+	if value, _, _ := databases[0].loadString("dst"); value != "new" {
+		t.Fatalf("expected REPLACE to overwrite dst, got %q", value)
+	}
+	if value, _, _ := databases[4].loadString("src"); value != "new" {
+		t.Fatalf("expected src to be copied to database 4, got %q", value)
+	}
+}
+
+func TestCopyToAnotherDatabaseTouchesWatchedKey(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "SET", bulkValues("src", "v"))
+
+	watcher := NewClient()
+	defer watcher.Close()
+	Execute(watcher, "SELECT", bulkValues("1"))
+	Execute(watcher, "WATCH", bulkValues("dst"))
+
+	Execute(client, "COPY", bulkValues("src", "dst", "DB", "1"))
+	Execute(watcher, "MULTI", nil)
+	Execute(watcher, "GET", bulkValues("dst"))
+	if reply, _ := Execute(watcher, "EXEC", nil); !reply.IsNull {
+		t.Fatalf("expected EXEC to fail after COPY wrote the watched key, got %+v", reply)
+	}
+}
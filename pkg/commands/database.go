@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strconv"
+	"strings"
+)
+
+const (
+	errDBIndexOutOfRange    = "ERR DB index is out of range"
+	errInvalidFirstDBIndex  = "ERR invalid first DB index"
+	errInvalidSecondDBIndex = "ERR invalid second DB index"
+	errSameObject           = "ERR source and destination objects are the same"
+)
+
+// parseDBIndex parses the number of a database, replying with notInteger
+// if it is not a number at all.
+func parseDBIndex(s string, notInteger string) (int, string) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, notInteger
+	}
+	if id < 0 || id >= len(databases) {
+		return 0, errDBIndexOutOfRange
+	}
+	return id, ""
+}
+
+// handleSelect switches the database the client's commands apply to. It
+// only changes the state of the connection, so it runs without the
+// keyspace lock.
+func handleSelect(client *Client, args []resp.Value) resp.Value {
+	id, errMsg := parseDBIndex(args[0].Bulk, errNotInteger)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	client.db = id
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+func handleDBSize(db *Database, args []resp.Value) resp.Value {
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(db.dbSize())}
+}
+
+// handleMove moves a key, along with its TTL, to another database. Nothing
+// moves if the key already exists there.
+func handleMove(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	id, errMsg := parseDBIndex(args[1].Bulk, errNotInteger)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	target := databases[id]
+	if target == db {
+		return resp.Value{DataType: resp.TypeError, Err: errSameObject}
+	}
+
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	if _, exists := target.lookupKey(key); exists {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	target.storeRecord(key, record)
+	db.deleteKey(key)
+
+	// The key is logged as a MOVE, which touches it in db only
+	touchKeys(target, []string{key})
+	if record.Type == TypeList {
+		signalKeyReady(target, key)
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: 1}
+}
+
+// handleSwapDB exchanges the contents of two databases. Clients stay
+// connected to the database they selected, so they see the other one's
+// keys from then on, and those blocked on a list that now exists are
+// served.
+func handleSwapDB(db *Database, args []resp.Value) resp.Value {
+	first, errMsg := parseDBIndex(args[0].Bulk, errInvalidFirstDBIndex)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	second, errMsg := parseDBIndex(args[1].Bulk, errInvalidSecondDBIndex)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	if first == second {
+		return resp.Value{DataType: resp.TypeString, Str: okResponse}
+	}
+
+	a, b := databases[first], databases[second]
+	touchDatabase(a, b)
+	touchDatabase(b, a)
+	swapContents(a, b)
+	signalModified()
+	signalDatabaseReady(a)
+	signalDatabaseReady(b)
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+// parseFlushMode checks the optional ASYNC or SYNC argument of FLUSHDB and
+// FLUSHALL. Both behave the same: the dropped keys are left to the garbage
+// collector, so flushing never waits for them to be freed.
+func parseFlushMode(args []resp.Value) bool {
+	if len(args) == 0 {
+		return true
+	}
+	mode := strings.ToUpper(args[0].Bulk)
+	return len(args) == 1 && (mode == "ASYNC" || mode == "SYNC")
+}
+
+func handleFlushDB(db *Database, args []resp.Value) resp.Value {
+	if !parseFlushMode(args) {
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+	touchDatabase(db, nil)
+	db.flush()
+	// Flushing is propagated even if there was nothing to drop, as in
+	// Redis
+	signalModified()
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+func handleFlushAll(db *Database, args []resp.Value) resp.Value {
+	if !parseFlushMode(args) {
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+	for _, db := range databases {
+		touchDatabase(db, nil)
+		db.flush()
+	}
+	signalModified()
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
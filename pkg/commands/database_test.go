@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"testing"
+)
+
+func TestSelectIsolatesDatabases(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+	other := NewClient()
+	defer other.Close()
+
+	Execute(client, "SET", bulkValues("key", "zero"))
+	if reply, _ := Execute(client, "SELECT", bulkValues("1")); reply.Str != okResponse {
+		t.Fatalf("SELECT failed: %+v", reply)
+	}
+	if reply, _ := Execute(client, "GET", bulkValues("key")); !reply.IsNull {
+		t.Fatalf("database 1 sees a key of database 0: %+v", reply)
+	}
+	Execute(client, "SET", bulkValues("key", "one"))
+	Execute(client, "SET", bulkValues("another", "one"))
+
+	if reply, _ := Execute(other, "GET", bulkValues("key")); reply.Bulk != "zero" {
+		t.Fatalf("SELECT changed the database of another client: %+v", reply)
+	}
+	if reply, _ := Execute(client, "DBSIZE", nil); reply.Num != 2 {
+		t.Fatalf("expected 2 keys in database 1, got %+v", reply)
+	}
+	if reply, _ := Execute(other, "DBSIZE", nil); reply.Num != 1 {
+		t.Fatalf("expected 1 key in database 0, got %+v", reply)
+	}
+}
+
+func TestDatabaseCommandErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		command     string
+		args        []string
+		expectedErr string
+	}{
+		{"Select Not A Number", "SELECT", []string{"one"}, errNotInteger},
+		{"Select Out Of Range", "SELECT", []string{"16"}, errDBIndexOutOfRange},
+		{"Select Negative", "SELECT", []string{"-1"}, errDBIndexOutOfRange},
+		{"Move To The Same Database", "MOVE", []string{"key", "0"}, errSameObject},
+		{"Move Out Of Range", "MOVE", []string{"key", "99"}, errDBIndexOutOfRange},
+		{"SwapDB First Not A Number", "SWAPDB", []string{"a", "1"}, errInvalidFirstDBIndex},
+		{"SwapDB Second Not A Number", "SWAPDB", []string{"0", "b"}, errInvalidSecondDBIndex},
+		{"SwapDB Out Of Range", "SWAPDB", []string{"0", "16"}, errDBIndexOutOfRange},
+		{"FlushDB Unknown Mode", "FLUSHDB", []string{"LATER"}, errSyntax},
+		{"FlushAll Too Many Arguments", "FLUSHALL", []string{"ASYNC", "SYNC"}, errSyntax},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := NewClient()
+			defer client.Close()
+			reply, _ := Execute(client, tc.command, bulkValues(tc.args...))
+			if reply.DataType != resp.TypeError || reply.Err != tc.expectedErr {
+				t.Errorf("Expected error %q, but got %+v", tc.expectedErr, reply)
+			}
+		})
+	}
+}
+
+func TestMoveKeepsTTLAndDoesNotOverwrite(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+
+	Execute(client, "SET", bulkValues("key", "v", "EX", "100"))
+	Execute(client, "SET", bulkValues("taken", "source"))
+	Execute(client, "SELECT", bulkValues("2"))
+	Execute(client, "SET", bulkValues("taken", "target"))
+	Execute(client, "SELECT", bulkValues("0"))
+
+	if reply, _ := Execute(client, "MOVE", bulkValues("key", "2")); reply.Num != 1 {
+		t.Fatalf("expected MOVE to move the key, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "MOVE", bulkValues("taken", "2")); reply.Num != 0 {
+		t.Fatalf("MOVE overwrote a key, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "MOVE", bulkValues("missing", "2")); reply.Num != 0 {
+		t.Fatalf("MOVE moved a missing key, got %+v", reply)
+	}
+
+	if _, ok := databases[0].lookupKey("key"); ok {
+		t.Fatal("the moved key is still in its old database")
+	}
+	if record, _ := databases[2].lookupKey("key"); record.Value != "v" || record.ExpiryTime == nil {
+		t.Fatalf("the moved key or its TTL was lost: %+v", record)
+	}
+	if value, _, _ := databases[2].loadString("taken"); value != "target" {
+		t.Fatalf("expected the existing key to be kept, got %q", value)
+	}
+}
+
+func TestSwapDBTouchesWatchedKeysAndServesBlockedClients(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+
+	Execute(client, "SELECT", bulkValues("1"))
+	Execute(client, "SET", bulkValues("watched", "1"))
+	Execute(client, "RPUSH", bulkValues("queue", "job"))
+	Execute(client, "SELECT", bulkValues("0"))
+
+	watcher := NewClient()
+	defer watcher.Close()
+	Execute(watcher, "WATCH", bulkValues("watched"))
+
+	done := make(chan resp.Value)
+	go func() {
+		reply, _ := Execute(NewClient(), "BLPOP", bulkValues("queue", "0"))
+		done <- reply
+	}()
+	waitForBlocked(t, "queue", 1)
+
+	if reply, _ := Execute(client, "SWAPDB", bulkValues("0", "1")); reply.Str != okResponse {
+		t.Fatalf("SWAPDB failed: %+v", reply)
+	}
+	if reply := <-done; len(reply.Array) != 2 || reply.Array[1].Bulk != "job" {
+		t.Fatalf("expected the blocked client to pop from the swapped in list, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "GET", bulkValues("watched")); reply.Bulk != "1" {
+		t.Fatalf("expected database 0 to hold the keys of database 1, got %+v", reply)
+	}
+
+	Execute(watcher, "MULTI", nil)
+	Execute(watcher, "GET", bulkValues("watched"))
+	if reply, _ := Execute(watcher, "EXEC", nil); !reply.IsNull {
+		t.Fatalf("expected EXEC to fail after SWAPDB, got %+v", reply)
+	}
+}
+
+func TestFlushDBAndFlushAll(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+
+	Execute(client, "SET", bulkValues("a", "0"))
+	Execute(client, "SELECT", bulkValues("3"))
+	Execute(client, "SET", bulkValues("a", "3"))
+	Execute(client, "SET", bulkValues("b", "3", "EX", "100"))
+
+	if reply, _ := Execute(client, "FLUSHDB", bulkValues("async")); reply.Str != okResponse {
+		t.Fatalf("FLUSHDB failed: %+v", reply)
+	}
+	if reply, _ := Execute(client, "DBSIZE", nil); reply.Num != 0 {
+		t.Fatalf("expected FLUSHDB to empty database 3, got %+v", reply)
+	}
+	if _, ok := databases[0].lookupKey("a"); !ok {
+		t.Fatal("FLUSHDB emptied another database")
+	}
+
+	Execute(client, "SET", bulkValues("c", "3"))
+	if reply, _ := Execute(client, "FLUSHALL", nil); reply.Str != okResponse {
+		t.Fatalf("FLUSHALL failed: %+v", reply)
+	}
+	for _, db := range databases {
+		if n := db.dbSize(); n != 0 {
+			t.Fatalf("expected FLUSHALL to empty database %d, %d keys are left", db.id, n)
+		}
+	}
+}
+
+func TestSelectInsideTransaction(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+
+	Execute(client, "MULTI", nil)
+	Execute(client, "SET", bulkValues("key", "0"))
+	if reply, _ := Execute(client, "SELECT", bulkValues("5")); reply.Str != queuedResponse {
+		t.Fatalf("expected SELECT to be queued, got %+v", reply)
+	}
+	Execute(client, "SET", bulkValues("key", "5"))
+	Execute(client, "EXEC", nil)
+
+	if value, _, _ := databases[0].loadString("key"); value != "0" {
+		t.Fatalf("expected the command before SELECT to write database 0, got %q", value)
+	}
+	if value, _, _ := databases[5].loadString("key"); value != "5" {
+		t.Fatalf("expected the command after SELECT to write database 5, got %q", value)
+	}
+	if client.db != 5 {
+		t.Fatalf("expected the client to stay in database 5, got %d", client.db)
+	}
+}
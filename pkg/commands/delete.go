@@ -2,17 +2,20 @@ package commands
 
 import "go-redis/pkg/resp"
 
-func handleDelete(args []resp.Value) resp.Value {
-	if len(args) == 0 {
-		return resp.Value{DataType: resp.TypeError, Err: errWrongArgsCount}
-	}
+func handleDelete(db *Database, args []resp.Value) resp.Value {
 	var numKeysDeleted = 0
 	for _, arg := range args {
 		key := arg.Bulk
-		if _, ok := dataSet.Load(key); ok {
-			dataSet.Delete(key)
+		if _, ok := db.lookupKey(key); ok {
+			db.deleteKey(key)
 			numKeysDeleted++
 		}
 	}
-	return resp.Value{DataType: resp.TypeInteger, Num: numKeysDeleted}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(numKeysDeleted)}
+}
+
+// handleUnlink is DEL, which already leaves freeing the value to the
+// garbage collector, as FLUSHDB ASYNC does (see parseFlushMode).
+func handleUnlink(db *Database, args []resp.Value) resp.Value {
+	return handleDelete(db, args)
 }
@@ -0,0 +1,121 @@
+package commands
+
+const dequeMinCapacity = 8
+
+// deque is the value stored in a TypeList Record. It is a growable ring
+// buffer whose capacity is always a power of two, so pushes and pops at
+// either end are O(1) amortized and indexing is O(1).
+type deque struct {
+	buf  []string
+	head int
+	size int
+}
+
+func newDeque() *deque {
+	return &deque{buf: make([]string, dequeMinCapacity)}
+}
+
+func (d *deque) len() int {
+	return d.size
+}
+
+func (d *deque) slot(i int) int {
+	return (d.head + i) & (len(d.buf) - 1)
+}
+
+// at returns the i-th element counted from the head. i must be in range.
+func (d *deque) at(i int) string {
+	return d.buf[d.slot(i)]
+}
+
+func (d *deque) set(i int, value string) {
+	d.buf[d.slot(i)] = value
+}
+
+func (d *deque) resize(capacity int) {
+	buf := make([]string, capacity)
+	for i := 0; i < d.size; i++ {
+		buf[i] = d.at(i)
+	}
+	d.buf = buf
+	d.head = 0
+}
+
+func (d *deque) growIfFull() {
+	if d.size == len(d.buf) {
+		d.resize(len(d.buf) * 2)
+	}
+}
+
+// shrinkIfSparse gives memory back once the buffer is mostly empty.
+func (d *deque) shrinkIfSparse() {
+	capacity := len(d.buf)
+	for capacity > dequeMinCapacity && d.size <= capacity/4 {
+		capacity /= 2
+	}
+	if capacity != len(d.buf) {
+		d.resize(capacity)
+	}
+}
+
+func (d *deque) pushFront(value string) {
+	d.growIfFull()
+	d.head = (d.head - 1) & (len(d.buf) - 1)
+	d.buf[d.head] = value
+	d.size++
+}
+
+func (d *deque) pushBack(value string) {
+	d.growIfFull()
+	d.buf[d.slot(d.size)] = value
+	d.size++
+}
+
+func (d *deque) popFront() string {
+	value := d.buf[d.head]
+	d.buf[d.head] = ""
+	d.head = (d.head + 1) & (len(d.buf) - 1)
+	d.size--
+	d.shrinkIfSparse()
+	return value
+}
+
+func (d *deque) popBack() string {
+	i := d.slot(d.size - 1)
+	value := d.buf[i]
+	d.buf[i] = ""
+	d.size--
+	d.shrinkIfSparse()
+	return value
+}
+
+// insert places value at position i, shifting later elements towards the
+// tail. i may equal len() to append.
+func (d *deque) insert(i int, value string) {
+	d.pushBack(value)
+	for j := d.size - 1; j > i; j-- {
+		d.set(j, d.at(j-1))
+	}
+	d.set(i, value)
+}
+
+// removeWhere deletes every element for which remove returns true while
+// keeping the others in order, and returns how many were deleted.
+func (d *deque) removeWhere(remove func(i int, value string) bool) int {
+	kept := 0
+	for i := 0; i < d.size; i++ {
+		value := d.at(i)
+		if remove(i, value) {
+			continue
+		}
+		d.set(kept, value)
+		kept++
+	}
+	removed := d.size - kept
+	for i := kept; i < d.size; i++ {
+		d.set(i, "")
+	}
+	d.size = kept
+	d.shrinkIfSparse()
+	return removed
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestDequeMatchesSlice(t *testing.T) {
+	d := newDeque()
+	var reference []string
+
+	for i := 0; i < 20000; i++ {
+		value := strconv.Itoa(i)
+		switch op := rand.IntN(7); {
+		case op == 0:
+			d.pushFront(value)
+			reference = append([]string{value}, reference...)
+		case op == 1:
+			d.pushBack(value)
+			reference = append(reference, value)
+		case op == 2 && len(reference) > 0:
+			if got := d.popFront(); got != reference[0] {
+				t.Fatalf("popFront() = %q, expected %q", got, reference[0])
+			}
+			reference = reference[1:]
+		case op == 3 && len(reference) > 0:
+			if got := d.popBack(); got != reference[len(reference)-1] {
+				t.Fatalf("popBack() = %q, expected %q", got, reference[len(reference)-1])
+			}
+			reference = reference[:len(reference)-1]
+		case op == 4:
+			at := rand.IntN(len(reference) + 1)
+			d.insert(at, value)
+			reference = slices.Insert(reference, at, value)
+		case op == 5 && len(reference) > 0:
+			at := rand.IntN(len(reference))
+			d.set(at, value)
+			reference[at] = value
+		case op == 6:
+			// Drop every element whose value is divisible by 5
+			d.removeWhere(func(_ int, v string) bool {
+				n, _ := strconv.Atoi(v)
+				return n%5 == 0
+			})
+			reference = slices.DeleteFunc(reference, func(v string) bool {
+				n, _ := strconv.Atoi(v)
+				return n%5 == 0
+			})
+		}
+
+		if d.len() != len(reference) {
+			t.Fatalf("len() = %d, expected %d", d.len(), len(reference))
+		}
+	}
+
+	for i, expected := range reference {
+		if got := d.at(i); got != expected {
+			t.Fatalf("at(%d) = %q, expected %q", i, got, expected)
+		}
+	}
+}
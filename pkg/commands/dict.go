@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+)
+
+// dict maps strings to values, like a Go map, but keeps its entries packed
+// in slices, with index giving the position of each key. That makes picking
+// an entry at random take constant time, which a Go map cannot do without
+// walking it. Deleting an entry moves the last one into its place.
+//
+// The read-only methods accept a nil dict, which is empty, so that a
+// missing key can be treated as an empty collection.
+type dict[V any] struct {
+	keys   []string
+	values []V
+	index  map[string]int
+}
+
+// stringSet is the dict a set is stored as.
+type stringSet = dict[struct{}]
+
+func newDict[V any](capacity int) *dict[V] {
+	return &dict[V]{
+		keys:   make([]string, 0, capacity),
+		values: make([]V, 0, capacity),
+		index:  make(map[string]int, capacity),
+	}
+}
+
+// clone returns a copy of d that shares nothing with it.
+func (d *dict[V]) clone() *dict[V] {
+	return &dict[V]{
+		keys:   slices.Clone(d.keys),
+		values: slices.Clone(d.values),
+		index:  maps.Clone(d.index),
+	}
+}
+
+func (d *dict[V]) len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.keys)
+}
+
+func (d *dict[V]) get(key string) (V, bool) {
+	if d == nil {
+		var zero V
+		return zero, false
+	}
+	i, ok := d.index[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return d.values[i], true
+}
+
+func (d *dict[V]) has(key string) bool {
+	_, ok := d.get(key)
+	return ok
+}
+
+// set stores value at key, reporting whether the key was newly added.
+func (d *dict[V]) set(key string, value V) bool {
+	if i, ok := d.index[key]; ok {
+		d.values[i] = value
+		return false
+	}
+	d.index[key] = len(d.keys)
+	d.keys = append(d.keys, key)
+	d.values = append(d.values, value)
+	return true
+}
+
+// delete removes key, reporting whether it was present.
+func (d *dict[V]) delete(key string) bool {
+	if d == nil {
+		return false
+	}
+	i, ok := d.index[key]
+	if !ok {
+		return false
+	}
+	d.removeAt(i)
+	return true
+}
+
+// removeAt removes the entry at position i, moving the last entry into its
+// place.
+func (d *dict[V]) removeAt(i int) {
+	last := len(d.keys) - 1
+	delete(d.index, d.keys[i])
+	if i != last {
+		d.keys[i], d.values[i] = d.keys[last], d.values[last]
+		d.index[d.keys[i]] = i
+	}
+	var zero V
+	d.keys[last], d.values[last] = "", zero
+	d.keys, d.values = d.keys[:last], d.values[:last]
+}
+
+// popRandom removes an entry picked at random and returns it. The dict
+// must not be empty.
+func (d *dict[V]) popRandom() (string, V) {
+	i := rand.IntN(len(d.keys))
+	key, value := d.keys[i], d.values[i]
+	d.removeAt(i)
+	return key, value
+}
+
+// sample implements the count semantics shared by the random sampling
+// commands, returning the positions of the entries picked: a positive count
+// picks up to count distinct entries, a negative count exactly -count
+// entries that may repeat. It takes time proportional to the number of
+// entries picked rather than to the size of the dict.
+func (d *dict[V]) sample(count int64) []int {
+	n := d.len()
+	if n == 0 || count == 0 {
+		return []int{}
+	}
+
+	if count < 0 {
+		// The count comes from the client, so the reply grows as entries
+		// are picked rather than being allocated up front
+		picked := make([]int, 0, min(-count, int64(n)))
+		for i := int64(0); i < -count; i++ {
+			picked = append(picked, rand.IntN(n))
+		}
+		return picked
+	}
+
+	if count >= int64(n) {
+		picked := make([]int, n)
+		for i := range picked {
+			picked[i] = i
+		}
+		return picked
+	}
+	// Floyd's algorithm picks count distinct positions uniformly, with
+	// each step adding either a fresh random position or, if that one was
+	// already taken, the one no earlier step could have picked
+	picked := make([]int, 0, count)
+	taken := make(map[int]struct{}, count)
+	for j := n - int(count); j < n; j++ {
+		i := rand.IntN(j + 1)
+		if _, ok := taken[i]; ok {
+			i = j
+		}
+		taken[i] = struct{}{}
+		picked = append(picked, i)
+	}
+	return picked
+}
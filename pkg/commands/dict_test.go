@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"testing"
+)
+
+func TestDictMatchesMap(t *testing.T) {
+	d := newDict[int](0)
+	reference := map[string]int{}
+
+	for i := 0; i < 20000; i++ {
+		key := strconv.Itoa(rand.IntN(500))
+		switch rand.IntN(3) {
+		case 0, 1:
+			_, existed := reference[key]
+			if added := d.set(key, i); added == existed {
+				t.Fatalf("set(%q) reported added %v, expected %v", key, added, !existed)
+			}
+			reference[key] = i
+		case 2:
+			_, existed := reference[key]
+			if deleted := d.delete(key); deleted != existed {
+				t.Fatalf("delete(%q) = %v, expected %v", key, deleted, existed)
+			}
+			delete(reference, key)
+		}
+
+		if d.len() != len(reference) {
+			t.Fatalf("len() = %d, expected %d", d.len(), len(reference))
+		}
+	}
+
+	for key, expected := range reference {
+		if got, ok := d.get(key); !ok || got != expected {
+			t.Fatalf("get(%q) = %d, %v, expected %d", key, got, ok, expected)
+		}
+	}
+	for i, key := range d.keys {
+		if d.index[key] != i || d.values[i] != reference[key] {
+			t.Fatalf("entry %d, %q, is out of place", i, key)
+		}
+	}
+
+	var missing *dict[int]
+	if missing.len() != 0 || missing.has("a") || missing.delete("a") {
+		t.Fatal("expected a nil dict to be empty")
+	}
+}
+
+func TestDictSample(t *testing.T) {
+	d := newDict[struct{}](0)
+	for i := range 64 {
+		d.set(strconv.Itoa(i), struct{}{})
+	}
+
+	picked := map[int]int{}
+	for range 400 {
+		sample := d.sample(16)
+		seen := map[int]bool{}
+		for _, i := range sample {
+			if seen[i] {
+				t.Fatalf("a positive count picked %d twice: %v", i, sample)
+			}
+			seen[i] = true
+			picked[i]++
+		}
+		if len(sample) != 16 {
+			t.Fatalf("expected 16 entries, got %v", sample)
+		}
+	}
+	for i := range 64 {
+		// Each entry is expected to be picked 100 times
+		if n := picked[i]; n < 40 || n > 200 {
+			t.Errorf("entry %d was picked %d times out of 6400", i, n)
+		}
+	}
+
+	if sample := d.sample(100); len(sample) != 64 {
+		t.Fatalf("expected every entry, got %d", len(sample))
+	}
+	if sample := d.sample(-100); len(sample) != 100 {
+		t.Fatalf("expected 100 entries, got %d", len(sample))
+	}
+	for d.len() > 0 {
+		d.popRandom()
+	}
+	if len(d.index) != 0 {
+		t.Fatalf("popping every entry left %v behind", d.index)
+	}
+}
@@ -2,7 +2,7 @@ package commands
 
 import "go-redis/pkg/resp"
 
-func handleEcho(args []resp.Value) resp.Value {
+func handleEcho(db *Database, args []resp.Value) resp.Value {
 	if len(args) != 1 {
 		return resp.Value{DataType: resp.TypeError, Err: "ERR: wrong number of arguments"}
 	}
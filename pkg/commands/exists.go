@@ -4,16 +4,19 @@ import (
 	"go-redis/pkg/resp"
 )
 
-func handleExists(args []resp.Value) resp.Value {
-	if len(args) == 0 {
-		return resp.Value{DataType: resp.TypeError, Err: errWrongArgsCount}
-	}
+func handleExists(db *Database, args []resp.Value) resp.Value {
 	var result = 0
 	for _, arg := range args {
 		key := arg.Bulk
-		if _, ok := dataSet.Load(key); ok {
+		if _, ok := db.lookupKey(key); ok {
 			result++
 		}
 	}
-	return resp.Value{DataType: resp.TypeInteger, Num: result}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(result)}
+}
+
+// handleTouch counts the given keys that exist. Keys carry no access time,
+// so beyond expiring keys that are due, touching them changes nothing.
+func handleTouch(db *Database, args []resp.Value) resp.Value {
+	return handleExists(db, args)
 }
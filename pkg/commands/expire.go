@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"fmt"
+	"go-redis/pkg/resp"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errExpireNXAndOthers = "ERR NX and XX, GT or LT options at the same time are not compatible"
+	errExpireGTAndLT     = "ERR GT and LT options at the same time are not compatible"
+)
+
+func errInvalidExpireTime(command string) string {
+	return fmt.Sprintf("ERR invalid expire time in '%s' command", command)
+}
+
+// expiryUnixMilli converts the value of an EX, PX, EXAT or PXAT style
+// option into an absolute expiry in Unix milliseconds. ok is false when the
+// result does not fit in 64 bits.
+func expiryUnixMilli(unit string, value int64) (at int64, ok bool) {
+	if unit == "EX" || unit == "EXAT" {
+		if value > math.MaxInt64/1000 || value < math.MinInt64/1000 {
+			return 0, false
+		}
+		value *= 1000
+	}
+	if unit == "EX" || unit == "PX" {
+		now := time.Now().UnixMilli()
+		if value > math.MaxInt64-now {
+			return 0, false
+		}
+		value += now
+	}
+	return value, true
+}
+
+func handleExpire(db *Database, args []resp.Value) resp.Value {
+	return expireCommand(db, args, "EX", "expire")
+}
+
+func handlePExpire(db *Database, args []resp.Value) resp.Value {
+	return expireCommand(db, args, "PX", "pexpire")
+}
+
+func handleExpireAt(db *Database, args []resp.Value) resp.Value {
+	return expireCommand(db, args, "EXAT", "expireat")
+}
+
+func handlePExpireAt(db *Database, args []resp.Value) resp.Value {
+	return expireCommand(db, args, "PXAT", "pexpireat")
+}
+
+// expireCommand backs the EXPIRE family; unit says how the time argument
+// is to be read, as in the SET options.
+func expireCommand(db *Database, args []resp.Value, unit string, name string) resp.Value {
+	key := args[0].Bulk
+	value, err := strconv.ParseInt(args[1].Bulk, 10, 64)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	at, ok := expiryUnixMilli(unit, value)
+	if !ok {
+		return resp.Value{DataType: resp.TypeError, Err: errInvalidExpireTime(name)}
+	}
+
+	var nx, xx, gt, lt bool
+	for _, arg := range args[2:] {
+		switch strings.ToUpper(arg.Bulk) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		default:
+			return resp.Value{DataType: resp.TypeError, Err: "ERR Unsupported option " + arg.Bulk}
+		}
+	}
+	if nx && (xx || gt || lt) {
+		return resp.Value{DataType: resp.TypeError, Err: errExpireNXAndOthers}
+	}
+	if gt && lt {
+		return resp.Value{DataType: resp.TypeError, Err: errExpireGTAndLT}
+	}
+
+	record, exists := db.lookupKey(key)
+	if !exists {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+
+	// A key without a TTL counts as expiring infinitely far in the future
+	current := record.ExpiryTime
+	if (nx && current != nil) ||
+		(xx && current == nil) ||
+		(gt && (current == nil || at <= current.UnixMilli())) ||
+		(lt && current != nil && at >= current.UnixMilli()) {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+
+	// An expiry in the past deletes the key straight away
+	if at <= time.Now().UnixMilli() {
+		db.deleteKey(key)
+		return resp.Value{DataType: resp.TypeInteger, Num: 1}
+	}
+
+	expiryTime := time.UnixMilli(at)
+	record.ExpiryTime = &expiryTime
+	db.storeRecord(key, record)
+	return resp.Value{DataType: resp.TypeInteger, Num: 1}
+}
+
+func handleTTL(db *Database, args []resp.Value) resp.Value {
+	return ttlCommand(db, args, false, false)
+}
+
+func handlePTTL(db *Database, args []resp.Value) resp.Value {
+	return ttlCommand(db, args, true, false)
+}
+
+func handleExpireTime(db *Database, args []resp.Value) resp.Value {
+	return ttlCommand(db, args, false, true)
+}
+
+func handlePExpireTime(db *Database, args []resp.Value) resp.Value {
+	return ttlCommand(db, args, true, true)
+}
+
+// ttlCommand backs TTL, PTTL, EXPIRETIME and PEXPIRETIME, replying with -2
+// for a missing key and -1 for a key without an expiry.
+func ttlCommand(db *Database, args []resp.Value, milliseconds bool, absolute bool) resp.Value {
+	record, exists := db.lookupKey(args[0].Bulk)
+	if !exists {
+		return resp.Value{DataType: resp.TypeInteger, Num: -2}
+	}
+	if record.ExpiryTime == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: -1}
+	}
+
+	at := record.ExpiryTime.UnixMilli()
+	if absolute {
+		if !milliseconds {
+			at /= 1000
+		}
+		return resp.Value{DataType: resp.TypeInteger, Num: at}
+	}
+
+	remaining := max(at-time.Now().UnixMilli(), 0)
+	if !milliseconds {
+		remaining = (remaining + 500) / 1000
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: remaining}
+}
+
+func handlePersist(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	record, exists := db.lookupKey(key)
+	if !exists || record.ExpiryTime == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	record.ExpiryTime = nil
+	db.storeRecord(key, record)
+	return resp.Value{DataType: resp.TypeInteger, Num: 1}
+}
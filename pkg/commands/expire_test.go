@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpireAndTTL(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("key", "v"))
+
+	if reply := handleTTL(db, bulkValues("missing")); reply.Num != -2 {
+		t.Fatalf("expected -2 for a missing key, got %+v", reply)
+	}
+	if reply := handlePTTL(db, bulkValues("missing")); reply.Num != -2 {
+		t.Fatalf("expected -2 for a missing key, got %+v", reply)
+	}
+	if reply := handleTTL(db, bulkValues("key")); reply.Num != -1 {
+		t.Fatalf("expected -1 for a key without a TTL, got %+v", reply)
+	}
+	if reply := handlePTTL(db, bulkValues("key")); reply.Num != -1 {
+		t.Fatalf("expected -1 for a key without a TTL, got %+v", reply)
+	}
+
+	if reply := handleExpire(db, bulkValues("key", "100")); reply.Num != 1 {
+		t.Fatalf("expected 1, got %+v", reply)
+	}
+	if reply := handleTTL(db, bulkValues("key")); reply.Num != 100 {
+		t.Fatalf("expected 100, got %+v", reply)
+	}
+	if reply := handlePExpire(db, bulkValues("key", "5000")); reply.Num != 1 {
+		t.Fatalf("expected 1, got %+v", reply)
+	}
+	if reply := handlePTTL(db, bulkValues("key")); reply.Num <= 4000 || reply.Num > 5000 {
+		t.Fatalf("expected a PTTL close to 5000, got %+v", reply)
+	}
+
+	at := time.Now().Add(time.Hour).Unix()
+	if reply := handleExpireAt(db, bulkValues("key", strconv.FormatInt(at, 10))); reply.Num != 1 {
+		t.Fatalf("expected 1, got %+v", reply)
+	}
+	if reply := handleExpireTime(db, bulkValues("key")); reply.Num != at {
+		t.Fatalf("expected %d, got %+v", at, reply)
+	}
+	if reply := handlePExpireAt(db, bulkValues("key", strconv.FormatInt(at*1000+500, 10))); reply.Num != 1 {
+		t.Fatalf("expected 1, got %+v", reply)
+	}
+	if reply := handlePExpireTime(db, bulkValues("key")); reply.Num != at*1000+500 {
+		t.Fatalf("expected %d, got %+v", at*1000+500, reply)
+	}
+
+	if reply := handlePersist(db, bulkValues("key")); reply.Num != 1 {
+		t.Fatalf("expected 1, got %+v", reply)
+	}
+	if reply := handleTTL(db, bulkValues("key")); reply.Num != -1 {
+		t.Fatalf("expected PERSIST to drop the TTL, got %+v", reply)
+	}
+	if reply := handlePersist(db, bulkValues("key")); reply.Num != 0 {
+		t.Fatalf("expected 0 for a key without a TTL, got %+v", reply)
+	}
+	if reply := handlePersist(db, bulkValues("missing")); reply.Num != 0 {
+		t.Fatalf("expected 0 for a missing key, got %+v", reply)
+	}
+	if reply := handleExpire(db, bulkValues("missing", "100")); reply.Num != 0 {
+		t.Fatalf("expected 0 for a missing key, got %+v", reply)
+	}
+}
+
+func TestExpireInThePastDeletes(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	testCases := []struct {
+		name    string
+		handler func(*Database, []resp.Value) resp.Value
+		value   string
+	}{
+		{"EXPIRE", handleExpire, "-1"},
+		{"PEXPIRE", handlePExpire, "0"},
+		{"EXPIREAT", handleExpireAt, "1"},
+		{"PEXPIREAT", handlePExpireAt, strconv.FormatInt(time.Now().Add(-time.Second).UnixMilli(), 10)},
+	}
+	for _, tc := range testCases {
+		handleSet(db, bulkValues("key", "v"))
+		if reply := tc.handler(db, bulkValues("key", tc.value)); reply.Num != 1 {
+			t.Errorf("%s: expected 1, got %+v", tc.name, reply)
+		}
+		if reply := handleTTL(db, bulkValues("key")); reply.Num != -2 {
+			t.Errorf("%s: expected the key to be deleted, got %+v", tc.name, reply)
+		}
+	}
+}
+
+func TestExpireFlags(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("key", "v"))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected int64
+		ttl      int64
+	}{
+		{"XX Without A TTL", []string{"key", "100", "XX"}, 0, -1},
+		{"GT Without A TTL", []string{"key", "100", "GT"}, 0, -1},
+		{"LT Without A TTL", []string{"key", "100", "LT"}, 1, 100},
+		{"NX With A TTL", []string{"key", "200", "NX"}, 0, 100},
+		{"XX With A TTL", []string{"key", "200", "XX"}, 1, 200},
+		{"GT With A Lower TTL", []string{"key", "150", "GT"}, 0, 200},
+		{"GT With A Higher TTL", []string{"key", "300", "gt"}, 1, 300},
+		{"LT With A Higher TTL", []string{"key", "400", "LT"}, 0, 300},
+		{"LT With A Lower TTL", []string{"key", "50", "LT"}, 1, 50},
+		{"XX And GT", []string{"key", "60", "XX", "GT"}, 1, 60},
+	}
+	for _, tc := range testCases {
+		if reply := handleExpire(db, bulkValues(tc.args...)); reply.Num != tc.expected {
+			t.Errorf("%s: expected %d, got %+v", tc.name, tc.expected, reply)
+		}
+		if reply := handleTTL(db, bulkValues("key")); reply.Num != tc.ttl {
+			t.Errorf("%s: expected a TTL of %d, got %+v", tc.name, tc.ttl, reply)
+		}
+	}
+
+	handlePersist(db, bulkValues("key"))
+	if reply := handleExpire(db, bulkValues("key", "100", "NX")); reply.Num != 1 {
+		t.Fatalf("expected NX to set a TTL on a key without one, got %+v", reply)
+	}
+
+	errorCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{"NX And XX", []string{"key", "10", "NX", "XX"}, errExpireNXAndOthers},
+		{"NX And GT", []string{"key", "10", "NX", "GT"}, errExpireNXAndOthers},
+		{"GT And LT", []string{"key", "10", "GT", "LT"}, errExpireGTAndLT},
+		{"Unknown Option", []string{"key", "10", "KEEP"}, "ERR Unsupported option KEEP"},
+		{"Not A Number", []string{"key", "x"}, errNotInteger},
+		{"Overflow", []string{"key", "9223372036854775807"}, errInvalidExpireTime("expire")},
+	}
+	for _, tc := range errorCases {
+		if reply := handleExpire(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+			t.Errorf("%s: expected %q, got %+v", tc.name, tc.expectedErr, reply)
+		}
+	}
+	if reply := handleTTL(db, bulkValues("key")); reply.Num != 100 {
+		t.Fatalf("a rejected EXPIRE changed the TTL: %+v", reply)
+	}
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"sync"
+	"time"
+)
+
+// A keyspaceFork is a point-in-time view of the keyspace that BGSAVE and
+// BGREWRITEAOF encode while clients keep writing, much like the child
+// process Redis forks for them. Taking one only records which map holds each
+// database, so it costs next to nothing. The fork then walks the maps a key
+// at a time under the shared keyspace lock, which holds writers up for no
+// longer than one key takes to encode.
+//
+// It stays a point-in-time view by copy-on-write: before a writer changes a
+// key the fork has not reached yet, the key is preserved as it was, its
+// value cloned if it is about to be modified in place, and the fork encodes
+// the preserved record instead of the live one. Memory can therefore grow
+// by the size of every key written while a fork runs, as it does in Redis,
+// and the fork also remembers the keys it has walked in the database it is
+// walking.
+type keyspaceFork struct {
+	// maps holds the data of every database as of the fork, by number.
+	// SWAPDB and FLUSHDB replace the map a Database holds rather than
+	// changing it, so keys are told apart by their map, not their Database.
+	maps  []*sync.Map
+	index map[*sync.Map]int
+	now   time.Time
+
+	// mu guards the rest. Keys expire while the keyspace lock is shared, so
+	// the lock alone does not.
+	mu sync.Mutex
+	// walking is the position in maps of the database being walked. The
+	// ones before it are done with, so changes to them need not be
+	// preserved.
+	walking int
+	// visited holds the keys already walked in maps[walking].
+	visited   map[string]struct{}
+	preserved map[forkKey]preservedRecord
+}
+
+type forkKey struct {
+	data *sync.Map
+	key  string
+}
+
+// preservedRecord is a key as it was when the fork was taken; exists is
+// false for a key created since.
+type preservedRecord struct {
+	record Record
+	exists bool
+}
+
+// forks are the forks in progress. It only changes while the keyspace lock
+// is held exclusively, so holding the lock either way is enough to read it.
+var forks []*keyspaceFork
+
+// forkKeyspace takes a fork of the keyspace as it is now. The caller holds
+// the keyspace lock exclusively and then calls walk, which may run on
+// another goroutine once the lock is released.
+func forkKeyspace() *keyspaceFork {
+	f := &keyspaceFork{
+		maps:      make([]*sync.Map, len(databases)),
+		index:     make(map[*sync.Map]int, len(databases)),
+		now:       time.Now(),
+		visited:   make(map[string]struct{}),
+		preserved: make(map[forkKey]preservedRecord),
+	}
+	for i, db := range databases {
+		f.maps[i] = db.data
+		f.index[db.data] = i
+	}
+	forks = append(forks, f)
+	return f
+}
+
+// beforeChange preserves the key about to change in data for every fork
+// that has not walked it yet. record is what the key holds, if it exists.
+// modify is set when the value is about to be modified in place, which
+// makes the fork keep a clone of it; a value that is replaced or deleted
+// instead is no longer reachable from the keyspace, so it is kept as is.
+func beforeChange(data *sync.Map, key string, record Record, exists, modify bool) {
+	for _, f := range forks {
+		f.preserve(data, key, record, exists, modify)
+	}
+}
+
+func (f *keyspaceFork) preserve(data *sync.Map, key string, record Record, exists, modify bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, ok := f.index[data]
+	if !ok || i < f.walking {
+		return
+	}
+	if _, ok := f.visited[key]; ok && i == f.walking {
+		return
+	}
+	k := forkKey{data, key}
+	if _, ok := f.preserved[k]; ok {
+		return
+	}
+	if exists && modify {
+		record = record.clone()
+	}
+	f.preserved[k] = preservedRecord{record: record, exists: exists}
+}
+
+// walk calls each with every key of the fork that had not expired when it
+// was taken, database by database, and then retires the fork. The caller
+// must not hold the keyspace lock, which walk takes for each key in turn;
+// each must not change the keyspace.
+func (f *keyspaceFork) walk(each func(db int, key string, record Record)) {
+	for i, data := range f.maps {
+		data.Range(func(k, _ any) bool {
+			key := k.(string)
+			keyspaceMu.RLock()
+			defer keyspaceMu.RUnlock()
+
+			f.mu.Lock()
+			f.visited[key] = struct{}{}
+			saved, preserved := f.preserved[forkKey{data, key}]
+			delete(f.preserved, forkKey{data, key})
+			f.mu.Unlock()
+
+			if !preserved {
+				// A key that expires while it is being walked is
+				// deleted without being preserved, and so left out
+				value, ok := data.Load(key)
+				if !ok {
+					return true
+				}
+				saved = preservedRecord{record: value.(Record), exists: true}
+			}
+			if saved.exists && !saved.record.isExpired(f.now) {
+				each(i, key, saved.record)
+			}
+			return true
+		})
+
+		// What is still preserved was deleted before the walk reached it.
+		// Nothing else refers to it, so it is read without the lock.
+		f.mu.Lock()
+		var deleted []forkKey
+		var records []Record
+		for k, saved := range f.preserved {
+			if k.data != data {
+				continue
+			}
+			if saved.exists {
+				deleted = append(deleted, k)
+				records = append(records, saved.record)
+			}
+			delete(f.preserved, k)
+		}
+		f.walking = i + 1
+		f.visited = make(map[string]struct{})
+		f.mu.Unlock()
+		for j, k := range deleted {
+			if !records[j].isExpired(f.now) {
+				each(i, k.key, records[j])
+			}
+		}
+	}
+
+	keyspaceMu.Lock()
+	for i, other := range forks {
+		if other == f {
+			forks = append(forks[:i], forks[i+1:]...)
+			break
+		}
+	}
+	keyspaceMu.Unlock()
+}
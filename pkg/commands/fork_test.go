@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// forkContents walks f and describes each key it holds as "db:key" mapped
+// to its value, with the elements of a list or set joined by commas.
+func forkContents(f *keyspaceFork) map[string]string {
+	contents := map[string]string{}
+	f.walk(func(db int, key string, record Record) {
+		var value string
+		switch record.Type {
+		case TypeString:
+			value = stringValue(record)
+		case TypeList:
+			list := record.Value.(*deque)
+			elements := make([]string, list.len())
+			for i := range elements {
+				elements[i] = list.at(i)
+			}
+			value = strings.Join(elements, ",")
+		case TypeSet:
+			members := slices.Clone(record.Value.(*stringSet).keys)
+			slices.Sort(members)
+			value = strings.Join(members, ",")
+		}
+		contents[strconv.Itoa(db)+":"+key] = value
+	})
+	return contents
+}
+
+func TestForkIsPointInTime(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleRPush(db, bulkValues("list", "a", "b"))
+	handleSAdd(db, bulkValues("set", "m1"))
+	handleSet(db, bulkValues("deleted", "x"))
+	handleSet(db, bulkValues("replaced", "old"))
+	handleSet(databases[1], bulkValues("flushed", "x"))
+	handleSet(databases[2], bulkValues("swapped", "2"))
+
+	f := forkKeyspace()
+	lockKeyspace()
+	handleRPush(db, bulkValues("list", "c"))
+	handleSAdd(db, bulkValues("set", "m2"))
+	handleDelete(db, bulkValues("deleted"))
+	handleSet(db, bulkValues("replaced", "new"))
+	handleSet(db, bulkValues("created", "x"))
+	handleFlushDB(databases[1], nil)
+	handleSwapDB(db, bulkValues("2", "3"))
+	handleSet(databases[3], bulkValues("swapped", "changed"))
+	unlockKeyspace()
+
+	expected := map[string]string{
+		"0:list":     "a,b",
+		"0:set":      "m1",
+		"0:deleted":  "x",
+		"0:replaced": "old",
+		"1:flushed":  "x",
+		"2:swapped":  "2",
+	}
+	contents := forkContents(f)
+	if len(contents) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, contents)
+	}
+	for key, value := range expected {
+		if contents[key] != value {
+			t.Fatalf("expected %s to be %q, got %q", key, value, contents[key])
+		}
+	}
+	if len(forks) != 0 {
+		t.Fatal("expected the fork to be retired once walked")
+	}
+
+	// Writes after the walk are not preserved for it
+	handleRPush(db, bulkValues("list", "d"))
+	if reply := handleLLen(db, bulkValues("list")); reply.Num != 4 {
+		t.Fatalf("expected the live list to hold 4 elements, got %d", reply.Num)
+	}
+}
+
+func TestForkWhileWriting(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	for i := 0; i < 1000; i++ {
+		handleRPush(db, bulkValues("list:"+strconv.Itoa(i), "a"))
+	}
+
+	keyspaceMu.Lock()
+	f := forkKeyspace()
+	keyspaceMu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 999; i >= 0; i-- {
+			lockKeyspace()
+			handleRPush(db, bulkValues("list:"+strconv.Itoa(i), "b"))
+			handleDelete(db, bulkValues("list:"+strconv.Itoa(i/2)))
+			unlockKeyspace()
+		}
+	}()
+	contents := forkContents(f)
+	wg.Wait()
+
+	if len(contents) != 1000 {
+		t.Fatalf("expected the 1000 keys as of the fork, got %d", len(contents))
+	}
+	for key, value := range contents {
+		if value != "a" {
+			t.Fatalf("expected %s to be as of the fork, got %q", key, value)
+		}
+	}
+}
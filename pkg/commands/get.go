@@ -2,24 +2,17 @@ package commands
 
 import (
 	"go-redis/pkg/resp"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func handleGet(args []resp.Value) resp.Value {
-	if len(args) != 1 {
-		return resp.Value{DataType: resp.TypeError, Err: errWrongArgsCount}
-	}
+func handleGet(db *Database, args []resp.Value) resp.Value {
 	key := args[0].Bulk
-	if record, ok := dataSet.Load(key); ok {
-		r := record.(Record)
-		if r.ExpiryTime != nil && r.ExpiryTime.Before(time.Now()) {
-			dataSet.Delete(key)
-			return resp.Value{DataType: resp.TypeNull, IsNull: true}
-		}
-
+	if r, ok := db.lookupKey(key); ok {
 		switch r.Type {
 		case TypeString:
-			return resp.Value{DataType: resp.TypeBulk, Bulk: r.Value.(string)}
+			return resp.Value{DataType: resp.TypeBulk, Bulk: stringValue(r)}
 		case TypeList, TypeSet, TypeZSet, TypeHash:
 			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
 		default:
@@ -28,3 +21,89 @@ func handleGet(args []resp.Value) resp.Value {
 	}
 	return resp.Value{DataType: resp.TypeNull, IsNull: true}
 }
+
+// stringValue returns the value of a string record. The bitmap commands
+// store the strings they write as a []byte they modify in place, while
+// every other command stores a string.
+func stringValue(record Record) string {
+	if bitmap, ok := record.Value.([]byte); ok {
+		return string(bitmap)
+	}
+	return record.Value.(string)
+}
+
+// loadString returns the string stored at key. exists is false for a
+// missing key, while a key holding another type reports wrongType.
+func (db *Database) loadString(key string) (value string, exists bool, wrongType bool) {
+	r, ok := db.lookupKey(key)
+	if !ok {
+		return "", false, false
+	}
+	if r.Type != TypeString {
+		return "", false, true
+	}
+	return stringValue(r), true, false
+}
+
+func handleGetDel(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	value, exists, wrongType := db.loadString(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if !exists {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+	db.deleteKey(key)
+	return resp.Value{DataType: resp.TypeBulk, Bulk: value}
+}
+
+func handleGetEx(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	var expiry *time.Time
+	persist := false
+	options := args[1:]
+	switch {
+	case len(options) == 0:
+	case len(options) == 1 && strings.ToUpper(options[0].Bulk) == "PERSIST":
+		persist = true
+	case len(options) == 2:
+		unit := strings.ToUpper(options[0].Bulk)
+		if unit != "EX" && unit != "PX" && unit != "EXAT" && unit != "PXAT" {
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+		value, err := strconv.ParseInt(options[1].Bulk, 10, 64)
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+		}
+		at, ok := expiryUnixMilli(unit, value)
+		if !ok || value <= 0 {
+			return resp.Value{DataType: resp.TypeError, Err: errInvalidExpireTime("getex")}
+		}
+		expiryTime := time.UnixMilli(at)
+		expiry = &expiryTime
+	default:
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+
+	record, exists := db.lookupKey(key)
+	if !exists {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+	if record.Type != TypeString {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	reply := resp.Value{DataType: resp.TypeBulk, Bulk: stringValue(record)}
+	switch {
+	case expiry != nil && !expiry.After(time.Now()):
+		db.deleteKey(key)
+	case expiry != nil:
+		record.ExpiryTime = expiry
+		db.storeRecord(key, record)
+	case persist && record.ExpiryTime != nil:
+		record.ExpiryTime = nil
+		db.storeRecord(key, record)
+	}
+	return reply
+}
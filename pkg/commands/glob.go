@@ -0,0 +1,95 @@
+package commands
+
+// globMatch reports whether s matches the glob-style pattern the way Redis
+// matches channel patterns and KEYS patterns:
+//
+//   - * matches any sequence of characters, including none
+//   - ? matches any single character
+//   - [abc] matches one of the listed characters; ranges such as [a-z] and
+//     negation with [^abc] are supported
+//   - \x matches x literally
+//
+// Patterns come from clients, so the match never recurses: on a mismatch
+// it only backtracks to the most recent star, which keeps it to
+// O(len(pattern) * len(s)) however many stars the pattern holds.
+func globMatch(pattern, s string) bool {
+	var starPattern, starS string
+	hasStar := false
+	for {
+		if len(pattern) > 0 && pattern[0] == '*' {
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			starPattern, starS, hasStar = pattern, s, true
+			continue
+		}
+		if len(pattern) == 0 && len(s) == 0 {
+			return true
+		}
+		if len(pattern) > 0 && len(s) > 0 {
+			if matched, rest := matchToken(pattern, s[0]); matched {
+				pattern, s = rest, s[1:]
+				continue
+			}
+		}
+
+		// Let the last star swallow one more character and retry from there
+		if !hasStar || len(starS) == 0 {
+			return false
+		}
+		starS = starS[1:]
+		pattern, s = starPattern, starS
+	}
+}
+
+// matchToken matches c against the single-character token that starts the
+// pattern, returning the pattern that follows the token.
+func matchToken(pattern string, c byte) (bool, string) {
+	switch pattern[0] {
+	case '?':
+		return true, pattern[1:]
+	case '[':
+		return matchClass(pattern[1:], c)
+	case '\\':
+		if len(pattern) >= 2 {
+			pattern = pattern[1:]
+		}
+	}
+	return pattern[0] == c, pattern[1:]
+}
+
+// matchClass matches c against the character class starting right after
+// its opening bracket, returning the pattern that follows the class. An
+// unterminated class runs to the end of the pattern.
+func matchClass(pattern string, c byte) (bool, string) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			pattern = pattern[1:]
+			matched = matched || pattern[0] == c
+		case len(pattern) >= 3 && pattern[1] == '-':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			matched = matched || (c >= start && c <= end)
+			pattern = pattern[2:]
+		default:
+			matched = matched || pattern[0] == c
+		}
+		pattern = pattern[1:]
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return matched != negate, pattern
+}
@@ -0,0 +1,373 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	errHashNotInteger = "ERR hash value is not an integer"
+	errHashNotFloat   = "ERR hash value is not a float"
+	errNotFloat       = "ERR value is not a valid float"
+	errOverflow       = "ERR increment or decrement would overflow"
+	errNaNOrInfinity  = "ERR increment would produce NaN or Infinity"
+	errOutOfRange     = "ERR value is out of range"
+)
+
+// loadHash returns the hash stored at key. A missing key yields a nil dict,
+// while a key holding another type reports wrongType.
+func (db *Database) loadHash(key string) (hash *dict[string], wrongType bool) {
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return nil, false
+	}
+	if record.Type != TypeHash {
+		return nil, true
+	}
+	return record.Value.(*dict[string]), false
+}
+
+// loadOrCreateHash is like loadHash but creates and stores an empty hash
+// when the key does not exist yet.
+func (db *Database) loadOrCreateHash(key string) (hash *dict[string], wrongType bool) {
+	hash, wrongType = db.loadHash(key)
+	if wrongType || hash != nil {
+		return hash, wrongType
+	}
+	hash = newDict[string](0)
+	db.storeRecord(key, Record{Type: TypeHash, Value: hash})
+	return hash, false
+}
+
+func handleHSet(db *Database, args []resp.Value) resp.Value {
+	return hashSet(db, "HSET", args)
+}
+
+func handleHMSet(db *Database, args []resp.Value) resp.Value {
+	result := hashSet(db, "HMSET", args)
+	if result.DataType == resp.TypeError {
+		return result
+	}
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+// hashSet backs HSET and HMSET, named by name, returning the number of
+// fields added.
+func hashSet(db *Database, name string, args []resp.Value) resp.Value {
+	if len(args)%2 != 1 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError(name)}
+	}
+
+	hash, wrongType := db.loadOrCreateHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		if hash.set(args[i].Bulk, args[i+1].Bulk) {
+			added++
+		}
+	}
+	signalModified()
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(added)}
+}
+
+func handleHSetNX(db *Database, args []resp.Value) resp.Value {
+	hash, wrongType := db.loadOrCreateHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	field := args[1].Bulk
+	if hash.has(field) {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	hash.set(field, args[2].Bulk)
+	signalModified()
+	return resp.Value{DataType: resp.TypeInteger, Num: 1}
+}
+
+func handleHGet(db *Database, args []resp.Value) resp.Value {
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	value, ok := hash.get(args[1].Bulk)
+	if !ok {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+	return resp.Value{DataType: resp.TypeBulk, Bulk: value}
+}
+
+func handleHMGet(db *Database, args []resp.Value) resp.Value {
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	result := make([]resp.Value, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		if value, ok := hash.get(arg.Bulk); ok {
+			result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: value})
+		} else {
+			result = append(result, resp.Value{DataType: resp.TypeNull, IsNull: true})
+		}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
+
+func handleHDel(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	hash, wrongType := db.loadHash(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	deleted := 0
+	for _, arg := range args[1:] {
+		if hash.delete(arg.Bulk) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		signalModified()
+	}
+
+	// Empty hashes are never kept around
+	if hash != nil && hash.len() == 0 {
+		db.deleteKey(key)
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(deleted)}
+}
+
+func handleHExists(db *Database, args []resp.Value) resp.Value {
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	if hash.has(args[1].Bulk) {
+		return resp.Value{DataType: resp.TypeInteger, Num: 1}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: 0}
+}
+
+func handleHLen(db *Database, args []resp.Value) resp.Value {
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(hash.len())}
+}
+
+func handleHStrLen(db *Database, args []resp.Value) resp.Value {
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	value, _ := hash.get(args[1].Bulk)
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(len(value))}
+}
+
+func handleHKeys(db *Database, args []resp.Value) resp.Value {
+	return hashGetAll(db, args, true, false)
+}
+
+func handleHVals(db *Database, args []resp.Value) resp.Value {
+	return hashGetAll(db, args, false, true)
+}
+
+func handleHGetAll(db *Database, args []resp.Value) resp.Value {
+	return hashGetAll(db, args, true, true)
+}
+
+// hashGetAll backs HKEYS, HVALS and HGETALL, emitting fields, values or
+// both as a flat array. Fields and values together make a map.
+func hashGetAll(db *Database, args []resp.Value, withFields, withValues bool) resp.Value {
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	result := make([]resp.Value, 0, hash.len()*2)
+	for i := range hash.len() {
+		if withFields {
+			result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: hash.keys[i]})
+		}
+		if withValues {
+			result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: hash.values[i]})
+		}
+	}
+	if withFields && withValues {
+		return resp.Value{DataType: resp.TypeMap, Array: result}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
+
+func handleHIncrBy(db *Database, args []resp.Value) resp.Value {
+	increment, err := strconv.ParseInt(args[2].Bulk, 10, 64)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	field := args[1].Bulk
+	var value int64
+	if current, ok := hash.get(field); ok {
+		value, err = strconv.ParseInt(current, 10, 64)
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: errHashNotInteger}
+		}
+	}
+
+	if (increment > 0 && value > math.MaxInt64-increment) ||
+		(increment < 0 && value < math.MinInt64-increment) {
+		return resp.Value{DataType: resp.TypeError, Err: errOverflow}
+	}
+	value += increment
+	if hash == nil {
+		hash, _ = db.loadOrCreateHash(args[0].Bulk)
+	}
+	hash.set(field, strconv.FormatInt(value, 10))
+	signalModified()
+	return resp.Value{DataType: resp.TypeInteger, Num: value}
+}
+
+func handleHIncrByFloat(db *Database, args []resp.Value) resp.Value {
+	increment, err := parseFloat(args[2].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotFloat}
+	}
+
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	field := args[1].Bulk
+	var value float64
+	if current, ok := hash.get(field); ok {
+		value, err = parseFloat(current)
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: errHashNotFloat}
+		}
+	}
+
+	value += increment
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return resp.Value{DataType: resp.TypeError, Err: errNaNOrInfinity}
+	}
+	formatted := formatFloat(value)
+	// The key is only created once the increment is known to succeed, so a
+	// failed one never leaves an empty hash behind
+	if hash == nil {
+		hash, _ = db.loadOrCreateHash(args[0].Bulk)
+	}
+	hash.set(field, formatted)
+	signalModified()
+	return resp.Value{DataType: resp.TypeBulk, Bulk: formatted}
+}
+
+func handleHRandField(db *Database, args []resp.Value) resp.Value {
+	if len(args) > 3 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError("HRANDFIELD")}
+	}
+
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	// Without a count a single field is returned as a bulk string
+	if len(args) == 1 {
+		if hash.len() == 0 {
+			return resp.Value{DataType: resp.TypeNull, IsNull: true}
+		}
+		return resp.Value{DataType: resp.TypeBulk, Bulk: hash.keys[rand.IntN(hash.len())]}
+	}
+
+	count, errMsg := parseRandomCount(args[1].Bulk)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	withValues := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2].Bulk) != "WITHVALUES" {
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+		withValues = true
+	}
+
+	picked := hash.sample(count)
+	result := make([]resp.Value, 0, len(picked)*2)
+	for _, i := range picked {
+		result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: hash.keys[i]})
+		if withValues {
+			result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: hash.values[i]})
+		}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
+
+// parseRandomCount parses the count of a random sampling command. Counts
+// beyond half the int64 range are rejected like Redis does, which also
+// keeps -count from overflowing.
+func parseRandomCount(s string) (int64, string) {
+	count, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	if count < -math.MaxInt64/2 || count > math.MaxInt64/2 {
+		return 0, errOutOfRange
+	}
+	return count, ""
+}
+
+// parseFloat parses a float argument the way Redis does, accepting the
+// inf/-inf spellings but rejecting NaN.
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) {
+		return 0, strconv.ErrSyntax
+	}
+	return f, nil
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func handleHScan(db *Database, args []resp.Value) resp.Value {
+	opts, errMsg := parseScanArgs(args[1:], false, true)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	hash, wrongType := db.loadHash(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	value := func(value string) string { return value }
+	if opts.noValues {
+		value = nil
+	}
+	return scanDict(hash, opts, value)
+}
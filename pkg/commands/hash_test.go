@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"testing"
+)
+
+func TestHSetHGetAndHDel(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	if reply := handleHSet(db, bulkValues("hash", "a", "1", "b", "2")); reply.Num != 2 {
+		t.Fatalf("expected 2 fields added, got %+v", reply)
+	}
+	if reply := handleHSet(db, bulkValues("hash", "a", "10", "c", "3")); reply.Num != 1 {
+		t.Fatalf("expected only the new field to count, got %+v", reply)
+	}
+	if reply := handleHGet(db, bulkValues("hash", "a")); reply.Bulk != "10" {
+		t.Fatalf("expected the overwritten value, got %+v", reply)
+	}
+	if reply := handleHGet(db, bulkValues("hash", "missing")); !reply.IsNull {
+		t.Fatalf("expected a null reply for a missing field, got %+v", reply)
+	}
+	if reply := handleHGet(db, bulkValues("missing", "a")); !reply.IsNull {
+		t.Fatalf("expected a null reply for a missing key, got %+v", reply)
+	}
+
+	if reply := handleHDel(db, bulkValues("hash", "a", "missing")); reply.Num != 1 {
+		t.Fatalf("expected 1 field deleted, got %+v", reply)
+	}
+	handleHDel(db, bulkValues("hash", "b", "c"))
+	if reply := handleExists(db, bulkValues("hash")); reply.Num != 0 {
+		t.Fatal("deleting the last field left an empty hash behind")
+	}
+
+	handleSet(db, bulkValues("str", "v"))
+	for _, reply := range []resp.Value{
+		handleHSet(db, bulkValues("str", "a", "1")),
+		handleHGet(db, bulkValues("str", "a")),
+		handleHDel(db, bulkValues("str", "a")),
+		handleHIncrBy(db, bulkValues("str", "a", "1")),
+		handleHIncrByFloat(db, bulkValues("str", "a", "1")),
+		handleHRandField(db, bulkValues("str")),
+		handleHScan(db, bulkValues("str", "0")),
+	} {
+		if reply.Err != errWrongType {
+			t.Errorf("expected %q, got %+v", errWrongType, reply)
+		}
+	}
+	if reply := handleHSet(db, bulkValues("hash", "a")); reply.Err != wrongArityError("HSET") {
+		t.Fatalf("expected %q, got %+v", wrongArityError("HSET"), reply)
+	}
+}
+
+func TestHIncrBy(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleHSet(db, bulkValues("hash", "n", "5", "s", "abc", "max", "9223372036854775807", "f", "1.5"))
+
+	if reply := handleHIncrBy(db, bulkValues("hash", "n", "-7")); reply.Num != -2 {
+		t.Fatalf("expected -2, got %+v", reply)
+	}
+	if reply := handleHIncrByFloat(db, bulkValues("hash", "f", "0.25")); reply.Bulk != "1.75" {
+		t.Fatalf("expected 1.75, got %+v", reply)
+	}
+	if reply := handleHIncrByFloat(db, bulkValues("hash", "n", "1e3")); reply.Bulk != "998" {
+		t.Fatalf("expected 998, got %+v", reply)
+	}
+
+	testCases := []struct {
+		name        string
+		reply       resp.Value
+		expectedErr string
+	}{
+		{"Not An Integer Field", handleHIncrBy(db, bulkValues("hash", "s", "1")), errHashNotInteger},
+		{"Float Field", handleHIncrBy(db, bulkValues("hash", "f", "1")), errHashNotInteger},
+		{"Not An Integer Increment", handleHIncrBy(db, bulkValues("hash", "n", "1.5")), errNotInteger},
+		{"Overflow", handleHIncrBy(db, bulkValues("hash", "max", "1")), errOverflow},
+		{"Not A Float Field", handleHIncrByFloat(db, bulkValues("hash", "s", "1")), errHashNotFloat},
+		{"Not A Float Increment", handleHIncrByFloat(db, bulkValues("hash", "f", "x")), errNotFloat},
+		{"Infinity", handleHIncrByFloat(db, bulkValues("hash", "f", "inf")), errNaNOrInfinity},
+	}
+	for _, tc := range testCases {
+		if tc.reply.Err != tc.expectedErr {
+			t.Errorf("%s: expected %q, got %+v", tc.name, tc.expectedErr, tc.reply)
+		}
+	}
+	if reply := handleHGet(db, bulkValues("hash", "max")); reply.Bulk != "9223372036854775807" {
+		t.Fatalf("an overflowing HINCRBY changed the field: %+v", reply)
+	}
+}
+
+func TestHRandField(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	if reply := handleHRandField(db, bulkValues("missing")); !reply.IsNull {
+		t.Fatalf("expected a null reply for a missing key, got %+v", reply)
+	}
+	if reply := handleHRandField(db, bulkValues("missing", "3")); reply.DataType != resp.TypeArray || len(reply.Array) != 0 {
+		t.Fatalf("expected an empty array for a missing key, got %+v", reply)
+	}
+
+	handleHSet(db, bulkValues("hash", "a", "1", "b", "2", "c", "3"))
+	if reply := handleHRandField(db, bulkValues("hash")); reply.Bulk == "" {
+		t.Fatalf("expected a field, got %+v", reply)
+	}
+	reply := handleHRandField(db, bulkValues("hash", "2", "WITHVALUES"))
+	if len(reply.Array) != 4 || reply.Array[0].Bulk == reply.Array[2].Bulk {
+		t.Fatalf("expected 2 distinct fields with their values, got %+v", reply)
+	}
+	for i := 0; i < len(reply.Array); i += 2 {
+		if value := handleHGet(db, bulkValues("hash", reply.Array[i].Bulk)); value.Bulk != reply.Array[i+1].Bulk {
+			t.Errorf("field %s came with the wrong value %s", reply.Array[i].Bulk, reply.Array[i+1].Bulk)
+		}
+	}
+
+	testCases := []struct {
+		args        []string
+		expectedErr string
+	}{
+		{[]string{"hash", "x"}, errNotInteger},
+		{[]string{"hash", "1", "WITHSCORES"}, errSyntax},
+		{[]string{"hash", "1", "WITHVALUES", "extra"}, wrongArityError("HRANDFIELD")},
+	}
+	for _, tc := range testCases {
+		if reply := handleHRandField(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+			t.Errorf("HRANDFIELD %v: expected %q, got %+v", tc.args, tc.expectedErr, reply)
+		}
+	}
+}
+
+func TestHScanThroughExecute(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "HSET", bulkValues("hash", "f1", "v1", "f2", "v2", "other", "x"))
+
+	reply, _ := Execute(client, "HSCAN", bulkValues("hash", "0", "MATCH", "f*", "COUNT", "100"))
+	if reply.Array[0].Bulk != "0" || len(reply.Array[1].Array) != 4 {
+		t.Fatalf("expected f1 and f2 with their values, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "HSCAN", bulkValues("hash", "0", "COUNT", "0")); reply.Err != errSyntax {
+		t.Fatalf("expected %q, got %+v", errSyntax, reply)
+	}
+}
+
+func TestHRandFieldCountBounds(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleHSet(db, bulkValues("hash", "a", "1", "b", "2"))
+
+	for _, count := range []string{"-9223372036854775808", "-9223372036854775807", "4611686018427387904"} {
+		if reply := handleHRandField(db, bulkValues("hash", count)); reply.Err != errOutOfRange {
+			t.Errorf("HRANDFIELD %s: expected %q, got %+v", count, errOutOfRange, reply)
+		}
+	}
+	if reply := handleHRandField(db, bulkValues("hash", "-5", "WITHVALUES")); len(reply.Array) != 10 {
+		t.Fatalf("expected 5 fields with their values, got %+v", reply)
+	}
+	if reply := handleHRandField(db, bulkValues("hash", "5")); len(reply.Array) != 2 {
+		t.Fatalf("expected both fields, got %+v", reply)
+	}
+}
+
+func TestHIncrByFloatErrorsLeaveNoKey(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	for _, increment := range []string{"inf", "-inf", "nan", "abc"} {
+		if reply := handleHIncrByFloat(db, bulkValues("missing", "f", increment)); reply.DataType != resp.TypeError {
+			t.Errorf("HINCRBYFLOAT %s: expected an error, got %+v", increment, reply)
+		}
+	}
+	if reply := handleHIncrBy(db, bulkValues("missing", "f", "x")); reply.Err != errNotInteger {
+		t.Errorf("expected %q, got %+v", errNotInteger, reply)
+	}
+	if reply := handleExists(db, bulkValues("missing")); reply.Num != 0 {
+		t.Fatalf("expected the failed increments to leave no key, got %+v", reply)
+	}
+
+	if reply := handleHIncrByFloat(db, bulkValues("hash", "f", "1.5")); reply.Bulk != "1.5" {
+		t.Fatalf("expected 1.5, got %+v", reply)
+	}
+	if reply := handleHIncrBy(db, bulkValues("counters", "f", "-3")); reply.Num != -3 {
+		t.Fatalf("expected -3, got %+v", reply)
+	}
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strconv"
+	"strings"
+)
+
+const (
+	errProtocolVersion = "ERR Protocol version is not an integer or out of range"
+	errNoProto         = "NOPROTO unsupported protocol version"
+	errWrongPass       = "WRONGPASS invalid username-password pair or user is disabled."
+	errClientName      = "ERR Client names cannot contain spaces, newlines or special characters."
+
+	serverName    = "go-redis"
+	serverVersion = "0.0.1"
+)
+
+// handleHello switches the client to the requested protocol version and
+// replies with information about the server. No passwords are configured,
+// so AUTH accepts the default user with any password.
+func handleHello(client *Client, args []resp.Value) resp.Value {
+	protocol := client.Protocol()
+	name := client.name
+	if len(args) > 0 {
+		version, err := strconv.Atoi(args[0].Bulk)
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: errProtocolVersion}
+		}
+		if version != 2 && version != 3 {
+			return resp.Value{DataType: resp.TypeError, Err: errNoProto}
+		}
+		protocol = version
+
+		for i := 1; i < len(args); i++ {
+			switch option := strings.ToUpper(args[i].Bulk); {
+			case option == "AUTH" && i+2 < len(args):
+				if args[i+1].Bulk != "default" {
+					return resp.Value{DataType: resp.TypeError, Err: errWrongPass}
+				}
+				i += 2
+			case option == "SETNAME" && i+1 < len(args):
+				name = args[i+1].Bulk
+				if !validClientName(name) {
+					return resp.Value{DataType: resp.TypeError, Err: errClientName}
+				}
+				i++
+			default:
+				return resp.Value{DataType: resp.TypeError, Err: "ERR Syntax error in HELLO option '" + args[i].Bulk + "'"}
+			}
+		}
+	}
+
+	client.protocol.Store(int32(protocol))
+	client.name = name
+	return resp.Value{DataType: resp.TypeMap, Array: []resp.Value{
+		{DataType: resp.TypeBulk, Bulk: "server"}, {DataType: resp.TypeBulk, Bulk: serverName},
+		{DataType: resp.TypeBulk, Bulk: "version"}, {DataType: resp.TypeBulk, Bulk: serverVersion},
+		{DataType: resp.TypeBulk, Bulk: "proto"}, {DataType: resp.TypeInteger, Num: int64(protocol)},
+		{DataType: resp.TypeBulk, Bulk: "id"}, {DataType: resp.TypeInteger, Num: client.id},
+		{DataType: resp.TypeBulk, Bulk: "mode"}, {DataType: resp.TypeBulk, Bulk: "standalone"},
+		{DataType: resp.TypeBulk, Bulk: "role"}, {DataType: resp.TypeBulk, Bulk: "master"},
+		{DataType: resp.TypeBulk, Bulk: "modules"}, {DataType: resp.TypeArray, Array: []resp.Value{}},
+	}}
+}
+
+// validClientName reports whether name is made of printable characters
+// other than space, as Redis requires.
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] <= ' ' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
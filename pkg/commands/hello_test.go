@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"testing"
+)
+
+func TestHelloNegotiatesProtocol(t *testing.T) {
+	client := NewClient()
+	defer client.Close()
+
+	if reply, _ := Execute(client, "HELLO", bulkValues("4")); reply.Err != errNoProto {
+		t.Fatalf("expected NOPROTO, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "HELLO", bulkValues("3", "AUTH", "admin", "secret")); reply.Err != errWrongPass {
+		t.Fatalf("expected WRONGPASS, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "HELLO", bulkValues("3", "SETNAME", "bad name")); reply.Err != errClientName {
+		t.Fatalf("expected an invalid client name error, got %+v", reply)
+	}
+	if client.Protocol() != 2 {
+		t.Fatal("a failed HELLO switched the protocol")
+	}
+
+	reply, _ := Execute(client, "HELLO", bulkValues("3", "AUTH", "default", "any", "SETNAME", "worker"))
+	if reply.DataType != resp.TypeMap || reply.Array[4].Bulk != "proto" || reply.Array[5].Num != 3 {
+		t.Fatalf("expected a map reporting protocol 3, got %+v", reply)
+	}
+	if client.Protocol() != 3 || client.name != "worker" {
+		t.Fatalf("HELLO did not apply its options: protocol %d, name %q", client.Protocol(), client.name)
+	}
+}
+
+func TestRepliesAreConvertedForTheClientProtocol(t *testing.T) {
+	flushKeyspace()
+	resp2 := NewClient()
+	defer resp2.Close()
+	resp3 := NewClient()
+	defer resp3.Close()
+	Execute(resp3, "HELLO", bulkValues("3"))
+	drain(resp3)
+
+	Execute(resp2, "HSET", bulkValues("hash", "field", "value"))
+	Execute(resp2, "ZADD", bulkValues("zset", "1.5", "member"))
+	for _, client := range []*Client{resp2, resp3} {
+		for _, command := range [][]string{{"HGETALL", "hash"}, {"ZSCORE", "zset", "member"}, {"GET", "missing"}} {
+			reply, _ := Execute(client, command[0], bulkValues(command[1:]...))
+			client.Reply(reply)
+		}
+	}
+
+	expected := map[*Client]string{
+		resp2: "*2\r\n$5\r\nfield\r\n$5\r\nvalue\r\n$3\r\n1.5\r\n$-1\r\n",
+		resp3: "%1\r\n$5\r\nfield\r\n$5\r\nvalue\r\n,1.5\r\n_\r\n",
+	}
+	for client, want := range expected {
+		got := ""
+		for _, v := range drain(client) {
+			got += string(v.Serialize())
+		}
+		if got != want {
+			t.Errorf("protocol %d: expected %q, got %q", client.Protocol(), want, got)
+		}
+	}
+}
+
+func TestRESP3SubscribersReceivePushes(t *testing.T) {
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "HELLO", bulkValues("3"))
+	drain(client)
+
+	reply, _ := Execute(client, "SUBSCRIBE", bulkValues("news"))
+	client.Reply(reply)
+	// RESP3 clients may keep using regular commands while subscribed
+	reply, _ = Execute(client, "ECHO", bulkValues("still here"))
+	client.Reply(reply)
+	Execute(NewClient(), "PUBLISH", bulkValues("news", "hello"))
+
+	values := drain(client)
+	if len(values) != 3 || values[0].DataType != resp.TypePush || values[1].Str != "still here" ||
+		values[2].DataType != resp.TypePush {
+		t.Fatalf("expected a push, a reply and a push, got %+v", values)
+	}
+}
@@ -2,35 +2,89 @@ package commands
 
 import (
 	"go-redis/pkg/resp"
+	"math"
 	"strconv"
 )
 
-func handleIncr(args []resp.Value) resp.Value {
-	return handleIncrBy(args, 1)
+func handleIncr(db *Database, args []resp.Value) resp.Value {
+	return incrementBy(db, args[0].Bulk, 1)
 }
 
-func handleDecr(args []resp.Value) resp.Value {
-	return handleIncrBy(args, -1)
+func handleDecr(db *Database, args []resp.Value) resp.Value {
+	return incrementBy(db, args[0].Bulk, -1)
 }
 
-func handleIncrBy(args []resp.Value, increment int64) resp.Value {
-	if len(args) != 1 {
-		return resp.Value{DataType: resp.TypeError, Err: errWrongArgsCount}
+func handleIncrBy(db *Database, args []resp.Value) resp.Value {
+	increment, err := strconv.ParseInt(args[1].Bulk, 10, 64)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
 	}
-	key := args[0].Bulk
+	return incrementBy(db, args[0].Bulk, increment)
+}
+
+func handleDecrBy(db *Database, args []resp.Value) resp.Value {
+	decrement, err := strconv.ParseInt(args[1].Bulk, 10, 64)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	// The smallest int64 has no positive counterpart to add
+	if decrement == math.MinInt64 {
+		return resp.Value{DataType: resp.TypeError, Err: errOverflow}
+	}
+	return incrementBy(db, args[0].Bulk, -decrement)
+}
+
+// incrementBy adds increment to the integer stored at key, which counts as
+// 0 if it is missing. The key keeps its TTL.
+func incrementBy(db *Database, key string, increment int64) resp.Value {
+	record, exists := db.lookupKey(key)
 	var value int64
-	if record, ok := dataSet.Load(key); ok {
-		r := record.(Record)
-		if r.Type != TypeString {
+	if exists {
+		if record.Type != TypeString {
 			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
 		}
 		var err error
-		value, err = strconv.ParseInt(r.Value.(string), 10, 64)
+		value, err = strconv.ParseInt(stringValue(record), 10, 64)
 		if err != nil {
 			return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
 		}
 	}
+
+	if (increment > 0 && value > math.MaxInt64-increment) ||
+		(increment < 0 && value < math.MinInt64-increment) {
+		return resp.Value{DataType: resp.TypeError, Err: errOverflow}
+	}
+	value += increment
+	db.storeString(key, record, strconv.FormatInt(value, 10))
+	return resp.Value{DataType: resp.TypeInteger, Num: value}
+}
+
+// handleIncrByFloat adds a floating point increment to the number stored at
+// key, which counts as 0 if it is missing. The key keeps its TTL.
+func handleIncrByFloat(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	increment, err := parseFloat(args[1].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotFloat}
+	}
+
+	record, exists := db.lookupKey(key)
+	var value float64
+	if exists {
+		if record.Type != TypeString {
+			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+		}
+		value, err = parseFloat(stringValue(record))
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: errNotFloat}
+		}
+	}
+
 	value += increment
-	dataSet.Store(key, Record{Type: TypeString, Value: strconv.FormatInt(value, 10)})
-	return resp.Value{DataType: resp.TypeInteger, Num: int(value)}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return resp.Value{DataType: resp.TypeError, Err: errNaNOrInfinity}
+	}
+	formatted := formatFloat(value)
+	db.storeString(key, record, formatted)
+	return resp.Value{DataType: resp.TypeBulk, Bulk: formatted}
 }
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"testing"
+)
+
+func TestIncrementCommands(t *testing.T) {
+	testCases := []struct {
+		name     string
+		initial  string
+		handler  func(*Database, []resp.Value) resp.Value
+		args     []string
+		expected resp.Value
+	}{
+		{"Incr Missing", "", handleIncr, nil, resp.Value{DataType: resp.TypeInteger, Num: 1}},
+		{"IncrBy", "10", handleIncrBy, []string{"5"}, resp.Value{DataType: resp.TypeInteger, Num: 15}},
+		{"DecrBy", "10", handleDecrBy, []string{"15"}, resp.Value{DataType: resp.TypeInteger, Num: -5}},
+		{"IncrBy Not A Number", "10", handleIncrBy, []string{"1.5"}, resp.Value{DataType: resp.TypeError, Err: errNotInteger}},
+		{"IncrBy Value Not A Number", "x", handleIncrBy, []string{"1"}, resp.Value{DataType: resp.TypeError, Err: errNotInteger}},
+		{"Incr Overflow", "9223372036854775807", handleIncr, nil, resp.Value{DataType: resp.TypeError, Err: errOverflow}},
+		{"Decr Overflow", "-9223372036854775808", handleDecr, nil, resp.Value{DataType: resp.TypeError, Err: errOverflow}},
+		{"IncrBy Overflow", "1", handleIncrBy, []string{"9223372036854775807"}, resp.Value{DataType: resp.TypeError, Err: errOverflow}},
+		{"DecrBy Smallest", "0", handleDecrBy, []string{"-9223372036854775808"}, resp.Value{DataType: resp.TypeError, Err: errOverflow}},
+		{"IncrBy Largest", "0", handleIncrBy, []string{"9223372036854775807"}, resp.Value{DataType: resp.TypeInteger, Num: 9223372036854775807}},
+		{"IncrByFloat", "10.5", handleIncrByFloat, []string{"0.1"}, resp.Value{DataType: resp.TypeBulk, Bulk: "10.6"}},
+		{"IncrByFloat Integer", "3", handleIncrByFloat, []string{"2"}, resp.Value{DataType: resp.TypeBulk, Bulk: "5"}},
+		{"IncrByFloat Exponent", "5.0e3", handleIncrByFloat, []string{"2.0e2"}, resp.Value{DataType: resp.TypeBulk, Bulk: "5200"}},
+		{"IncrByFloat Not A Float", "1", handleIncrByFloat, []string{"abc"}, resp.Value{DataType: resp.TypeError, Err: errNotFloat}},
+		{"IncrByFloat Infinity", "1", handleIncrByFloat, []string{"inf"}, resp.Value{DataType: resp.TypeError, Err: errNaNOrInfinity}},
+	}
+
+	db := databases[0]
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db.deleteKey("key")
+			if tc.initial != "" {
+				handleSet(db, bulkValues("key", tc.initial))
+			}
+			reply := tc.handler(db, bulkValues(append([]string{"key"}, tc.args...)...))
+			if reply.DataType != tc.expected.DataType || reply.Num != tc.expected.Num ||
+				reply.Bulk != tc.expected.Bulk || reply.Err != tc.expected.Err {
+				t.Errorf("expected %+v, got %+v", tc.expected, reply)
+			}
+			if tc.expected.DataType == resp.TypeError {
+				if value, _, _ := db.loadString("key"); value != tc.initial {
+					t.Errorf("a failed increment changed the value to %q", value)
+				}
+			}
+		})
+	}
+}
+
+func TestIncrementKeepsTTL(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("counter", "1", "EX", "100"))
+	handleSet(db, bulkValues("float", "1", "EX", "100"))
+	handleSAdd(db, bulkValues("set", "x"))
+
+	handleIncr(db, bulkValues("counter"))
+	handleIncrBy(db, bulkValues("counter", "10"))
+	handleDecrBy(db, bulkValues("counter", "2"))
+	handleIncrByFloat(db, bulkValues("float", "0.5"))
+
+	if record, _ := db.lookupKey("counter"); record.Value != "10" || record.ExpiryTime == nil {
+		t.Fatalf("expected counter to be 10 with its TTL, got %+v", record)
+	}
+	if record, _ := db.lookupKey("float"); record.Value != "1.5" || record.ExpiryTime == nil {
+		t.Fatalf("expected float to be 1.5 with its TTL, got %+v", record)
+	}
+	for _, handler := range []func(*Database, []resp.Value) resp.Value{handleIncrBy, handleDecrBy, handleIncrByFloat} {
+		if reply := handler(db, bulkValues("set", "1")); reply.Err != errWrongType {
+			t.Errorf("expected %q, got %+v", errWrongType, reply)
+		}
+	}
+}
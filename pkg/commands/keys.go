@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errInvalidCursor = "ERR invalid cursor"
+
+	// defaultScanCount is how many elements a SCAN looks at without COUNT.
+	defaultScanCount = 10
+)
+
+// typeNames are the names TYPE reports and SCAN's TYPE option takes.
+var typeNames = map[DataType]string{
+	TypeString: "string",
+	TypeList:   "list",
+	TypeSet:    "set",
+	TypeZSet:   "zset",
+	TypeHash:   "hash",
+}
+
+func handleType(db *Database, args []resp.Value) resp.Value {
+	record, ok := db.lookupKey(args[0].Bulk)
+	if !ok {
+		return resp.Value{DataType: resp.TypeString, Str: "none"}
+	}
+	return resp.Value{DataType: resp.TypeString, Str: typeNames[record.Type]}
+}
+
+func handleKeys(db *Database, args []resp.Value) resp.Value {
+	pattern := args[0].Bulk
+	now := time.Now()
+	keys := []string{}
+	db.data.Range(func(k, v any) bool {
+		key := k.(string)
+		if !v.(Record).isExpired(now) && globMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return resp.Value{DataType: resp.TypeArray, Array: bulkValues(keys...)}
+}
+
+// handleRandomKey returns a key picked uniformly at random from the key
+// index. A pick that turns out to be expired is deleted and another one is
+// made.
+func handleRandomKey(db *Database, args []resp.Value) resp.Value {
+	for {
+		indexMu.Lock()
+		n := db.keys.len()
+		var picked string
+		if n > 0 {
+			picked = db.keys.keys[rand.IntN(n)]
+		}
+		indexMu.Unlock()
+		if n == 0 {
+			return resp.Value{DataType: resp.TypeNull, IsNull: true}
+		}
+		if _, ok := db.lookupKey(picked); ok {
+			return resp.Value{DataType: resp.TypeBulk, Bulk: picked}
+		}
+	}
+}
+
+// scanOptions are the options shared by SCAN and the commands scanning a
+// collection.
+type scanOptions struct {
+	cursor   uint64
+	count    int
+	pattern  string
+	typeName string
+	noValues bool
+}
+
+// parseScanArgs parses "cursor [MATCH pattern] [COUNT count]", followed by
+// TYPE for SCAN, or NOVALUES for HSCAN, where allowed.
+func parseScanArgs(args []resp.Value, allowType, allowNoValues bool) (scanOptions, string) {
+	opts := scanOptions{count: defaultScanCount}
+	cursor, err := strconv.ParseUint(args[0].Bulk, 10, 64)
+	if err != nil {
+		return opts, errInvalidCursor
+	}
+	opts.cursor = cursor
+
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(args[i].Bulk)
+		hasValue := i+1 < len(args)
+		switch {
+		case option == "MATCH" && hasValue:
+			opts.pattern = args[i+1].Bulk
+			i++
+		case option == "COUNT" && hasValue:
+			count, err := strconv.Atoi(args[i+1].Bulk)
+			if err != nil {
+				return opts, errNotInteger
+			}
+			if count < 1 {
+				return opts, errSyntax
+			}
+			opts.count = count
+			i++
+		case option == "TYPE" && hasValue && allowType:
+			opts.typeName = strings.ToLower(args[i+1].Bulk)
+			i++
+		case option == "NOVALUES" && allowNoValues:
+			opts.noValues = true
+		default:
+			return opts, errSyntax
+		}
+	}
+	return opts, ""
+}
+
+// scanRange picks the positions of a dict a scan visits next, from low up
+// to but not including high, along with the cursor to continue from. A
+// scan walks the positions downwards, and its cursor is the position it
+// has reached, except that 0 starts a scan from the top and ends it once
+// the bottom is reached, so no state is kept between calls.
+//
+// Walking downwards is what makes this safe while the dict changes: new
+// entries are appended above the cursor, and deleting an entry only moves
+// the last one down into its place. An entry below the cursor therefore
+// stays below it, so every element present for the whole scan is
+// returned, while one added or removed in the meantime may or may not be.
+// An entry moved down from above the cursor is returned again.
+func scanRange(n int, cursor uint64, count int) (low, high int, next uint64) {
+	high = n
+	if cursor != 0 && cursor < uint64(n) {
+		high = int(cursor)
+	}
+	low = max(high-count, 0)
+	return low, high, uint64(low)
+}
+
+// scanReply is the reply to the SCAN family: the next cursor and a batch of
+// elements.
+func scanReply(cursor uint64, elements []resp.Value) resp.Value {
+	return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{
+		{DataType: resp.TypeBulk, Bulk: strconv.FormatUint(cursor, 10)},
+		{DataType: resp.TypeArray, Array: elements},
+	}}
+}
+
+// handleScan iterates the keyspace a batch at a time, walking the key
+// index. MATCH and TYPE filter a batch after it has been picked, so a batch
+// may come back smaller than COUNT, or empty, before the scan is over.
+func handleScan(db *Database, args []resp.Value) resp.Value {
+	opts, errMsg := parseScanArgs(args, true, false)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+
+	// Keys expire while the keyspace lock is shared, so the batch is
+	// copied out of the index before any of it is looked up
+	indexMu.Lock()
+	low, high, cursor := scanRange(db.keys.len(), opts.cursor, opts.count)
+	keys := slices.Clone(db.keys.keys[low:high])
+	indexMu.Unlock()
+
+	result := make([]resp.Value, 0, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := keys[i]
+		if opts.pattern != "" && !globMatch(opts.pattern, key) {
+			continue
+		}
+		record, ok := db.lookupKey(key)
+		if !ok || (opts.typeName != "" && typeNames[record.Type] != opts.typeName) {
+			continue
+		}
+		result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: key})
+	}
+	return scanReply(cursor, result)
+}
+
+// scanDict backs HSCAN, SSCAN and ZSCAN on the collection stored in d,
+// which is nil for a missing key: it replies with each member of the next
+// batch that matches, followed by its value unless value is nil.
+func scanDict[V any](d *dict[V], opts scanOptions, value func(V) string) resp.Value {
+	low, high, cursor := scanRange(d.len(), opts.cursor, opts.count)
+	result := make([]resp.Value, 0, high-low)
+	for i := high - 1; i >= low; i-- {
+		member := d.keys[i]
+		if opts.pattern != "" && !globMatch(opts.pattern, member) {
+			continue
+		}
+		result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: member})
+		if value != nil {
+			result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: value(d.values[i])})
+		}
+	}
+	return scanReply(cursor, result)
+}
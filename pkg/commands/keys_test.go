@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTypeAndKeys(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("user:1", "a"))
+	handleRPush(db, bulkValues("user:2", "a"))
+	handleSAdd(db, bulkValues("user:10", "a"))
+	handleZAdd(db, bulkValues("zset", "1", "a"))
+	handleHSet(db, bulkValues("hash", "f", "v"))
+	past := time.Now().Add(-time.Second)
+	db.storeRecord("user:3", Record{Type: TypeString, Value: "x", ExpiryTime: &past})
+
+	for key, expected := range map[string]string{
+		"user:1": "string", "user:2": "list", "user:10": "set", "zset": "zset", "hash": "hash",
+		"user:3": "none", "missing": "none",
+	} {
+		if reply := handleType(db, bulkValues(key)); reply.Str != expected {
+			t.Errorf("expected TYPE %s to be %s, got %+v", key, expected, reply)
+		}
+	}
+
+	testCases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"*", []string{"hash", "user:1", "user:10", "user:2", "zset"}},
+		{"user:?", []string{"user:1", "user:2"}},
+		{"user:[^1]*", []string{"user:2"}},
+		{"nothing*", []string{}},
+	}
+	for _, tc := range testCases {
+		reply := handleKeys(db, bulkValues(tc.pattern))
+		keys := make([]string, len(reply.Array))
+		for i, v := range reply.Array {
+			keys[i] = v.Bulk
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, tc.expected) {
+			t.Errorf("expected KEYS %s to return %v, got %v", tc.pattern, tc.expected, keys)
+		}
+	}
+}
+
+func TestRandomKey(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	if reply := handleRandomKey(db, nil); !reply.IsNull {
+		t.Fatalf("expected a null reply from an empty database, got %+v", reply)
+	}
+
+	past := time.Now().Add(-time.Second)
+	for i := 0; i < 10; i++ {
+		db.storeRecord("expired:"+strconv.Itoa(i), Record{Type: TypeString, Value: "x", ExpiryTime: &past})
+	}
+	handleSet(db, bulkValues("alive", "x"))
+	if reply := handleRandomKey(db, nil); reply.Bulk != "alive" {
+		t.Fatalf("expected the only live key, got %+v", reply)
+	}
+
+	for i := 0; i < 10; i++ {
+		handleSet(db, bulkValues("key:"+strconv.Itoa(i), "x"))
+	}
+	picked := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		picked[handleRandomKey(db, nil).Bulk] = true
+	}
+	if len(picked) < 2 {
+		t.Fatalf("expected RANDOMKEY to return different keys, got %v", picked)
+	}
+}
+
+// scanAll runs a scan command to completion, calling between after every
+// batch, and returns how many times each element was returned.
+func scanAll(t *testing.T, scan func(cursor string) resp.Value, between func()) map[string]int {
+	t.Helper()
+	seen := map[string]int{}
+	cursor := "0"
+	for calls := 0; ; calls++ {
+		if calls > 10000 {
+			t.Fatal("the scan does not terminate")
+		}
+		reply := scan(cursor)
+		if reply.DataType != resp.TypeArray {
+			t.Fatalf("unexpected reply %+v", reply)
+		}
+		for _, element := range reply.Array[1].Array {
+			seen[element.Bulk]++
+		}
+		cursor = reply.Array[0].Bulk
+		if cursor == "0" {
+			return seen
+		}
+		between()
+	}
+}
+
+func TestScanWhileTheKeyspaceChanges(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	for i := 0; i < 500; i++ {
+		handleSet(db, bulkValues("stable:"+strconv.Itoa(i), "x"))
+	}
+
+	added := 0
+	seen := scanAll(t, func(cursor string) resp.Value {
+		return handleScan(db, bulkValues(cursor, "COUNT", "7"))
+	}, func() {
+		handleSet(db, bulkValues("added:"+strconv.Itoa(added), "x"))
+		handleDelete(db, bulkValues("added:"+strconv.Itoa(added-1)))
+		added++
+	})
+
+	for i := 0; i < 500; i++ {
+		if n := seen["stable:"+strconv.Itoa(i)]; n != 1 {
+			t.Fatalf("expected stable:%d to be returned once, got %d", i, n)
+		}
+	}
+}
+
+func TestScanFilters(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	for i := 0; i < 50; i++ {
+		handleSet(db, bulkValues("str:"+strconv.Itoa(i), "x"))
+		handleRPush(db, bulkValues("list:"+strconv.Itoa(i), "x"))
+	}
+
+	seen := scanAll(t, func(cursor string) resp.Value {
+		return handleScan(db, bulkValues(cursor, "MATCH", "*:1*", "TYPE", "list"))
+	}, func() {})
+	if len(seen) != 11 || seen["list:1"] != 1 || seen["list:19"] != 1 {
+		t.Fatalf("expected list:1 and list:10 to list:19, got %v", seen)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	db := databases[0]
+	testCases := []struct {
+		args        []string
+		expectedErr string
+	}{
+		{[]string{"abc"}, errInvalidCursor},
+		{[]string{"-1"}, errInvalidCursor},
+		{[]string{"0", "COUNT", "0"}, errSyntax},
+		{[]string{"0", "COUNT", "many"}, errNotInteger},
+		{[]string{"0", "MATCH"}, errSyntax},
+		{[]string{"0", "NOVALUES"}, errSyntax},
+	}
+	for _, tc := range testCases {
+		if reply := handleScan(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+			t.Errorf("expected SCAN %v to fail with %q, got %+v", tc.args, tc.expectedErr, reply)
+		}
+	}
+}
+
+func TestCollectionScans(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	for i := 0; i < 100; i++ {
+		n := strconv.Itoa(i)
+		handleHSet(db, bulkValues("hash", "f"+n, "v"+n))
+		handleSAdd(db, bulkValues("set", "m"+n))
+		handleZAdd(db, bulkValues("zset", n, "m"+n))
+	}
+
+	hscan := scanAll(t, func(cursor string) resp.Value {
+		return handleHScan(db, bulkValues("hash", cursor, "COUNT", "9"))
+	}, func() {})
+	if len(hscan) != 200 || hscan["f42"] != 1 || hscan["v42"] != 1 {
+		t.Fatalf("expected every field and value once, got %d elements", len(hscan))
+	}
+	if reply := handleHScan(db, bulkValues("hash", "0", "COUNT", "1000", "NOVALUES")); len(reply.Array[1].Array) != 100 {
+		t.Fatalf("expected NOVALUES to return the fields only, got %d elements", len(reply.Array[1].Array))
+	}
+
+	sscan := scanAll(t, func(cursor string) resp.Value {
+		return handleSScan(db, bulkValues("set", cursor, "MATCH", "m1?"))
+	}, func() {})
+	if len(sscan) != 10 || sscan["m15"] != 1 {
+		t.Fatalf("expected m10 to m19, got %v", sscan)
+	}
+
+	reply := handleZScan(db, bulkValues("zset", "0", "COUNT", "1000", "MATCH", "m7"))
+	if elements := reply.Array[1].Array; reply.Array[0].Bulk != "0" || len(elements) != 2 || elements[1].Bulk != "7" {
+		t.Fatalf("expected m7 with its score, got %+v", reply)
+	}
+
+	if reply := handleZScan(db, bulkValues("missing", "0")); len(reply.Array[1].Array) != 0 || reply.Array[0].Bulk != "0" {
+		t.Fatalf("expected an empty scan of a missing key, got %+v", reply)
+	}
+	if reply := handleSScan(db, bulkValues("hash", "0")); reply.Err != errWrongType {
+		t.Fatalf("expected WRONGTYPE, got %+v", reply)
+	}
+}
+
+func TestScanCursorIsStateless(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	for i := 0; i < 1000; i++ {
+		handleSAdd(db, bulkValues("set", "m"+strconv.Itoa(i)))
+	}
+
+	// Removing members moves others around in the set, and adding them
+	// grows it, while the scan is in progress
+	removed := map[string]bool{}
+	added := 0
+	seen := scanAll(t, func(cursor string) resp.Value {
+		return handleSScan(db, bulkValues("set", cursor, "COUNT", "10"))
+	}, func() {
+		for range 3 {
+			member := "m" + strconv.Itoa(rand.IntN(1000))
+			handleSRem(db, bulkValues("set", member))
+			removed[member] = true
+		}
+		handleSAdd(db, bulkValues("set", "added"+strconv.Itoa(added)))
+		added++
+	})
+	for i := 0; i < 1000; i++ {
+		member := "m" + strconv.Itoa(i)
+		if !removed[member] && seen[member] == 0 {
+			t.Fatalf("expected %s to be returned", member)
+		}
+	}
+
+	// Nothing is kept between calls, so continuing twice from a cursor
+	// returns the same batch
+	next := handleSScan(db, bulkValues("set", "0")).Array[0].Bulk
+	first := handleSScan(db, bulkValues("set", next))
+	second := handleSScan(db, bulkValues("set", next))
+	if next == "0" || !slices.EqualFunc(first.Array[1].Array, second.Array[1].Array, func(a, b resp.Value) bool {
+		return a.Bulk == b.Bulk
+	}) {
+		t.Fatalf("expected continuing twice from a cursor to return the same batch, got %+v and %+v", first, second)
+	}
+}
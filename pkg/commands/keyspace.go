@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	activeExpireInterval   = 100 * time.Millisecond
+	activeExpireSampleSize = 20
+	activeExpireTimeBudget = 25 * time.Millisecond
+)
+
+// keyspaceMu makes every command atomic. Commands that may modify the
+// keyspace hold it exclusively while they run, so that reading a value and
+// storing the result cannot interleave with another client; read-only
+// commands share it. Taking it exclusively is also how a keyspaceFork gets
+// a point-in-time view of the keyspace.
+var keyspaceMu sync.RWMutex
+
+// keyspaceWritable is set while a command that may modify the keyspace
+// holds the lock, so that looking a key up preserves it for the forks in
+// progress, which read commands need not do. It is only changed under the
+// exclusive lock.
+var keyspaceWritable bool
+
+// lockKeyspace takes the keyspace lock for commands that may modify the
+// keyspace.
+func lockKeyspace() {
+	keyspaceMu.Lock()
+	keyspaceWritable = true
+}
+
+func unlockKeyspace() {
+	keyspaceWritable = false
+	keyspaceMu.Unlock()
+}
+
+// modifications counts the changes commands make to the keyspace.
+// callCommand compares it before and after a write command, so that a
+// write that changed nothing, such as SADD of a member already there or
+// DEL of a missing key, is neither propagated nor counted as a change.
+var modifications atomic.Int64
+
+// signalModified records that a command changed a value in place. Storing
+// and deleting a key record it themselves.
+func signalModified() {
+	modifications.Add(1)
+}
+
+// DefaultDatabases is the number of databases unless SetDatabases is
+// called.
+const DefaultDatabases = 16
+
+// Database is one of the numbered keyspaces a client chooses between with
+// SELECT. SWAPDB and FLUSHDB replace its contents while the keyspace lock
+// is held exclusively, so a Database keeps its number while the data it
+// holds moves.
+type Database struct {
+	id   int
+	data *sync.Map
+	// keys indexes the keys in data, including expired keys that have not
+	// been reclaimed yet, so that they can be counted, picked at random and
+	// scanned by position without walking the whole keyspace. It is guarded
+	// by indexMu.
+	keys *stringSet
+	// expires indexes the keys whose Record carries an ExpiryTime, so the
+	// active expiry cycle can sample them without walking the whole
+	// keyspace. It is guarded by indexMu.
+	expires map[string]struct{}
+}
+
+// dbKey identifies a key in a particular database, for the registries of
+// keys that clients watch or are blocked on.
+type dbKey struct {
+	db  int
+	key string
+}
+
+var (
+	databases = newDatabases(DefaultDatabases)
+	// indexMu guards the indexes of every database. Keys expire while the
+	// keyspace lock is shared, so the lock alone does not protect them.
+	indexMu sync.Mutex
+)
+
+func newDatabases(n int) []*Database {
+	dbs := make([]*Database, n)
+	for i := range dbs {
+		dbs[i] = &Database{id: i, data: new(sync.Map), keys: newDict[struct{}](0), expires: make(map[string]struct{})}
+	}
+	return dbs
+}
+
+// SetDatabases sets the number of databases, dropping their contents. It
+// must be called before any client connects.
+func SetDatabases(n int) {
+	databases = newDatabases(n)
+}
+
+// flush drops every key in the database.
+func (db *Database) flush() {
+	db.data = new(sync.Map)
+	indexMu.Lock()
+	db.keys = newDict[struct{}](0)
+	db.expires = make(map[string]struct{})
+	indexMu.Unlock()
+}
+
+// swapContents exchanges the keys of two databases.
+func swapContents(a, b *Database) {
+	a.data, b.data = b.data, a.data
+	indexMu.Lock()
+	a.keys, b.keys = b.keys, a.keys
+	a.expires, b.expires = b.expires, a.expires
+	indexMu.Unlock()
+}
+
+// dbSize returns the number of keys in the database, including expired
+// keys that have not been reclaimed yet.
+func (db *Database) dbSize() int {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	return db.keys.len()
+}
+
+func (r Record) isExpired(now time.Time) bool {
+	return r.ExpiryTime != nil && !r.ExpiryTime.After(now)
+}
+
+// lookupKey returns the record stored at key, lazily deleting it first if
+// its TTL has passed. Every command reads the keyspace through it, so they
+// all agree on whether a key exists.
+func (db *Database) lookupKey(key string) (Record, bool) {
+	value, ok := db.data.Load(key)
+	if !ok {
+		return Record{}, false
+	}
+	record := value.(Record)
+	if !loading.Load() && record.isExpired(time.Now()) {
+		// Readers share the keyspace lock, so another one may have
+		// deleted the key first; only the one that did logs it
+		if db.removeKey(key) {
+			propagateExpired(db, key)
+		}
+		return Record{}, false
+	}
+	if keyspaceWritable {
+		// The command may modify the value in place
+		beforeChange(db.data, key, record, true, true)
+	}
+	return record, true
+}
+
+// peekKey returns the record stored at key as it is, even if its TTL has
+// passed. Propagation reads the keys a command just wrote through it, so
+// that logging a command never expires a key, which would log a DEL ahead
+// of the command itself.
+func (db *Database) peekKey(key string) (Record, bool) {
+	value, ok := db.data.Load(key)
+	if !ok {
+		return Record{}, false
+	}
+	return value.(Record), true
+}
+
+// storeRecord sets key to record, replacing any previous value and TTL.
+func (db *Database) storeRecord(key string, record Record) {
+	previous, replaced := db.data.Swap(key, record)
+	if replaced {
+		beforeChange(db.data, key, previous.(Record), true, false)
+	} else {
+		beforeChange(db.data, key, Record{}, false, false)
+	}
+	signalModified()
+
+	indexMu.Lock()
+	if !replaced {
+		db.keys.set(key, struct{}{})
+	}
+	if record.ExpiryTime != nil {
+		db.expires[key] = struct{}{}
+	} else {
+		delete(db.expires, key)
+	}
+	indexMu.Unlock()
+}
+
+// deleteKey removes key, reporting whether it was present.
+func (db *Database) deleteKey(key string) bool {
+	if !db.removeKey(key) {
+		return false
+	}
+	signalModified()
+	return true
+}
+
+// removeKey is deleteKey for a key whose TTL has passed. Its deletion is
+// propagated on its own rather than as part of the command that found it
+// expired, so it does not count as that command's change.
+func (db *Database) removeKey(key string) bool {
+	previous, existed := db.data.LoadAndDelete(key)
+	if !existed {
+		return false
+	}
+	beforeChange(db.data, key, previous.(Record), true, false)
+
+	indexMu.Lock()
+	db.keys.delete(key)
+	delete(db.expires, key)
+	indexMu.Unlock()
+	return true
+}
+
+// RunActiveExpire periodically reclaims expired keys that are never read
+// again, in the spirit of Redis's active expire cycle. It never returns.
+func RunActiveExpire() {
+	ticker := time.NewTicker(activeExpireInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		activeExpireCycle()
+	}
+}
+
+// activeExpireNextDB is the database the next active expire cycle starts
+// from, like Redis's current_db. Only the active expire goroutine uses it.
+var activeExpireNextDB int
+
+// activeExpireCycle samples keys with a TTL in every database and deletes
+// the expired ones. As long as more than a quarter of a sample turns out to
+// be expired there are probably many more, so it keeps going on the same
+// database until its time budget is spent. Each cycle picks up after the
+// database the previous one stopped in, so a large database cannot keep the
+// ones after it from ever being visited.
+func activeExpireCycle() {
+	deadline := time.Now().Add(activeExpireTimeBudget)
+	for range databases {
+		db := databases[activeExpireNextDB%len(databases)]
+		activeExpireNextDB = (activeExpireNextDB + 1) % len(databases)
+		for {
+			sampled, expired := db.expireSample(activeExpireSampleSize)
+			if time.Now().After(deadline) {
+				return
+			}
+			if sampled == 0 || expired*4 <= sampled {
+				break
+			}
+		}
+	}
+}
+
+func (db *Database) expireSample(size int) (sampled, expired int) {
+	// Map iteration starts at a random position, which makes this a
+	// random sample
+	keys := make([]string, 0, size)
+	indexMu.Lock()
+	for key := range db.expires {
+		if len(keys) == size {
+			break
+		}
+		keys = append(keys, key)
+	}
+	indexMu.Unlock()
+
+	keyspaceMu.RLock()
+	defer keyspaceMu.RUnlock()
+	now := time.Now()
+	for _, key := range keys {
+		if value, ok := db.data.Load(key); ok && value.(Record).isExpired(now) && db.removeKey(key) {
+			propagateExpired(db, key)
+			expired++
+		}
+	}
+	return len(keys), expired
+}
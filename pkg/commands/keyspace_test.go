@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func flushKeyspace() {
+	for _, db := range databases {
+		db.flush()
+	}
+}
+
+func TestLazyExpiryIsConsistentAcrossCommands(t *testing.T) {
+	db := databases[0]
+	past := time.Now().Add(-time.Second)
+	db.storeRecord("stale", Record{Type: TypeString, Value: "1", ExpiryTime: &past})
+
+	if reply := handleExists(db, bulkValues("stale")); reply.Num != 0 {
+		t.Fatalf("EXISTS reported an expired key, got %d", reply.Num)
+	}
+
+	db.storeRecord("stale", Record{Type: TypeString, Value: "1", ExpiryTime: &past})
+	if reply := handleIncr(db, bulkValues("stale")); reply.Num != 1 {
+		t.Fatalf("INCR should start from zero on an expired key, got %d", reply.Num)
+	}
+
+	db.storeRecord("stale", Record{Type: TypeString, Value: "1", ExpiryTime: &past})
+	if reply := handleDelete(db, bulkValues("stale")); reply.Num != 0 {
+		t.Fatalf("DEL counted an expired key, got %d", reply.Num)
+	}
+}
+
+func TestExpiredKeyIsPropagatedOnce(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	past := time.Now().Add(-time.Second)
+	const keys, readers = 20000, 8
+	for i := 0; i < keys; i++ {
+		db.storeRecord("stale:"+strconv.Itoa(i), Record{Type: TypeString, Value: "x", ExpiryTime: &past})
+	}
+
+	before := dirty.Load()
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keyspaceMu.RLock()
+			defer keyspaceMu.RUnlock()
+			<-start
+			for i := 0; i < keys; i++ {
+				db.lookupKey("stale:" + strconv.Itoa(i))
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if changes := dirty.Load() - before; changes != keys {
+		t.Fatalf("expected each expired key to be propagated once, got %d changes for %d keys", changes, keys)
+	}
+}
+
+func TestActiveExpireCycleReclaimsExpiredKeys(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+	for i := 0; i < 500; i++ {
+		db.storeRecord("expired:"+strconv.Itoa(i), Record{Type: TypeString, Value: "x", ExpiryTime: &past})
+	}
+	db.storeRecord("alive", Record{Type: TypeString, Value: "x", ExpiryTime: &future})
+
+	activeExpireCycle()
+
+	for i := 0; i < 500; i++ {
+		if _, ok := db.data.Load("expired:" + strconv.Itoa(i)); ok {
+			t.Fatalf("expired:%d was not reclaimed", i)
+		}
+	}
+	if _, ok := db.data.Load("alive"); !ok {
+		t.Fatal("a key with a future TTL was reclaimed")
+	}
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if len(db.expires) != 1 {
+		t.Fatalf("expected only the live key to remain indexed, got %d keys", len(db.expires))
+	}
+}
+
+func TestActiveExpireReachesEveryDatabase(t *testing.T) {
+	flushKeyspace()
+	defer flushKeyspace()
+	past := time.Now().Add(-time.Second)
+
+	// Far more expired keys in db 0 than one cycle's budget can reclaim
+	large := databases[0]
+	for i := 0; i < 300000; i++ {
+		large.storeRecord("expired:"+strconv.Itoa(i), Record{Type: TypeString, Value: "x", ExpiryTime: &past})
+	}
+	last := databases[len(databases)-1]
+	for i := 0; i < 10; i++ {
+		last.storeRecord("expired:"+strconv.Itoa(i), Record{Type: TypeString, Value: "x", ExpiryTime: &past})
+	}
+
+	activeExpireNextDB = 0
+	activeExpireCycle()
+	activeExpireCycle()
+
+	if size := last.dbSize(); size != 0 {
+		t.Fatalf("expected db %d to be reclaimed while db 0 is still large, %d keys are left", len(databases)-1, size)
+	}
+}
+
+func TestCommandsAreAtomicUnderConcurrentClients(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	const clients, rounds = 32, 500
+
+	var wg sync.WaitGroup
+	setWins := make([]int, rounds)
+	var setMu sync.Mutex
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			client := NewClient()
+			defer client.Close()
+			for round := 0; round < rounds; round++ {
+				Execute(client, "INCR", bulkValues("counter"))
+				Execute(client, "LPUSH", bulkValues("list", id))
+				Execute(client, "HINCRBY", bulkValues("hash", "field", "1"))
+				Execute(client, "GET", bulkValues("counter"))
+
+				reply, _ := Execute(client, "SET", bulkValues("lock:"+strconv.Itoa(round), id, "NX"))
+				if reply.DataType == resp.TypeString {
+					setMu.Lock()
+					setWins[round]++
+					setMu.Unlock()
+				}
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	if value, _, _ := db.loadString("counter"); value != strconv.Itoa(clients*rounds) {
+		t.Fatalf("expected counter %d, got %s", clients*rounds, value)
+	}
+	if reply := handleLLen(db, bulkValues("list")); reply.Num != clients*rounds {
+		t.Fatalf("expected %d list elements, got %d", clients*rounds, reply.Num)
+	}
+	if reply := handleHGet(db, bulkValues("hash", "field")); reply.Bulk != strconv.Itoa(clients*rounds) {
+		t.Fatalf("expected hash field %d, got %s", clients*rounds, reply.Bulk)
+	}
+	for round, wins := range setWins {
+		if wins != 1 {
+			t.Fatalf("SET NX on lock:%d succeeded %d times", round, wins)
+		}
+	}
+}
@@ -0,0 +1,520 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strconv"
+	"strings"
+)
+
+const (
+	errNoSuchKey        = "ERR no such key"
+	errIndexOutOfRange  = "ERR index out of range"
+	errLPosRankZero     = "ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list"
+	errCountNegative    = "ERR COUNT can't be negative"
+	errMaxLenNegative   = "ERR MAXLEN can't be negative"
+	errCountNotPositive = "ERR count should be greater than 0"
+)
+
+// loadList returns the list stored at key. A missing key yields nil, while
+// a key holding another type reports wrongType.
+func (db *Database) loadList(key string) (list *deque, wrongType bool) {
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return nil, false
+	}
+	if record.Type != TypeList {
+		return nil, true
+	}
+	return record.Value.(*deque), false
+}
+
+// deleteListIfEmpty removes key once its list has no elements left, since
+// Redis never keeps empty aggregates around.
+func (db *Database) deleteListIfEmpty(key string, list *deque) {
+	if list != nil && list.len() == 0 {
+		db.deleteKey(key)
+	}
+}
+
+func parseListSide(s string) (left bool, ok bool) {
+	switch strings.ToUpper(s) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// popElements pops up to count elements from one end of list.
+func popElements(list *deque, left bool, count int) []string {
+	popped := make([]string, 0, min(count, list.len()))
+	for len(popped) < count && list.len() > 0 {
+		if left {
+			popped = append(popped, list.popFront())
+		} else {
+			popped = append(popped, list.popBack())
+		}
+	}
+	if len(popped) > 0 {
+		signalModified()
+	}
+	return popped
+}
+
+func bulkArray(elements []string) resp.Value {
+	result := make([]resp.Value, len(elements))
+	for i, element := range elements {
+		result[i] = resp.Value{DataType: resp.TypeBulk, Bulk: element}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
+
+func handleLPop(db *Database, args []resp.Value) resp.Value {
+	return popCommand(db, "LPOP", args, true)
+}
+
+func handleRPop(db *Database, args []resp.Value) resp.Value {
+	return popCommand(db, "RPOP", args, false)
+}
+
+func popCommand(db *Database, name string, args []resp.Value, left bool) resp.Value {
+	if len(args) > 2 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError(name)}
+	}
+
+	key := args[0].Bulk
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(args[1].Bulk)
+		if err != nil || count < 0 {
+			return resp.Value{DataType: resp.TypeError, Err: errNotPositive}
+		}
+	}
+
+	list, wrongType := db.loadList(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if list == nil {
+		// With a count the reply would have been an array, so it is the
+		// null array rather than the null bulk string
+		if len(args) == 2 {
+			return resp.Value{DataType: resp.TypeArray, IsNull: true}
+		}
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+
+	popped := popElements(list, left, count)
+	db.deleteListIfEmpty(key, list)
+
+	if len(args) == 1 {
+		return resp.Value{DataType: resp.TypeBulk, Bulk: popped[0]}
+	}
+	return bulkArray(popped)
+}
+
+func handleLLen(db *Database, args []resp.Value) resp.Value {
+	list, wrongType := db.loadList(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if list == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(list.len())}
+}
+
+func handleLIndex(db *Database, args []resp.Value) resp.Value {
+	index, err := strconv.Atoi(args[1].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+
+	list, wrongType := db.loadList(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if list == nil {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+
+	if index < 0 {
+		index += list.len()
+	}
+	if index < 0 || index >= list.len() {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+	return resp.Value{DataType: resp.TypeBulk, Bulk: list.at(index)}
+}
+
+func handleLSet(db *Database, args []resp.Value) resp.Value {
+	index, err := strconv.Atoi(args[1].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+
+	list, wrongType := db.loadList(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if list == nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNoSuchKey}
+	}
+
+	if index < 0 {
+		index += list.len()
+	}
+	if index < 0 || index >= list.len() {
+		return resp.Value{DataType: resp.TypeError, Err: errIndexOutOfRange}
+	}
+	list.set(index, args[2].Bulk)
+	signalModified()
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+func handleLInsert(db *Database, args []resp.Value) resp.Value {
+	var after bool
+	switch strings.ToUpper(args[1].Bulk) {
+	case "BEFORE":
+		after = false
+	case "AFTER":
+		after = true
+	default:
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+
+	list, wrongType := db.loadList(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if list == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+
+	pivot := args[2].Bulk
+	for i := 0; i < list.len(); i++ {
+		if list.at(i) != pivot {
+			continue
+		}
+		if after {
+			i++
+		}
+		list.insert(i, args[3].Bulk)
+		signalModified()
+		return resp.Value{DataType: resp.TypeInteger, Num: int64(list.len())}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: -1}
+}
+
+func handleLRem(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	count, err := strconv.Atoi(args[1].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	element := args[2].Bulk
+
+	list, wrongType := db.loadList(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if list == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+
+	var removed int
+	switch {
+	case count > 0:
+		removed = list.removeWhere(func(_ int, value string) bool {
+			if value == element && count > 0 {
+				count--
+				return true
+			}
+			return false
+		})
+	case count < 0:
+		// Find the index of the -count-th match from the tail; every match
+		// at or after it goes.
+		firstRemoved := list.len()
+		for i := list.len() - 1; i >= 0 && count < 0; i-- {
+			if list.at(i) == element {
+				firstRemoved = i
+				count++
+			}
+		}
+		removed = list.removeWhere(func(i int, value string) bool {
+			return i >= firstRemoved && value == element
+		})
+	default:
+		removed = list.removeWhere(func(_ int, value string) bool {
+			return value == element
+		})
+	}
+
+	if removed > 0 {
+		signalModified()
+	}
+	db.deleteListIfEmpty(key, list)
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(removed)}
+}
+
+func handleLTrim(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	start, err := strconv.Atoi(args[1].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	end, err := strconv.Atoi(args[2].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+
+	list, wrongType := db.loadList(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if list == nil {
+		return resp.Value{DataType: resp.TypeString, Str: okResponse}
+	}
+
+	start, end, ok := normalizeListRange(start, end, list.len())
+	if !ok {
+		db.deleteKey(key)
+		return resp.Value{DataType: resp.TypeString, Str: okResponse}
+	}
+	if start > 0 || end < list.len()-1 {
+		for list.len() > end+1 {
+			list.popBack()
+		}
+		for i := 0; i < start; i++ {
+			list.popFront()
+		}
+		signalModified()
+	}
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+func handleLPos(db *Database, args []resp.Value) resp.Value {
+	rank, count, maxLen := 1, -1, 0
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+		value, err := strconv.Atoi(args[i+1].Bulk)
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+		}
+		switch strings.ToUpper(args[i].Bulk) {
+		case "RANK":
+			if value == 0 {
+				return resp.Value{DataType: resp.TypeError, Err: errLPosRankZero}
+			}
+			rank = value
+		case "COUNT":
+			if value < 0 {
+				return resp.Value{DataType: resp.TypeError, Err: errCountNegative}
+			}
+			count = value
+		case "MAXLEN":
+			if value < 0 {
+				return resp.Value{DataType: resp.TypeError, Err: errMaxLenNegative}
+			}
+			maxLen = value
+		default:
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+	}
+
+	list, wrongType := db.loadList(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	// COUNT 0 means "all matches"; without COUNT a single match is replied
+	limit := count
+	if count == -1 {
+		limit = 1
+	}
+
+	var matches []resp.Value
+	if list != nil {
+		skip := rank - 1
+		step, i := 1, 0
+		if rank < 0 {
+			skip = -rank - 1
+			step, i = -1, list.len()-1
+		}
+		for compared := 0; i >= 0 && i < list.len(); i += step {
+			if maxLen > 0 && compared == maxLen {
+				break
+			}
+			compared++
+			if list.at(i) != args[1].Bulk {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			matches = append(matches, resp.Value{DataType: resp.TypeInteger, Num: int64(i)})
+			if limit > 0 && len(matches) == limit {
+				break
+			}
+		}
+	}
+
+	if count == -1 {
+		if len(matches) == 0 {
+			return resp.Value{DataType: resp.TypeNull, IsNull: true}
+		}
+		return matches[0]
+	}
+	if matches == nil {
+		matches = []resp.Value{}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: matches}
+}
+
+// moveElement pops one element from source and pushes it onto destination,
+// reporting ok=false when source is missing.
+func (db *Database) moveElement(source, destination string, fromLeft, toLeft bool) (element string, ok bool, wrongType bool) {
+	srcList, wrongType := db.loadList(source)
+	if wrongType {
+		return "", false, true
+	}
+	dstList, wrongType := db.loadList(destination)
+	if wrongType {
+		return "", false, true
+	}
+	if srcList == nil {
+		return "", false, false
+	}
+
+	if fromLeft {
+		element = srcList.popFront()
+	} else {
+		element = srcList.popBack()
+	}
+	if source != destination {
+		db.deleteListIfEmpty(source, srcList)
+	}
+
+	if dstList == nil {
+		dstList = newDeque()
+		db.storeRecord(destination, Record{Type: TypeList, Value: dstList})
+	}
+	if toLeft {
+		dstList.pushFront(element)
+	} else {
+		dstList.pushBack(element)
+	}
+	signalModified()
+	signalKeyReady(db, destination)
+	return element, true, false
+}
+
+func handleLMove(db *Database, args []resp.Value) resp.Value {
+	fromLeft, okFrom := parseListSide(args[2].Bulk)
+	toLeft, okTo := parseListSide(args[3].Bulk)
+	if !okFrom || !okTo {
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+	return lmoveCommand(db, args[0].Bulk, args[1].Bulk, fromLeft, toLeft)
+}
+
+func handleRPopLPush(db *Database, args []resp.Value) resp.Value {
+	return lmoveCommand(db, args[0].Bulk, args[1].Bulk, false, true)
+}
+
+func lmoveCommand(db *Database, source, destination string, fromLeft, toLeft bool) resp.Value {
+	element, ok, wrongType := db.moveElement(source, destination, fromLeft, toLeft)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if !ok {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+	return resp.Value{DataType: resp.TypeBulk, Bulk: element}
+}
+
+// lmpopArgs is a parsed "numkeys key [key ...] LEFT|RIGHT [COUNT count]"
+// tail as taken by LMPOP.
+type lmpopArgs struct {
+	keys  []string
+	left  bool
+	count int
+}
+
+func parseLMPopArgs(args []resp.Value) (lmpopArgs, string) {
+	var parsed lmpopArgs
+	numKeys, err := strconv.Atoi(args[0].Bulk)
+	if err != nil {
+		return parsed, errNotInteger
+	}
+	if numKeys <= 0 {
+		return parsed, errNumKeysNotPositive
+	}
+	if numKeys > len(args)-2 {
+		return parsed, errSyntax
+	}
+	for _, arg := range args[1 : 1+numKeys] {
+		parsed.keys = append(parsed.keys, arg.Bulk)
+	}
+
+	var ok bool
+	if parsed.left, ok = parseListSide(args[1+numKeys].Bulk); !ok {
+		return parsed, errSyntax
+	}
+
+	parsed.count = 1
+	rest := args[2+numKeys:]
+	if len(rest) == 0 {
+		return parsed, ""
+	}
+	if len(rest) != 2 || strings.ToUpper(rest[0].Bulk) != "COUNT" {
+		return parsed, errSyntax
+	}
+	parsed.count, err = strconv.Atoi(rest[1].Bulk)
+	if err != nil || parsed.count <= 0 {
+		return parsed, errCountNotPositive
+	}
+	return parsed, ""
+}
+
+// popFirstNonEmpty pops from the first non-empty list among keys and
+// returns the LMPOP style [key, [elements]] reply, or ok=false when every
+// list is empty.
+func (db *Database) popFirstNonEmpty(keys []string, left bool, count int) (result resp.Value, ok bool) {
+	for _, key := range keys {
+		list, wrongType := db.loadList(key)
+		if wrongType {
+			return resp.Value{DataType: resp.TypeError, Err: errWrongType}, true
+		}
+		if list == nil {
+			continue
+		}
+
+		popped := popElements(list, left, count)
+		db.deleteListIfEmpty(key, list)
+		return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{
+			{DataType: resp.TypeBulk, Bulk: key},
+			bulkArray(popped),
+		}}, true
+	}
+	return resp.Value{}, false
+}
+
+func handleLMPop(db *Database, args []resp.Value) resp.Value {
+	parsed, errMsg := parseLMPopArgs(args)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+
+	result, ok := db.popFirstNonEmpty(parsed.keys, parsed.left, parsed.count)
+	if !ok {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+	return result
+}
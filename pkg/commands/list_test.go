@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"slices"
+	"testing"
+)
+
+func TestPushAndLRange(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	if reply := handleRPush(db, bulkValues("list", "c", "d")); reply.Num != 2 {
+		t.Fatalf("expected 2, got %+v", reply)
+	}
+	if reply := handleLPush(db, bulkValues("list", "b", "a")); reply.Num != 4 {
+		t.Fatalf("expected 4, got %+v", reply)
+	}
+
+	testCases := []struct {
+		start, stop string
+		expected    []string
+	}{
+		{"0", "-1", []string{"a", "b", "c", "d"}},
+		{"1", "2", []string{"b", "c"}},
+		{"-2", "-1", []string{"c", "d"}},
+		{"-100", "1", []string{"a", "b"}},
+		{"2", "100", []string{"c", "d"}},
+		{"3", "1", []string{}},
+		{"-1", "-2", []string{}},
+		{"10", "20", []string{}},
+	}
+	for _, tc := range testCases {
+		reply := handleLRange(db, bulkValues("list", tc.start, tc.stop))
+		if got := flatten(reply); reply.DataType != resp.TypeArray || !slices.Equal(got, tc.expected) {
+			t.Errorf("LRANGE %s %s: expected %v, got %+v", tc.start, tc.stop, tc.expected, reply)
+		}
+	}
+	if reply := handleLRange(db, bulkValues("missing", "0", "-1")); reply.DataType != resp.TypeArray || len(reply.Array) != 0 {
+		t.Fatalf("expected an empty array for a missing key, got %+v", reply)
+	}
+	if reply := handleLRange(db, bulkValues("list", "x", "1")); reply.Err != errNotInteger {
+		t.Fatalf("expected %q, got %+v", errNotInteger, reply)
+	}
+
+	handleSet(db, bulkValues("str", "v"))
+	for _, reply := range []resp.Value{
+		handleLPush(db, bulkValues("str", "a")),
+		handleRPush(db, bulkValues("str", "a")),
+		handleLPop(db, bulkValues("str")),
+		handleLRange(db, bulkValues("str", "0", "-1")),
+		handleLInsert(db, bulkValues("str", "BEFORE", "a", "b")),
+		handleLRem(db, bulkValues("str", "0", "a")),
+		handleLSet(db, bulkValues("str", "0", "a")),
+		handleLPos(db, bulkValues("str", "a")),
+	} {
+		if reply.Err != errWrongType {
+			t.Errorf("expected %q, got %+v", errWrongType, reply)
+		}
+	}
+}
+
+func TestLPopWithCount(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleRPush(db, bulkValues("list", "a", "b", "c", "d"))
+
+	if reply := handleLPop(db, bulkValues("list")); reply.DataType != resp.TypeBulk || reply.Bulk != "a" {
+		t.Fatalf("expected a, got %+v", reply)
+	}
+	if reply := handleLPop(db, bulkValues("list", "2")); !slices.Equal(flatten(reply), []string{"b", "c"}) {
+		t.Fatalf("expected [b c], got %+v", reply)
+	}
+	if reply := handleLPop(db, bulkValues("list", "0")); reply.DataType != resp.TypeArray || len(reply.Array) != 0 {
+		t.Fatalf("expected an empty array, got %+v", reply)
+	}
+	if reply := handleRPop(db, bulkValues("list", "10")); !slices.Equal(flatten(reply), []string{"d"}) {
+		t.Fatalf("expected [d], got %+v", reply)
+	}
+	if reply := handleExists(db, bulkValues("list")); reply.Num != 0 {
+		t.Fatal("popping the last element left an empty list behind")
+	}
+	if reply := handleLPop(db, bulkValues("list", "2")); reply.DataType != resp.TypeArray || !reply.IsNull {
+		t.Fatalf("expected a null array for a missing key, got %+v", reply)
+	}
+	if reply := handleRPop(db, bulkValues("list")); reply.DataType != resp.TypeNull || !reply.IsNull {
+		t.Fatalf("expected a null bulk string for a missing key, got %+v", reply)
+	}
+
+	for _, count := range []string{"-1", "x"} {
+		if reply := handleLPop(db, bulkValues("list", count)); reply.Err != errNotPositive {
+			t.Errorf("LPOP %s: expected %q, got %+v", count, errNotPositive, reply)
+		}
+	}
+	if reply := handleLPop(db, bulkValues("list", "1", "2")); reply.Err != wrongArityError("LPOP") {
+		t.Fatalf("expected %q, got %+v", wrongArityError("LPOP"), reply)
+	}
+}
+
+func TestLInsertLRemAndLSet(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleRPush(db, bulkValues("list", "a", "x", "b", "x", "c", "x"))
+
+	if reply := handleLInsert(db, bulkValues("list", "BEFORE", "b", "1")); reply.Num != 7 {
+		t.Fatalf("expected 7, got %+v", reply)
+	}
+	if reply := handleLInsert(db, bulkValues("list", "after", "c", "2")); reply.Num != 8 {
+		t.Fatalf("expected 8, got %+v", reply)
+	}
+	if reply := handleLInsert(db, bulkValues("list", "BEFORE", "missing", "3")); reply.Num != -1 {
+		t.Fatalf("expected -1 for a missing pivot, got %+v", reply)
+	}
+	if reply := handleLInsert(db, bulkValues("missing", "BEFORE", "a", "3")); reply.Num != 0 {
+		t.Fatalf("expected 0 for a missing key, got %+v", reply)
+	}
+	if reply := handleLInsert(db, bulkValues("list", "AROUND", "a", "3")); reply.Err != errSyntax {
+		t.Fatalf("expected %q, got %+v", errSyntax, reply)
+	}
+	expected := []string{"a", "x", "1", "b", "x", "c", "2", "x"}
+	if got := flatten(handleLRange(db, bulkValues("list", "0", "-1"))); !slices.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	if reply := handleLRem(db, bulkValues("list", "-1", "x")); reply.Num != 1 {
+		t.Fatalf("expected 1, got %+v", reply)
+	}
+	if reply := handleLRem(db, bulkValues("list", "1", "x")); reply.Num != 1 {
+		t.Fatalf("expected 1, got %+v", reply)
+	}
+	expected = []string{"a", "1", "b", "x", "c", "2"}
+	if got := flatten(handleLRange(db, bulkValues("list", "0", "-1"))); !slices.Equal(got, expected) {
+		t.Fatalf("expected LREM to remove from the given end, got %v", got)
+	}
+	handleRPush(db, bulkValues("list", "x"))
+	if reply := handleLRem(db, bulkValues("list", "0", "x")); reply.Num != 2 {
+		t.Fatalf("expected 2, got %+v", reply)
+	}
+	if reply := handleLRem(db, bulkValues("list", "x", "a")); reply.Err != errNotInteger {
+		t.Fatalf("expected %q, got %+v", errNotInteger, reply)
+	}
+
+	if reply := handleLSet(db, bulkValues("list", "-1", "z")); reply.Str != okResponse {
+		t.Fatalf("LSET failed: %+v", reply)
+	}
+	if reply := handleLIndex(db, bulkValues("list", "-1")); reply.Bulk != "z" {
+		t.Fatalf("expected z, got %+v", reply)
+	}
+
+	testCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{"Out Of Range", []string{"list", "10", "v"}, errIndexOutOfRange},
+		{"Negative Out Of Range", []string{"list", "-10", "v"}, errIndexOutOfRange},
+		{"Missing Key", []string{"missing", "0", "v"}, errNoSuchKey},
+		{"Not A Number", []string{"list", "x", "v"}, errNotInteger},
+	}
+	for _, tc := range testCases {
+		if reply := handleLSet(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+			t.Errorf("%s: expected %q, got %+v", tc.name, tc.expectedErr, reply)
+		}
+	}
+	if _, ok := db.lookupKey("missing"); ok {
+		t.Fatal("LSET on a missing key created it")
+	}
+}
+
+func TestLPos(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleRPush(db, bulkValues("list", "a", "b", "c", "1", "2", "3", "c", "c"))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"All Matches", []string{"list", "c", "COUNT", "0"}, []string{"2", "6", "7"}},
+		{"Rank", []string{"list", "c", "RANK", "2", "COUNT", "0"}, []string{"6", "7"}},
+		{"Negative Rank", []string{"list", "c", "RANK", "-1", "COUNT", "2"}, []string{"7", "6"}},
+		{"MaxLen", []string{"list", "c", "COUNT", "0", "MAXLEN", "3"}, []string{"2"}},
+		{"No Match With Count", []string{"list", "z", "COUNT", "0"}, []string{}},
+		{"Missing Key With Count", []string{"missing", "c", "COUNT", "1"}, []string{}},
+	}
+	for _, tc := range testCases {
+		reply := handleLPos(db, bulkValues(tc.args...))
+		if got := flatten(reply); reply.DataType != resp.TypeArray || !slices.Equal(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %+v", tc.name, tc.expected, reply)
+		}
+	}
+
+	if reply := handleLPos(db, bulkValues("list", "c")); reply.DataType != resp.TypeInteger || reply.Num != 2 {
+		t.Fatalf("expected 2, got %+v", reply)
+	}
+	if reply := handleLPos(db, bulkValues("list", "c", "RANK", "-1")); reply.Num != 7 {
+		t.Fatalf("expected 7, got %+v", reply)
+	}
+	if reply := handleLPos(db, bulkValues("list", "z")); !reply.IsNull {
+		t.Fatalf("expected a null reply for no match, got %+v", reply)
+	}
+
+	errorCases := []struct {
+		args        []string
+		expectedErr string
+	}{
+		{[]string{"list", "c", "RANK", "0"}, errLPosRankZero},
+		{[]string{"list", "c", "COUNT", "-1"}, errCountNegative},
+		{[]string{"list", "c", "MAXLEN", "-1"}, errMaxLenNegative},
+		{[]string{"list", "c", "RANK", "x"}, errNotInteger},
+		{[]string{"list", "c", "RANK"}, errSyntax},
+		{[]string{"list", "c", "FIRST", "1"}, errSyntax},
+	}
+	for _, tc := range errorCases {
+		if reply := handleLPos(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+			t.Errorf("LPOS %v: expected %q, got %+v", tc.args, tc.expectedErr, reply)
+		}
+	}
+}
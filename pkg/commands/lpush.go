@@ -4,36 +4,44 @@ import (
 	"go-redis/pkg/resp"
 )
 
-func handleLPush(args []resp.Value) resp.Value {
-	if len(args) < 2 {
-		return resp.Value{DataType: resp.TypeError, Err: errWrongArgsCount}
-	}
+func handleLPush(db *Database, args []resp.Value) resp.Value {
+	return pushCommand(db, args, true, false)
+}
+
+func handleLPushX(db *Database, args []resp.Value) resp.Value {
+	return pushCommand(db, args, true, true)
+}
 
+// pushCommand backs the LPUSH/RPUSH family. With onlyIfExists set nothing
+// is created when the key is missing.
+func pushCommand(db *Database, args []resp.Value, left bool, onlyIfExists bool) resp.Value {
 	key := args[0].Bulk
-	elements := make([]string, len(args)-1)
-	for i := 1; i < len(args); i++ {
-		elements[i-1] = args[i].Bulk
+	list, wrongType := db.loadList(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
 	}
-
-	var list []string
-	if value, ok := dataSet.Load(key); ok {
-		record := value.(Record)
-		if record.Type != TypeList {
-			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	if list == nil {
+		if onlyIfExists {
+			return resp.Value{DataType: resp.TypeInteger, Num: 0}
 		}
-		list = record.Value.([]string)
+		list = newDeque()
+		db.storeRecord(key, Record{Type: TypeList, Value: list})
 	}
 
-	// Prepend new elements to the list in reverse order
-	newList := make([]string, len(elements)+len(list))
-	for i := len(elements) - 1; i >= 0; i-- {
-		newList[len(elements)-1-i] = elements[i]
+	// Elements are pushed one at a time, so LPUSH ends up with them in
+	// reverse order at the head of the list
+	for _, arg := range args[1:] {
+		if left {
+			list.pushFront(arg.Bulk)
+		} else {
+			list.pushBack(arg.Bulk)
+		}
 	}
-	copy(newList[len(elements):], list)
 
-	// Store the updated list
-	dataSet.Store(key, Record{Type: TypeList, Value: newList})
+	length := list.len()
+	signalModified()
+	signalKeyReady(db, key)
 
 	// Return the new length of the list
-	return resp.Value{DataType: resp.TypeInteger, Num: len(newList)}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(length)}
 }
@@ -5,11 +5,7 @@ import (
 	"strconv"
 )
 
-func handleLRange(args []resp.Value) resp.Value {
-	if len(args) != 3 {
-		return resp.Value{DataType: resp.TypeError, Err: errWrongArgsCount}
-	}
-
+func handleLRange(db *Database, args []resp.Value) resp.Value {
 	key := args[0].Bulk
 	start, err := strconv.Atoi(args[1].Bulk)
 	if err != nil {
@@ -20,19 +16,30 @@ func handleLRange(args []resp.Value) resp.Value {
 		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
 	}
 
-	value, ok := dataSet.Load(key)
+	list, wrongType := db.loadList(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if list == nil {
+		return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{}}
+	}
+
+	start, end, ok := normalizeListRange(start, end, list.len())
 	if !ok {
 		return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{}}
 	}
 
-	record := value.(Record)
-	if record.Type != TypeList {
-		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	result := make([]resp.Value, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: list.at(i)})
 	}
 
-	list := record.Value.([]string)
-	listLen := len(list)
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
 
+// normalizeListRange resolves negative indices and clamps [start, end] to a
+// list of listLen elements. ok is false when the range selects nothing.
+func normalizeListRange(start, end, listLen int) (int, int, bool) {
 	// Handle negative indices
 	if start < 0 {
 		start = listLen + start
@@ -49,15 +56,9 @@ func handleLRange(args []resp.Value) resp.Value {
 		end = listLen - 1
 	}
 
-	// If start is greater than end or start is beyond the list, return empty array
+	// If start is greater than end or start is beyond the list, the range is empty
 	if start > end || start >= listLen {
-		return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{}}
+		return 0, 0, false
 	}
-
-	result := make([]resp.Value, 0, end-start+1)
-	for i := start; i <= end; i++ {
-		result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: list[i]})
-	}
-
-	return resp.Value{DataType: resp.TypeArray, Array: result}
+	return start, end, true
 }
@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+const (
+	errMultiNested      = "ERR MULTI calls can not be nested"
+	errExecWithoutMulti = "ERR EXEC without MULTI"
+	errDiscardNoMulti   = "ERR DISCARD without MULTI"
+	errWatchInMulti     = "ERR WATCH inside MULTI is not allowed"
+	errNotInMulti       = "ERR Command not allowed inside a transaction"
+	errExecAbort        = "EXECABORT Transaction discarded because of previous errors."
+	queuedResponse      = "QUEUED"
+)
+
+// queuedCommand is a command waiting for EXEC, with its handler already
+// looked up.
+type queuedCommand struct {
+	name string
+	run  func() resp.Value
+}
+
+var (
+	// transactionCommands run right away even inside MULTI.
+	transactionCommands = map[string]bool{
+		"MULTI":   true,
+		"EXEC":    true,
+		"DISCARD": true,
+		"WATCH":   true,
+		"UNWATCH": true,
+	}
+
+	// noMultiCommands may not be queued: they either take the keyspace lock
+	// themselves or reply more than once.
+	noMultiCommands = map[string]bool{
+		"SAVE":         true,
+		"BGSAVE":       true,
+		"BGREWRITEAOF": true,
+		"SUBSCRIBE":    true,
+		"UNSUBSCRIBE":  true,
+		"PSUBSCRIBE":   true,
+		"PUNSUBSCRIBE": true,
+	}
+
+	// watchMu guards watchedKeys and the watch state kept on every Client.
+	watchMu     sync.Mutex
+	watchedKeys = make(map[dbKey]map[*Client]struct{})
+)
+
+// queueCommand adds a command to the client's transaction. A command that
+// could never run is refused here, and makes EXEC discard the transaction.
+func queueCommand(client *Client, name string, args []resp.Value) resp.Value {
+	if noMultiCommands[name] {
+		client.multiError = true
+		return resp.Value{DataType: resp.TypeError, Err: errNotInMulti}
+	}
+
+	// The connection reuses the array of arguments for its next request.
+	// A queued SELECT changes the database of the commands after it, so
+	// the database is only looked up when the command runs.
+	args = slices.Clone(args)
+	var run func() resp.Value
+	if handler, ok := CommandHandler[name]; ok {
+		run = func() resp.Value { return callCommand(client.database(), name, handler, args) }
+	} else if handler, ok := ClientCommandHandler[name]; ok {
+		run = func() resp.Value { return handler(client, args) }
+	} else {
+		handler := AdminCommandHandler[name]
+		run = func() resp.Value { return handler(args) }
+	}
+	client.queued = append(client.queued, queuedCommand{name: name, run: run})
+	return resp.Value{DataType: resp.TypeString, Str: queuedResponse}
+}
+
+func resetTransaction(client *Client) {
+	client.inMulti = false
+	client.multiError = false
+	client.queued = nil
+	unwatchAll(client)
+}
+
+func handleMulti(client *Client, args []resp.Value) resp.Value {
+	if client.inMulti {
+		return resp.Value{DataType: resp.TypeError, Err: errMultiNested}
+	}
+	client.inMulti = true
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+func handleDiscard(client *Client, args []resp.Value) resp.Value {
+	if !client.inMulti {
+		return resp.Value{DataType: resp.TypeError, Err: errDiscardNoMulti}
+	}
+	resetTransaction(client)
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+// handleExec runs the queued commands with the keyspace to itself, so no
+// other client sees the transaction half applied. It replies with a null
+// array instead if a watched key was modified since WATCH.
+func handleExec(client *Client, args []resp.Value) resp.Value {
+	if !client.inMulti {
+		return resp.Value{DataType: resp.TypeError, Err: errExecWithoutMulti}
+	}
+	defer resetTransaction(client)
+	if client.multiError {
+		return resp.Value{DataType: resp.TypeError, Err: errExecAbort}
+	}
+
+	lockKeyspace()
+	defer unlockKeyspace()
+
+	// A watched key that expired in the meantime counts as modified; looking
+	// it up deletes it, which touches it
+	watchMu.Lock()
+	watched := make([]dbKey, 0, len(client.watched))
+	for key := range client.watched {
+		watched = append(watched, key)
+	}
+	watchMu.Unlock()
+	for _, key := range watched {
+		databases[key.db].lookupKey(key.key)
+	}
+
+	watchMu.Lock()
+	aborted := client.dirtyCAS
+	watchMu.Unlock()
+	if aborted {
+		return resp.Value{DataType: resp.TypeArray, IsNull: true}
+	}
+
+	beginPropagatedTransaction()
+	defer endPropagatedTransaction()
+	client.inExec = true
+	defer func() { client.inExec = false }()
+
+	results := make([]resp.Value, len(client.queued))
+	for i, queued := range client.queued {
+		results[i] = queued.run()
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: results}
+}
+
+func handleWatch(client *Client, args []resp.Value) resp.Value {
+	if client.inMulti {
+		return resp.Value{DataType: resp.TypeError, Err: errWatchInMulti}
+	}
+
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	for _, arg := range args {
+		key := dbKey{client.db, arg.Bulk}
+		if _, ok := client.watched[key]; ok {
+			continue
+		}
+		client.watched[key] = struct{}{}
+		if watchedKeys[key] == nil {
+			watchedKeys[key] = make(map[*Client]struct{})
+		}
+		watchedKeys[key][client] = struct{}{}
+	}
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+func handleUnwatch(client *Client, args []resp.Value) resp.Value {
+	unwatchAll(client)
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+func unwatchAll(client *Client) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	for key := range client.watched {
+		delete(watchedKeys[key], client)
+		if len(watchedKeys[key]) == 0 {
+			delete(watchedKeys, key)
+		}
+	}
+	client.watched = make(map[dbKey]struct{})
+	client.dirtyCAS = false
+}
+
+// touchKeys marks keys of db as modified for every client watching them,
+// which makes their next EXEC fail.
+func touchKeys(db *Database, keys []string) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if len(watchedKeys) == 0 {
+		return
+	}
+	for _, key := range keys {
+		for client := range watchedKeys[dbKey{db.id, key}] {
+			client.dirtyCAS = true
+		}
+	}
+}
+
+// touchDatabase marks the watched keys of db as modified if they exist in
+// db or in other, whose contents db is about to be given. A nil other
+// means db is about to be emptied.
+func touchDatabase(db, other *Database) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	for key, clients := range watchedKeys {
+		if key.db != db.id {
+			continue
+		}
+		_, exists := db.data.Load(key.key)
+		if !exists && other != nil {
+			_, exists = other.data.Load(key.key)
+		}
+		if exists {
+			for client := range clients {
+				client.dirtyCAS = true
+			}
+		}
+	}
+}
+
+// Key extractors for writeCommands.
+
+func noKeys(args []resp.Value) []string {
+	return nil
+}
+
+func firstKey(args []resp.Value) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	return []string{args[0].Bulk}
+}
+
+func allKeys(args []resp.Value) []string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = arg.Bulk
+	}
+	return keys
+}
+
+func secondKey(args []resp.Value) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	return []string{args[1].Bulk}
+}
+
+func firstTwoKeys(args []resp.Value) []string {
+	return allKeys(args[:min(len(args), 2)])
+}
+
+// alternateKeys extracts the keys of commands of the form
+// "key value [key value ...]", such as MSET.
+func alternateKeys(args []resp.Value) []string {
+	keys := make([]string, 0, (len(args)+1)/2)
+	for i := 0; i < len(args); i += 2 {
+		keys = append(keys, args[i].Bulk)
+	}
+	return keys
+}
+
+// numKeysKeys extracts the keys of commands of the form
+// "numkeys key [key ...] ...", such as LMPOP.
+func numKeysKeys(args []resp.Value) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(args[0].Bulk)
+	if err != nil || n < 0 {
+		return nil
+	}
+	return allKeys(args[1:min(len(args), 1+n)])
+}
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExecRunsQueuedCommands(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	client := NewClient()
+
+	Execute(client, "MULTI", nil)
+	if reply, _ := Execute(client, "SET", bulkValues("key", "1")); reply.Str != queuedResponse {
+		t.Fatalf("expected SET to be queued, got %+v", reply)
+	}
+	Execute(client, "INCR", bulkValues("key"))
+	Execute(client, "LPUSH", bulkValues("key", "x"))
+	Execute(client, "GET", bulkValues("key"))
+	if _, ok := db.lookupKey("key"); ok {
+		t.Fatal("a queued command ran before EXEC")
+	}
+
+	reply, _ := Execute(client, "EXEC", nil)
+	if len(reply.Array) != 4 {
+		t.Fatalf("expected 4 results, got %+v", reply)
+	}
+	if reply.Array[1].Num != 2 || reply.Array[2].Err != errWrongType || reply.Array[3].Bulk != "2" {
+		t.Fatalf("unexpected results %+v", reply.Array)
+	}
+	if reply, _ := Execute(client, "EXEC", nil); reply.Err != errExecWithoutMulti {
+		t.Fatalf("expected EXEC without MULTI to fail, got %+v", reply)
+	}
+}
+
+func TestExecAbortsAfterQueueingErrors(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	client := NewClient()
+
+	Execute(client, "MULTI", nil)
+	Execute(client, "SET", bulkValues("key", "v"))
+	if reply, _ := Execute(client, "GET", nil); reply.DataType != resp.TypeError {
+		t.Fatalf("expected GET without a key to be refused, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EXEC", nil); reply.Err != errExecAbort {
+		t.Fatalf("expected EXECABORT, got %+v", reply)
+	}
+	if _, ok := db.lookupKey("key"); ok {
+		t.Fatal("an aborted transaction was applied")
+	}
+
+	Execute(client, "MULTI", nil)
+	if _, ok := Execute(client, "NOSUCHCOMMAND", nil); ok {
+		t.Fatal("an unknown command was accepted")
+	}
+	if reply, _ := Execute(client, "EXEC", nil); reply.Err != errExecAbort {
+		t.Fatalf("expected EXECABORT after an unknown command, got %+v", reply)
+	}
+
+	Execute(client, "MULTI", nil)
+	Execute(client, "SET", bulkValues("key", "v"))
+	if reply, _ := Execute(client, "DISCARD", nil); reply.Str != okResponse {
+		t.Fatalf("DISCARD failed: %+v", reply)
+	}
+	if reply, _ := Execute(client, "GET", bulkValues("key")); !reply.IsNull {
+		t.Fatalf("a discarded command ran: %+v", reply)
+	}
+}
+
+func TestWatchedKeyModificationAbortsExec(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	client := NewClient()
+	other := NewClient()
+
+	Execute(client, "WATCH", bulkValues("balance"))
+	Execute(other, "SET", bulkValues("balance", "100"))
+	Execute(client, "MULTI", nil)
+	Execute(client, "SET", bulkValues("balance", "0"))
+	if reply, _ := Execute(client, "EXEC", nil); reply.DataType != resp.TypeArray || !reply.IsNull {
+		t.Fatalf("expected a null array, got %+v", reply)
+	}
+	if value, _, _ := db.loadString("balance"); value != "100" {
+		t.Fatalf("the aborted transaction overwrote the key: %q", value)
+	}
+
+	// EXEC drops the watches whatever its outcome
+	Execute(client, "MULTI", nil)
+	Execute(client, "SET", bulkValues("balance", "0"))
+	if reply, _ := Execute(client, "EXEC", nil); len(reply.Array) != 1 {
+		t.Fatalf("expected the unwatched transaction to run, got %+v", reply)
+	}
+
+	// A watched key that expires counts as modified
+	Execute(client, "SET", bulkValues("lease", "x", "PX", "1"))
+	Execute(client, "WATCH", bulkValues("lease"))
+	Execute(client, "MULTI", nil)
+	Execute(client, "PING", nil)
+	time.Sleep(5 * time.Millisecond)
+	if reply, _ := Execute(client, "EXEC", nil); !reply.IsNull {
+		t.Fatalf("expected the expiry to abort the transaction, got %+v", reply)
+	}
+}
+
+func TestBlockingPopInsideExecDoesNotBlock(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+
+	Execute(client, "MULTI", nil)
+	Execute(client, "BLPOP", bulkValues("empty", "0"))
+	reply, _ := Execute(client, "EXEC", nil)
+	if len(reply.Array) != 1 || !reply.Array[0].IsNull {
+		t.Fatalf("expected BLPOP to return nil inside EXEC, got %+v", reply)
+	}
+}
+
+func TestAppendOnlyDropsUnterminatedTransaction(t *testing.T) {
+	db := databases[0]
+	path := openTestAppendOnly(t)
+	client := NewClient()
+
+	Execute(client, "SET", bulkValues("before", "1"))
+	Execute(client, "MULTI", nil)
+	Execute(client, "SET", bulkValues("a", "1"))
+	Execute(client, "GET", bulkValues("a"))
+	Execute(client, "SET", bulkValues("b", "2"))
+	Execute(client, "EXEC", nil)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ""
+	for _, command := range [][]string{
+		{"SELECT", "0"}, {"SET", "before", "1"}, {"MULTI"}, {"SET", "a", "1"}, {"SET", "b", "2"}, {"EXEC"},
+	} {
+		expected += string(resp.Value{DataType: resp.TypeArray, Array: bulkValues(command...)}.Serialize())
+	}
+	if string(data) != expected {
+		t.Fatalf("expected the transaction to be logged as\n%q\ngot\n%q", expected, data)
+	}
+
+	// Lose the EXEC, as if the server died while writing it
+	exec := resp.Value{DataType: resp.TypeArray, Array: bulkValues("EXEC")}.Serialize()
+	if err := os.WriteFile(path, data[:len(data)-len(exec)], 0644); err != nil {
+		t.Fatal(err)
+	}
+	reload(t, path)
+	if _, ok := db.lookupKey("a"); ok {
+		t.Fatal("half a transaction was replayed")
+	}
+	if _, ok := db.lookupKey("before"); !ok {
+		t.Fatal("the command before the transaction was lost")
+	}
+	kept := len(resp.Value{DataType: resp.TypeArray, Array: bulkValues("SELECT", "0")}.Serialize()) +
+		len(resp.Value{DataType: resp.TypeArray, Array: bulkValues("SET", "before", "1")}.Serialize())
+	if info, _ := os.Stat(path); info.Size() != int64(kept) {
+		t.Fatalf("expected the transaction to be truncated away, file is %d bytes", info.Size())
+	}
+}
@@ -2,7 +2,7 @@ package commands
 
 import "go-redis/pkg/resp"
 
-func handlePing(args []resp.Value) resp.Value {
+func handlePing(db *Database, args []resp.Value) resp.Value {
 	if len(args) == 0 {
 		return resp.Value{DataType: resp.TypeString, Str: "PONG"}
 	}
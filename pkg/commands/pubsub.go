@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	errPubSubUnknownSubcommand = "ERR unknown subcommand or wrong number of arguments for 'PUBSUB' command"
+)
+
+var (
+	// pubsubMu guards both registries and the subscriptions kept on every
+	// Client.
+	pubsubMu         sync.RWMutex
+	channelClients   = make(map[string]map[*Client]struct{})
+	patternClients   = make(map[string]map[*Client]struct{})
+	subscribedModeOK = map[string]bool{
+		"SUBSCRIBE":    true,
+		"UNSUBSCRIBE":  true,
+		"PSUBSCRIBE":   true,
+		"PUNSUBSCRIBE": true,
+		"PING":         true,
+	}
+)
+
+// subscriptionCountLocked is the number reported back with every
+// (un)subscribe confirmation.
+func (c *Client) subscriptionCountLocked() int {
+	return len(c.channels) + len(c.patterns)
+}
+
+// subscribed reports whether the client is in subscriber mode, where only
+// the pub/sub commands and PING may be used.
+func (c *Client) subscribed() bool {
+	pubsubMu.RLock()
+	defer pubsubMu.RUnlock()
+	return c.subscriptionCountLocked() > 0
+}
+
+// subscribedModeReply answers a command issued by a client in subscriber
+// mode. It reports false if the command should run as usual. RESP3 tells
+// messages apart from replies, so there clients may use any command.
+func subscribedModeReply(client *Client, name string, args []resp.Value) (resp.Value, bool) {
+	if client.Protocol() >= 3 || !client.subscribed() {
+		return resp.Value{}, false
+	}
+	if !subscribedModeOK[name] {
+		return resp.Value{DataType: resp.TypeError, Err: "ERR Can't execute '" + strings.ToLower(name) +
+			"': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING are allowed in this context"}, true
+	}
+	if name == "PING" {
+		message := ""
+		if len(args) > 0 {
+			message = args[0].Bulk
+		}
+		return resp.Value{DataType: resp.TypeArray, Array: bulkValues("pong", message)}, true
+	}
+	return resp.Value{}, false
+}
+
+// subscriptionReply is the confirmation sent for every channel or pattern
+// a client (un)subscribes from. Like messages, it is a push for RESP3
+// clients.
+func subscriptionReply(kind, name string, count int) resp.Value {
+	return resp.Value{DataType: resp.TypePush, Array: []resp.Value{
+		{DataType: resp.TypeBulk, Bulk: kind},
+		{DataType: resp.TypeBulk, Bulk: name},
+		{DataType: resp.TypeInteger, Num: int64(count)},
+	}}
+}
+
+// replyAll sends every reply but the last through the client's queue and
+// returns the last one, which the caller queues as usual. Commands such as
+// SUBSCRIBE answer with one reply per argument this way.
+func replyAll(client *Client, replies []resp.Value) resp.Value {
+	for _, reply := range replies[:len(replies)-1] {
+		client.Reply(reply)
+	}
+	return replies[len(replies)-1]
+}
+
+func handleSubscribe(client *Client, args []resp.Value) resp.Value {
+	return subscribeCommand(client, args, "subscribe", channelClients, func(c *Client) map[string]struct{} { return c.channels })
+}
+
+func handlePSubscribe(client *Client, args []resp.Value) resp.Value {
+	return subscribeCommand(client, args, "psubscribe", patternClients, func(c *Client) map[string]struct{} { return c.patterns })
+}
+
+func subscribeCommand(client *Client, args []resp.Value, kind string, registry map[string]map[*Client]struct{}, subscriptions func(*Client) map[string]struct{}) resp.Value {
+	pubsubMu.Lock()
+	replies := make([]resp.Value, len(args))
+	for i, arg := range args {
+		name := arg.Bulk
+		if _, ok := subscriptions(client)[name]; !ok {
+			subscriptions(client)[name] = struct{}{}
+			if registry[name] == nil {
+				registry[name] = make(map[*Client]struct{})
+			}
+			registry[name][client] = struct{}{}
+		}
+		replies[i] = subscriptionReply(kind, name, client.subscriptionCountLocked())
+	}
+	pubsubMu.Unlock()
+	return replyAll(client, replies)
+}
+
+func handleUnsubscribe(client *Client, args []resp.Value) resp.Value {
+	return unsubscribeCommand(client, args, "unsubscribe", channelClients, func(c *Client) map[string]struct{} { return c.channels })
+}
+
+func handlePUnsubscribe(client *Client, args []resp.Value) resp.Value {
+	return unsubscribeCommand(client, args, "punsubscribe", patternClients, func(c *Client) map[string]struct{} { return c.patterns })
+}
+
+// unsubscribeCommand drops the given subscriptions, or all of them of its
+// kind when called without arguments.
+func unsubscribeCommand(client *Client, args []resp.Value, kind string, registry map[string]map[*Client]struct{}, subscriptions func(*Client) map[string]struct{}) resp.Value {
+	pubsubMu.Lock()
+	names := make([]string, 0, len(args))
+	for _, arg := range args {
+		names = append(names, arg.Bulk)
+	}
+	if len(args) == 0 {
+		for name := range subscriptions(client) {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	if len(names) == 0 {
+		count := client.subscriptionCountLocked()
+		pubsubMu.Unlock()
+		return resp.Value{DataType: resp.TypePush, Array: []resp.Value{
+			{DataType: resp.TypeBulk, Bulk: kind},
+			{DataType: resp.TypeNull, IsNull: true},
+			{DataType: resp.TypeInteger, Num: int64(count)},
+		}}
+	}
+
+	replies := make([]resp.Value, len(names))
+	for i, name := range names {
+		unsubscribeLocked(client, name, registry, subscriptions(client))
+		replies[i] = subscriptionReply(kind, name, client.subscriptionCountLocked())
+	}
+	pubsubMu.Unlock()
+	return replyAll(client, replies)
+}
+
+func unsubscribeLocked(client *Client, name string, registry map[string]map[*Client]struct{}, subscriptions map[string]struct{}) {
+	delete(subscriptions, name)
+	delete(registry[name], client)
+	if len(registry[name]) == 0 {
+		delete(registry, name)
+	}
+}
+
+// unsubscribeAll drops every subscription of a client that went away.
+func unsubscribeAll(client *Client) {
+	pubsubMu.Lock()
+	defer pubsubMu.Unlock()
+	for name := range client.channels {
+		unsubscribeLocked(client, name, channelClients, client.channels)
+	}
+	for pattern := range client.patterns {
+		unsubscribeLocked(client, pattern, patternClients, client.patterns)
+	}
+}
+
+func handlePublish(db *Database, args []resp.Value) resp.Value {
+	channel, message := args[0].Bulk, args[1].Bulk
+
+	// Messages are queued after the lock is released, because queueing may
+	// disconnect a slow subscriber, which unsubscribes it.
+	type delivery struct {
+		client  *Client
+		message resp.Value
+	}
+	var deliveries []delivery
+
+	pubsubMu.RLock()
+	if clients := channelClients[channel]; len(clients) > 0 {
+		msg := resp.Value{DataType: resp.TypePush, Array: bulkValues("message", channel, message)}
+		for client := range clients {
+			deliveries = append(deliveries, delivery{client, msg})
+		}
+	}
+	for pattern, clients := range patternClients {
+		if !globMatch(pattern, channel) {
+			continue
+		}
+		msg := resp.Value{DataType: resp.TypePush, Array: bulkValues("pmessage", pattern, channel, message)}
+		for client := range clients {
+			deliveries = append(deliveries, delivery{client, msg})
+		}
+	}
+	pubsubMu.RUnlock()
+
+	for _, d := range deliveries {
+		d.client.push(d.message)
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(len(deliveries))}
+}
+
+func handlePubSub(db *Database, args []resp.Value) resp.Value {
+	pubsubMu.RLock()
+	defer pubsubMu.RUnlock()
+
+	switch strings.ToUpper(args[0].Bulk) {
+	case "CHANNELS":
+		if len(args) > 2 {
+			break
+		}
+		channels := make([]string, 0, len(channelClients))
+		for channel := range channelClients {
+			if len(args) == 1 || globMatch(args[1].Bulk, channel) {
+				channels = append(channels, channel)
+			}
+		}
+		sort.Strings(channels)
+		return resp.Value{DataType: resp.TypeArray, Array: bulkValues(channels...)}
+	case "NUMSUB":
+		counts := make([]resp.Value, 0, 2*(len(args)-1))
+		for _, arg := range args[1:] {
+			counts = append(counts,
+				resp.Value{DataType: resp.TypeBulk, Bulk: arg.Bulk},
+				resp.Value{DataType: resp.TypeInteger, Num: int64(len(channelClients[arg.Bulk]))})
+		}
+		return resp.Value{DataType: resp.TypeArray, Array: counts}
+	case "NUMPAT":
+		if len(args) != 1 {
+			break
+		}
+		return resp.Value{DataType: resp.TypeInteger, Num: int64(len(patternClients))}
+	}
+	return resp.Value{DataType: resp.TypeError, Err: errPubSubUnknownSubcommand}
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strconv"
+	"testing"
+)
+
+// drain returns everything queued on the client so far.
+func drain(client *Client) []resp.Value {
+	var values []resp.Value
+	for {
+		select {
+		case v := <-client.Replies():
+			values = append(values, v)
+		default:
+			return values
+		}
+	}
+}
+
+func flatten(v resp.Value) []string {
+	var out []string
+	for _, element := range v.Array {
+		switch element.DataType {
+		case resp.TypeInteger:
+			out = append(out, strconv.FormatInt(element.Num, 10))
+		case resp.TypeNull:
+			out = append(out, "nil")
+		default:
+			out = append(out, element.Bulk)
+		}
+	}
+	return out
+}
+
+func expectMessages(t *testing.T, values []resp.Value, expected ...[]string) {
+	t.Helper()
+	if len(values) != len(expected) {
+		t.Fatalf("expected %d messages, got %d: %+v", len(expected), len(values), values)
+	}
+	for i, v := range values {
+		got := flatten(v)
+		if len(got) != len(expected[i]) {
+			t.Fatalf("message %d: expected %v, got %v", i, expected[i], got)
+		}
+		for j := range got {
+			if got[j] != expected[i][j] {
+				t.Fatalf("message %d: expected %v, got %v", i, expected[i], got)
+			}
+		}
+	}
+}
+
+func TestPublishReachesChannelAndPatternSubscribers(t *testing.T) {
+	subscriber := NewClient()
+	defer subscriber.Close()
+	watcher := NewClient()
+	defer watcher.Close()
+	publisher := NewClient()
+
+	reply, _ := Execute(subscriber, "SUBSCRIBE", bulkValues("news", "sports"))
+	expectMessages(t, append(drain(subscriber), reply),
+		[]string{"subscribe", "news", "1"},
+		[]string{"subscribe", "sports", "2"})
+	reply, _ = Execute(watcher, "PSUBSCRIBE", bulkValues("n*"))
+	expectMessages(t, append(drain(watcher), reply), []string{"psubscribe", "n*", "1"})
+
+	if reply, _ := Execute(publisher, "PUBLISH", bulkValues("news", "hello")); reply.Num != 2 {
+		t.Fatalf("expected 2 receivers, got %d", reply.Num)
+	}
+	if reply, _ := Execute(publisher, "PUBLISH", bulkValues("weather", "rain")); reply.Num != 0 {
+		t.Fatalf("expected no receivers, got %d", reply.Num)
+	}
+	pong, _ := Execute(subscriber, "PING", nil)
+	expectMessages(t, append(drain(subscriber), pong),
+		[]string{"message", "news", "hello"},
+		[]string{"pong", ""})
+	expectMessages(t, drain(watcher), []string{"pmessage", "n*", "news", "hello"})
+
+	reply, _ = Execute(publisher, "PUBSUB", bulkValues("CHANNELS"))
+	expectMessages(t, []resp.Value{reply}, []string{"news", "sports"})
+	reply, _ = Execute(publisher, "PUBSUB", bulkValues("NUMSUB", "news", "none"))
+	expectMessages(t, []resp.Value{reply}, []string{"news", "1", "none", "0"})
+	if reply, _ := Execute(publisher, "PUBSUB", bulkValues("NUMPAT")); reply.Num != 1 {
+		t.Fatalf("expected 1 pattern, got %d", reply.Num)
+	}
+
+	reply, _ = Execute(subscriber, "UNSUBSCRIBE", nil)
+	expectMessages(t, append(drain(subscriber), reply),
+		[]string{"unsubscribe", "news", "1"},
+		[]string{"unsubscribe", "sports", "0"})
+	reply, _ = Execute(subscriber, "UNSUBSCRIBE", nil)
+	expectMessages(t, append(drain(subscriber), reply), []string{"unsubscribe", "nil", "0"})
+
+	watcher.Close()
+	if reply, _ := Execute(publisher, "PUBSUB", bulkValues("NUMPAT")); reply.Num != 0 {
+		t.Fatalf("a closed client kept its pattern subscription")
+	}
+}
+
+func TestSubscribedClientsCanOnlyUsePubSubCommands(t *testing.T) {
+	client := NewClient()
+	defer client.Close()
+
+	Execute(client, "SUBSCRIBE", bulkValues("channel"))
+	reply, _ := Execute(client, "GET", bulkValues("key"))
+	expected := "ERR Can't execute 'get': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING are allowed in this context"
+	if reply.Err != expected {
+		t.Fatalf("expected GET to be refused in subscriber mode, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "PING", nil); len(reply.Array) != 2 || reply.Array[0].Bulk != "pong" {
+		t.Fatalf("expected PING to work in subscriber mode, got %+v", reply)
+	}
+
+	Execute(client, "UNSUBSCRIBE", bulkValues("channel"))
+	if reply, _ := Execute(client, "GET", bulkValues("key")); reply.DataType == resp.TypeError {
+		t.Fatalf("expected GET to work again after unsubscribing, got %+v", reply)
+	}
+}
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "SUBSCRIBE", bulkValues("firehose"))
+
+	for i := 0; i <= clientOutputBuffer; i++ {
+		Execute(NewClient(), "PUBLISH", bulkValues("firehose", "x"))
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Fatal("expected the subscriber to be disconnected once its buffer filled up")
+	}
+	if reply, _ := Execute(NewClient(), "PUBSUB", bulkValues("NUMSUB", "firehose")); reply.Array[1].Num != 0 {
+		t.Fatal("a disconnected subscriber kept its subscription")
+	}
+}
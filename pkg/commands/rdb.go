@@ -0,0 +1,518 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-redis/pkg/resp"
+	"hash/crc64"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A snapshot file is laid out as
+//
+//	"GOREDIS" version
+//	{ rdbOpSelectDB db { [rdbOpExpireMs unix-ms] type key value } }
+//	rdbOpEOF crc64
+//
+// Lengths are unsigned varints, strings are a length followed by their
+// bytes, and unix-ms, scores and the checksum are 8 bytes little endian.
+// Lists, sets, sorted sets and hashes are an element count followed by
+// their elements, where a sorted set element is a member and its score and
+// a hash element is a field and its value. The checksum is the CRC-64
+// (ECMA) of everything before it. Only databases holding keys are
+// written.
+
+const (
+	rdbMagic   = "GOREDIS"
+	rdbVersion = 1
+
+	rdbTypeString = 0
+	rdbTypeList   = 1
+	rdbTypeSet    = 2
+	rdbTypeZSet   = 3
+	rdbTypeHash   = 4
+
+	rdbOpExpireMs = 0xFC
+	rdbOpSelectDB = 0xFE
+	rdbOpEOF      = 0xFF
+)
+
+const (
+	errBackgroundSaveInProgress = "ERR Background save already in progress"
+	errSaveFailed               = "ERR snapshot could not be saved, check the server log"
+	backgroundSavingStarted     = "Background saving started"
+)
+
+var (
+	errRDBBadHeader   = errors.New("not a go-redis snapshot file")
+	errRDBBadChecksum = errors.New("snapshot checksum mismatch")
+	errRDBBadType     = errors.New("snapshot holds an unknown value type")
+
+	crcTable = crc64.MakeTable(crc64.ECMA)
+)
+
+var (
+	snapshotPath = "dump.rdb"
+
+	// dirty counts the changes made since the last successful save.
+	dirty    atomic.Int64
+	lastSave atomic.Int64
+
+	// saveMu is held for as long as a snapshot is being written, so at
+	// most one SAVE or BGSAVE runs at a time.
+	saveMu sync.Mutex
+)
+
+func init() {
+	lastSave.Store(time.Now().Unix())
+}
+
+// SaveRule triggers a background save once at least Changes writes were
+// made and Seconds have passed since the last save.
+type SaveRule struct {
+	Seconds int64
+	Changes int64
+}
+
+// ParseSaveRules parses rules in the format of the Redis "save" directive,
+// e.g. "3600 1 300 100". An empty string disables automatic saving.
+func ParseSaveRules(s string) ([]SaveRule, error) {
+	fields := strings.Fields(s)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid save rules %q: expected pairs of seconds and changes", s)
+	}
+	rules := make([]SaveRule, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err1 := strconv.ParseInt(fields[i], 10, 64)
+		changes, err2 := strconv.ParseInt(fields[i+1], 10, 64)
+		if err1 != nil || err2 != nil || seconds <= 0 || changes <= 0 {
+			return nil, fmt.Errorf("invalid save rule %q", fields[i]+" "+fields[i+1])
+		}
+		rules = append(rules, SaveRule{Seconds: seconds, Changes: changes})
+	}
+	return rules, nil
+}
+
+// SetSnapshotPath sets the file SAVE and BGSAVE write to.
+func SetSnapshotPath(path string) {
+	snapshotPath = path
+}
+
+// LoadSnapshot fills the keyspace from the snapshot at path. A missing file
+// is not an error; the server simply starts empty. It returns the number of
+// keys loaded.
+func LoadSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	keyspaceMu.Lock()
+	defer keyspaceMu.Unlock()
+	return decodeSnapshot(data, time.Now())
+}
+
+// RunSaveRules starts a background save whenever one of rules is met. It
+// never returns.
+func RunSaveRules(rules []SaveRule) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		elapsed := time.Now().Unix() - lastSave.Load()
+		changes := dirty.Load()
+		for _, rule := range rules {
+			if changes >= rule.Changes && elapsed >= rule.Seconds {
+				log.Printf("%d changes in %d seconds. Saving...", rule.Changes, rule.Seconds)
+				backgroundSave()
+				break
+			}
+		}
+	}
+}
+
+// markDirty records that a write command changed the keyspace.
+func markDirty() {
+	dirty.Add(1)
+}
+
+func handleSave(args []resp.Value) resp.Value {
+	if !saveMu.TryLock() {
+		return resp.Value{DataType: resp.TypeError, Err: errBackgroundSaveInProgress}
+	}
+	defer saveMu.Unlock()
+
+	if err := writeSnapshot(takeSnapshot(forkKeyspaceForSave())); err != nil {
+		log.Println("Error saving snapshot:", err)
+		return resp.Value{DataType: resp.TypeError, Err: errSaveFailed}
+	}
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+func handleBGSave(args []resp.Value) resp.Value {
+	if len(args) > 1 || (len(args) == 1 && !strings.EqualFold(args[0].Bulk, "SCHEDULE")) {
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+	if !backgroundSave() {
+		return resp.Value{DataType: resp.TypeError, Err: errBackgroundSaveInProgress}
+	}
+	return resp.Value{DataType: resp.TypeString, Str: backgroundSavingStarted}
+}
+
+func handleLastSave(args []resp.Value) resp.Value {
+	return resp.Value{DataType: resp.TypeInteger, Num: lastSave.Load()}
+}
+
+// backgroundSave forks the keyspace, then encodes it and writes it out on
+// another goroutine, reporting false if a save is already running. Clients
+// are only held up while the fork is taken; see keyspaceFork for what they
+// pay for writing while the save runs.
+func backgroundSave() bool {
+	if !saveMu.TryLock() {
+		return false
+	}
+	fork := forkKeyspaceForSave()
+	go func() {
+		defer saveMu.Unlock()
+		if err := writeSnapshot(takeSnapshot(fork)); err != nil {
+			log.Println("Error in background save:", err)
+			return
+		}
+		log.Println("Background saving terminated with success")
+	}()
+	return true
+}
+
+// snapshot is the encoded keyspace together with the number of changes it
+// accounts for.
+type snapshot struct {
+	data  []byte
+	dirty int64
+}
+
+// savedFork is a fork of the keyspace to be saved, together with the
+// number of changes it accounts for.
+type savedFork struct {
+	fork  *keyspaceFork
+	dirty int64
+}
+
+func forkKeyspaceForSave() savedFork {
+	keyspaceMu.Lock()
+	defer keyspaceMu.Unlock()
+	return savedFork{fork: forkKeyspace(), dirty: dirty.Load()}
+}
+
+// takeSnapshot encodes the fork, which writers are not held up by.
+func takeSnapshot(f savedFork) snapshot {
+	return snapshot{data: encodeSnapshot(f.fork), dirty: f.dirty}
+}
+
+// writeSnapshot replaces the snapshot file atomically: the data goes to a
+// temporary file in the same directory, which is renamed over the old one
+// once it has been synced to disk.
+func writeSnapshot(s snapshot) error {
+	f, err := os.CreateTemp(filepath.Dir(snapshotPath), "temp-*.rdb")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(s.data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), snapshotPath); err != nil {
+		return err
+	}
+
+	// Changes made while the file was being written are not in it
+	dirty.Add(-s.dirty)
+	lastSave.Store(time.Now().Unix())
+	return nil
+}
+
+// encodeSnapshot serializes every live key of every database as of fork,
+// walking it.
+func encodeSnapshot(fork *keyspaceFork) []byte {
+	var buf bytes.Buffer
+	w := rdbWriter{w: &buf}
+	buf.WriteString(rdbMagic)
+	buf.WriteByte(rdbVersion)
+
+	selected := -1
+	fork.walk(func(db int, key string, record Record) {
+		if db != selected {
+			buf.WriteByte(rdbOpSelectDB)
+			w.writeLength(db)
+			selected = db
+		}
+		if record.ExpiryTime != nil {
+			buf.WriteByte(rdbOpExpireMs)
+			w.writeUint64(uint64(record.ExpiryTime.UnixMilli()))
+		}
+		w.writeRecord(key, record)
+	})
+
+	buf.WriteByte(rdbOpEOF)
+	w.writeUint64(crc64.Checksum(buf.Bytes(), crcTable))
+	return buf.Bytes()
+}
+
+type rdbWriter struct {
+	w *bytes.Buffer
+}
+
+func (w rdbWriter) writeUint64(n uint64) {
+	w.w.Write(binary.LittleEndian.AppendUint64(nil, n))
+}
+
+func (w rdbWriter) writeLength(n int) {
+	w.w.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (w rdbWriter) writeString(s string) {
+	w.writeLength(len(s))
+	w.w.WriteString(s)
+}
+
+func (w rdbWriter) writeRecord(key string, record Record) {
+	switch record.Type {
+	case TypeString:
+		w.w.WriteByte(rdbTypeString)
+		w.writeString(key)
+		w.writeString(stringValue(record))
+	case TypeList:
+		list := record.Value.(*deque)
+		w.w.WriteByte(rdbTypeList)
+		w.writeString(key)
+		w.writeLength(list.len())
+		for i := 0; i < list.len(); i++ {
+			w.writeString(list.at(i))
+		}
+	case TypeSet:
+		set := record.Value.(*stringSet)
+		w.w.WriteByte(rdbTypeSet)
+		w.writeString(key)
+		w.writeLength(set.len())
+		for _, member := range set.keys {
+			w.writeString(member)
+		}
+	case TypeZSet:
+		zset := record.Value.(*sortedSet)
+		w.w.WriteByte(rdbTypeZSet)
+		w.writeString(key)
+		w.writeLength(zset.len())
+		for x := zset.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+			w.writeString(x.member)
+			w.writeUint64(math.Float64bits(x.score))
+		}
+	case TypeHash:
+		hash := record.Value.(*dict[string])
+		w.w.WriteByte(rdbTypeHash)
+		w.writeString(key)
+		w.writeLength(hash.len())
+		for i, field := range hash.keys {
+			w.writeString(field)
+			w.writeString(hash.values[i])
+		}
+	}
+}
+
+// decodeSnapshot stores every key in data that has not expired by now. The
+// caller must keep the keyspace from changing while it runs.
+func decodeSnapshot(data []byte, now time.Time) (int, error) {
+	if len(data) < len(rdbMagic)+1+1+8 || string(data[:len(rdbMagic)]) != rdbMagic {
+		return 0, errRDBBadHeader
+	}
+	if version := data[len(rdbMagic)]; version != rdbVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	body, sum := data[:len(data)-8], binary.LittleEndian.Uint64(data[len(data)-8:])
+	if crc64.Checksum(body, crcTable) != sum {
+		return 0, errRDBBadChecksum
+	}
+
+	r := rdbReader{r: bytes.NewReader(body[len(rdbMagic)+1:])}
+	db := databases[0]
+	loaded := 0
+	for {
+		op, err := r.r.ReadByte()
+		if err != nil {
+			return loaded, fmt.Errorf("truncated snapshot: %w", err)
+		}
+		if op == rdbOpEOF {
+			return loaded, nil
+		}
+		if op == rdbOpSelectDB {
+			id, err := r.readLength()
+			if err != nil {
+				return loaded, err
+			}
+			if id >= len(databases) {
+				return loaded, fmt.Errorf("snapshot holds database %d, but there are only %d", id, len(databases))
+			}
+			db = databases[id]
+			continue
+		}
+
+		var expiryTime *time.Time
+		if op == rdbOpExpireMs {
+			ms, err := r.readUint64()
+			if err != nil {
+				return loaded, err
+			}
+			at := time.UnixMilli(int64(ms))
+			expiryTime = &at
+			if op, err = r.r.ReadByte(); err != nil {
+				return loaded, fmt.Errorf("truncated snapshot: %w", err)
+			}
+		}
+
+		key, record, err := r.readRecord(op)
+		if err != nil {
+			return loaded, err
+		}
+		record.ExpiryTime = expiryTime
+		if record.isExpired(now) {
+			continue
+		}
+		db.storeRecord(key, record)
+		loaded++
+	}
+}
+
+type rdbReader struct {
+	r *bytes.Reader
+}
+
+func (r rdbReader) readUint64() (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		return 0, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+func (r rdbReader) readLength() (int, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return 0, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	// Every element takes at least a byte, so this keeps a corrupt length
+	// from allocating more than the file could hold
+	if n > uint64(r.r.Len()) {
+		return 0, fmt.Errorf("snapshot length %d out of range", n)
+	}
+	return int(n), nil
+}
+
+func (r rdbReader) readString() (string, error) {
+	n, err := r.readLength()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return "", fmt.Errorf("truncated snapshot: %w", err)
+	}
+	return string(b), nil
+}
+
+func (r rdbReader) readRecord(rdbType byte) (string, Record, error) {
+	key, err := r.readString()
+	if err != nil {
+		return "", Record{}, err
+	}
+
+	switch rdbType {
+	case rdbTypeString:
+		value, err := r.readString()
+		return key, Record{Type: TypeString, Value: value}, err
+	case rdbTypeList:
+		n, err := r.readLength()
+		if err != nil {
+			return "", Record{}, err
+		}
+		list := newDeque()
+		for i := 0; i < n; i++ {
+			element, err := r.readString()
+			if err != nil {
+				return "", Record{}, err
+			}
+			list.pushBack(element)
+		}
+		return key, Record{Type: TypeList, Value: list}, nil
+	case rdbTypeSet:
+		n, err := r.readLength()
+		if err != nil {
+			return "", Record{}, err
+		}
+		set := newDict[struct{}](n)
+		for i := 0; i < n; i++ {
+			member, err := r.readString()
+			if err != nil {
+				return "", Record{}, err
+			}
+			set.set(member, struct{}{})
+		}
+		return key, Record{Type: TypeSet, Value: set}, nil
+	case rdbTypeZSet:
+		n, err := r.readLength()
+		if err != nil {
+			return "", Record{}, err
+		}
+		zset := newSortedSet()
+		for i := 0; i < n; i++ {
+			member, err := r.readString()
+			if err != nil {
+				return "", Record{}, err
+			}
+			bits, err := r.readUint64()
+			if err != nil {
+				return "", Record{}, err
+			}
+			zset.add(member, math.Float64frombits(bits))
+		}
+		return key, Record{Type: TypeZSet, Value: zset}, nil
+	case rdbTypeHash:
+		n, err := r.readLength()
+		if err != nil {
+			return "", Record{}, err
+		}
+		hash := newDict[string](n)
+		for i := 0; i < n; i++ {
+			field, err := r.readString()
+			if err != nil {
+				return "", Record{}, err
+			}
+			value, err := r.readString()
+			if err != nil {
+				return "", Record{}, err
+			}
+			hash.set(field, value)
+		}
+		return key, Record{Type: TypeHash, Value: hash}, nil
+	}
+	return "", Record{}, errRDBBadType
+}
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTripsEveryType(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	handleSet(db, bulkValues("str", "hello", "PX", "600000"))
+	handleRPush(db, bulkValues("list", "a", "b", "c"))
+	handleSAdd(db, bulkValues("set", "x", "y"))
+	handleZAdd(db, bulkValues("zset", "1.5", "one", "-inf", "low", "2", "two"))
+	handleHSet(db, bulkValues("hash", "f1", "v1", "f2", ""))
+	past := time.Now().Add(-time.Second)
+	db.storeRecord("gone", Record{Type: TypeString, Value: "x", ExpiryTime: &past})
+
+	snapshotPath = filepath.Join(t.TempDir(), "dump.rdb")
+	if reply := handleSave(nil); reply.Str != okResponse {
+		t.Fatalf("SAVE failed: %+v", reply)
+	}
+	ttl, _ := db.lookupKey("str")
+
+	flushKeyspace()
+	loaded, err := LoadSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 5 {
+		t.Fatalf("expected 5 keys to be loaded, got %d", loaded)
+	}
+
+	record, _ := db.lookupKey("str")
+	if record.Value != "hello" || record.ExpiryTime == nil || record.ExpiryTime.UnixMilli() != ttl.ExpiryTime.UnixMilli() {
+		t.Fatalf("string or its TTL was not restored: %+v", record)
+	}
+	if reply := handleLRange(db, bulkValues("list", "0", "-1")); len(reply.Array) != 3 || reply.Array[2].Bulk != "c" {
+		t.Fatalf("list was not restored: %+v", reply)
+	}
+	if reply := handleSCard(db, bulkValues("set")); reply.Num != 2 {
+		t.Fatalf("set was not restored: %+v", reply)
+	}
+	if reply := handleZRange(db, bulkValues("zset", "0", "-1", "WITHSCORES")).ForProtocol(2); len(reply.Array) != 6 ||
+		reply.Array[0].Bulk != "low" || reply.Array[1].Bulk != "-inf" || reply.Array[3].Bulk != "1.5" {
+		t.Fatalf("sorted set was not restored: %+v", reply)
+	}
+	if reply := handleHGet(db, bulkValues("hash", "f2")); reply.IsNull || reply.Bulk != "" {
+		t.Fatalf("hash was not restored: %+v", reply)
+	}
+	if _, ok := db.lookupKey("gone"); ok {
+		t.Fatal("an expired key was saved")
+	}
+}
+
+func TestDecodeSnapshotRejectsCorruption(t *testing.T) {
+	db := databases[0]
+	db.storeRecord("key", Record{Type: TypeString, Value: "value"})
+	data := encodeSnapshot(forkKeyspace())
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-12] ^= 0xFF
+	if _, err := decodeSnapshot(corrupt, time.Now()); err != errRDBBadChecksum {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+	if _, err := decodeSnapshot(data[:len(data)-1], time.Now()); err == nil {
+		t.Fatal("expected a truncated snapshot to be rejected")
+	}
+	if _, err := decodeSnapshot([]byte("REDIS0011 not ours"), time.Now()); err != errRDBBadHeader {
+		t.Fatalf("expected a header error, got %v", err)
+	}
+}
+
+func TestParseSaveRules(t *testing.T) {
+	rules, err := ParseSaveRules("3600 1 300 100")
+	if err != nil || len(rules) != 2 || rules[1] != (SaveRule{Seconds: 300, Changes: 100}) {
+		t.Fatalf("unexpected rules %+v, err %v", rules, err)
+	}
+	if rules, err := ParseSaveRules(""); err != nil || len(rules) != 0 {
+		t.Fatalf("expected no rules, got %+v, err %v", rules, err)
+	}
+	for _, invalid := range []string{"3600", "60 x", "0 1"} {
+		if _, err := ParseSaveRules(invalid); err == nil {
+			t.Fatalf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestSnapshotKeepsDatabases(t *testing.T) {
+	flushKeyspace()
+	handleSet(databases[0], bulkValues("key", "0"))
+	handleSet(databases[7], bulkValues("key", "7"))
+	data := encodeSnapshot(forkKeyspace())
+
+	flushKeyspace()
+	if loaded, err := decodeSnapshot(data, time.Now()); err != nil || loaded != 2 {
+		t.Fatalf("expected 2 keys to be loaded, got %d, err %v", loaded, err)
+	}
+	for _, id := range []int{0, 7} {
+		if value, _, _ := databases[id].loadString("key"); value != strconv.Itoa(id) {
+			t.Fatalf("expected key in database %d to be %d, got %q", id, id, value)
+		}
+	}
+
+	SetDatabases(4)
+	defer SetDatabases(DefaultDatabases)
+	if _, err := decodeSnapshot(data, time.Now()); err == nil {
+		t.Fatal("expected a snapshot with more databases than configured to be rejected")
+	}
+}
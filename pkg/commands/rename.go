@@ -0,0 +1,40 @@
+package commands
+
+import "go-redis/pkg/resp"
+
+func handleRename(db *Database, args []resp.Value) resp.Value {
+	return renameCommand(db, args, false)
+}
+
+func handleRenameNX(db *Database, args []resp.Value) resp.Value {
+	return renameCommand(db, args, true)
+}
+
+// renameCommand backs RENAME and RENAMENX. The value keeps its TTL under
+// its new name. With onlyIfMissing set an existing key is never
+// overwritten.
+func renameCommand(db *Database, args []resp.Value, onlyIfMissing bool) resp.Value {
+	key, newKey := args[0].Bulk, args[1].Bulk
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return resp.Value{DataType: resp.TypeError, Err: errNoSuchKey}
+	}
+
+	if onlyIfMissing {
+		if _, exists := db.lookupKey(newKey); exists {
+			return resp.Value{DataType: resp.TypeInteger, Num: 0}
+		}
+	}
+	if key != newKey {
+		db.deleteKey(key)
+		db.storeRecord(newKey, record)
+		if record.Type == TypeList {
+			signalKeyReady(db, newKey)
+		}
+	}
+
+	if onlyIfMissing {
+		return resp.Value{DataType: resp.TypeInteger, Num: 1}
+	}
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"testing"
+)
+
+func TestRenameKeepsTTL(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("key", "v", "EX", "100"))
+	handleSet(db, bulkValues("other", "old"))
+
+	if reply := handleRename(db, bulkValues("key", "other")); reply.Str != okResponse {
+		t.Fatalf("RENAME failed: %+v", reply)
+	}
+	if _, ok := db.lookupKey("key"); ok {
+		t.Fatal("the renamed key still exists under its old name")
+	}
+	if record, _ := db.lookupKey("other"); record.Value != "v" || record.ExpiryTime == nil {
+		t.Fatalf("the value or its TTL was lost: %+v", record)
+	}
+	if reply := handleRename(db, bulkValues("other", "other")); reply.Str != okResponse {
+		t.Fatalf("expected renaming a key to itself to succeed, got %+v", reply)
+	}
+	if reply := handleRename(db, bulkValues("missing", "key")); reply.Err != errNoSuchKey {
+		t.Fatalf("expected %q, got %+v", errNoSuchKey, reply)
+	}
+}
+
+func TestRenameNX(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("a", "1"))
+	handleSet(db, bulkValues("b", "2"))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected int64
+	}{
+		{"Existing Target", []string{"a", "b"}, 0},
+		{"Same Key", []string{"a", "a"}, 0},
+		{"New Target", []string{"a", "c"}, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if reply := handleRenameNX(db, bulkValues(tc.args...)); reply.Num != tc.expected {
+				t.Errorf("expected %d, got %+v", tc.expected, reply)
+			}
+		})
+	}
+	if value, _, _ := db.loadString("b"); value != "2" {
+		t.Fatalf("RENAMENX overwrote an existing key, got %q", value)
+	}
+	if value, _, _ := db.loadString("c"); value != "1" {
+		t.Fatalf("expected c to hold 1, got %q", value)
+	}
+}
+
+func TestRenameServesBlockedClients(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "RPUSH", bulkValues("staging", "job"))
+
+	done := make(chan resp.Value)
+	go func() {
+		reply, _ := Execute(NewClient(), "BLPOP", bulkValues("queue", "0"))
+		done <- reply
+	}()
+	waitForBlocked(t, "queue", 1)
+
+	Execute(client, "RENAME", bulkValues("staging", "queue"))
+	if reply := <-done; len(reply.Array) != 2 || reply.Array[1].Bulk != "job" {
+		t.Fatalf("expected the blocked client to pop the renamed list, got %+v", reply)
+	}
+}
@@ -0,0 +1,510 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"go-redis/pkg/resp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const (
+	errNoScript                = "NOSCRIPT No matching script. Please use EVAL."
+	errNumKeysNegative         = "ERR Number of keys can't be negative"
+	errScriptUnknownCommand    = "ERR Unknown Redis command called from script"
+	errScriptCommandNotAllowed = "ERR This Redis command is not allowed from script"
+	errScriptWrongArgsCount    = "ERR Wrong number of args calling Redis command from script"
+	errScriptArgumentType      = "ERR Lua redis lib command arguments must be strings or integers"
+	errScriptUnknownSubcommand = "ERR unknown subcommand or wrong number of arguments for 'SCRIPT' command"
+	errScriptTimedOut          = "BUSY Script exceeded the time limit and was stopped"
+	errScriptKilled            = "ERR Script killed by user with SCRIPT KILL..."
+	errNoScriptRunning         = "NOTBUSY No scripts in execution right now."
+	errScriptUnkillable        = "UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command."
+)
+
+// DefaultScriptTimeLimit is how long a script may run unless
+// SetScriptTimeLimit is called.
+const DefaultScriptTimeLimit = 5 * time.Second
+
+var (
+	// scriptsMu guards the script cache. SCRIPT runs without the keyspace
+	// lock, so it may run alongside another client's EVAL.
+	scriptsMu sync.Mutex
+	scripts   = make(map[string]*lua.FunctionProto)
+
+	// luaState is shared by every script. Scripts hold the keyspace lock
+	// exclusively while they run, so only one uses it at a time.
+	luaState *lua.LState
+	// scriptLibs holds the libraries as they were opened, out of the reach
+	// of scripts. Each script runs with copies of them, so whatever it
+	// changes is gone by the next one.
+	scriptLibs *lua.LTable
+	// scriptDB is the database of the client running the script, which
+	// the commands it calls apply to.
+	scriptDB *Database
+
+	// noScriptCommands may not be called from a script, even though they
+	// are in CommandHandler.
+	noScriptCommands = map[string]bool{
+		"EVAL":    true,
+		"EVALSHA": true,
+	}
+
+	scriptTimeLimit = DefaultScriptTimeLimit
+	// stopScript stops the running script, if any, with the cause it is
+	// given, and scriptWrote records that the running script modified the
+	// keyspace, after which it may no longer be stopped. They are guarded
+	// by stopScriptMu, since SCRIPT KILL runs without the keyspace lock the
+	// script holds.
+	stopScriptMu sync.Mutex
+	stopScript   context.CancelCauseFunc
+	scriptWrote  bool
+	scriptKilled = errors.New("killed")
+)
+
+// SetScriptTimeLimit sets how long a read-only script may run before it is
+// stopped with an error, so that a runaway script cannot hold the keyspace
+// lock forever. A script that has written runs to completion. It must be
+// called before any client connects.
+func SetScriptTimeLimit(limit time.Duration) {
+	scriptTimeLimit = limit
+}
+
+// The scripting commands are added to the command tables here rather than
+// in their declaration, because scripts call back into CommandHandler and
+// the declaration would otherwise refer to itself. SCRIPT only touches the
+// script cache and has to be able to kill a script holding the keyspace
+// lock, so it runs without the lock.
+func init() {
+	CommandHandler["EVAL"] = handleEval
+	CommandHandler["EVALSHA"] = handleEvalSHA
+	AdminCommandHandler["SCRIPT"] = handleScript
+}
+
+func scriptSHA(source string) string {
+	sum := sha1.Sum([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadScript compiles source and adds it to the cache.
+func loadScript(source string) (string, *lua.FunctionProto, error) {
+	sha := scriptSHA(source)
+	scriptsMu.Lock()
+	defer scriptsMu.Unlock()
+	if proto, ok := scripts[sha]; ok {
+		return sha, proto, nil
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(source), "@user_script")
+	if err != nil {
+		return "", nil, err
+	}
+	proto, err := lua.Compile(chunk, "user_script")
+	if err != nil {
+		return "", nil, err
+	}
+	scripts[sha] = proto
+	return sha, proto, nil
+}
+
+func handleEval(db *Database, args []resp.Value) resp.Value {
+	sha, proto, err := loadScript(args[0].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: "ERR Error compiling script (new function): " + err.Error()}
+	}
+	return runScript(db, sha, proto, args[1:])
+}
+
+func handleEvalSHA(db *Database, args []resp.Value) resp.Value {
+	sha := strings.ToLower(args[0].Bulk)
+	scriptsMu.Lock()
+	proto, ok := scripts[sha]
+	scriptsMu.Unlock()
+	if !ok {
+		return resp.Value{DataType: resp.TypeError, Err: errNoScript}
+	}
+	return runScript(db, sha, proto, args[1:])
+}
+
+// scriptKeys extracts the keys of EVAL and EVALSHA for writeCommands.
+func scriptKeys(args []resp.Value) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	return numKeysKeys(args[1:])
+}
+
+// runScript runs a compiled script on db with the "numkeys key [key ...]
+// arg [arg ...]" arguments of EVAL. The caller holds the keyspace lock
+// exclusively, which makes the script atomic. Its writes are propagated as
+// the commands it called, wrapped in a transaction. A script running past
+// the time limit, or killed with SCRIPT KILL, is stopped with an error as
+// long as it has not modified the keyspace. Once it has, stopping it would
+// leave it half applied, so it runs to completion, as in Redis. A script
+// that fails on its own keeps the writes it made until then.
+func runScript(db *Database, sha string, proto *lua.FunctionProto, args []resp.Value) resp.Value {
+	numKeys, err := strconv.Atoi(args[0].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	if numKeys < 0 {
+		return resp.Value{DataType: resp.TypeError, Err: errNumKeysNegative}
+	}
+	if numKeys > len(args)-1 {
+		return resp.Value{DataType: resp.TypeError, Err: errNumKeysTooLarge}
+	}
+
+	L := scriptState()
+	defer L.SetTop(0)
+	scriptDB = db
+	env := scriptEnvironment(L)
+	env.RawSetString("KEYS", stringsTable(L, args[1:1+numKeys]))
+	env.RawSetString("ARGV", stringsTable(L, args[1+numKeys:]))
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	timer := time.AfterFunc(scriptTimeLimit, func() {
+		stopScriptMu.Lock()
+		defer stopScriptMu.Unlock()
+		if !scriptWrote {
+			cancel(context.DeadlineExceeded)
+		}
+	})
+	defer timer.Stop()
+	stopScriptMu.Lock()
+	stopScript = cancel
+	scriptWrote = false
+	stopScriptMu.Unlock()
+	defer func() {
+		stopScriptMu.Lock()
+		stopScript = nil
+		stopScriptMu.Unlock()
+		cancel(nil)
+	}()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+
+	beginPropagatedTransaction()
+	defer endPropagatedTransaction()
+
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		switch context.Cause(ctx) {
+		case context.DeadlineExceeded:
+			return resp.Value{DataType: resp.TypeError, Err: errScriptTimedOut}
+		case scriptKilled:
+			return resp.Value{DataType: resp.TypeError, Err: errScriptKilled}
+		}
+		return scriptError(sha, err)
+	}
+	return luaToValue(L.Get(-1))
+}
+
+// scriptError turns a failed script into an error reply. An error raised
+// by redis.call, or returned by redis.error_reply, is passed on as is.
+func scriptError(sha string, err error) resp.Value {
+	if apiErr, ok := err.(*lua.ApiError); ok {
+		if table, ok := apiErr.Object.(*lua.LTable); ok {
+			if message, ok := table.RawGetString("err").(lua.LString); ok {
+				return resp.Value{DataType: resp.TypeError, Err: string(message)}
+			}
+		}
+		if message, ok := apiErr.Object.(lua.LString); ok {
+			return resp.Value{DataType: resp.TypeError, Err: "ERR Error running script (call to f_" + sha + "): " + string(message)}
+		}
+	}
+	return resp.Value{DataType: resp.TypeError, Err: "ERR Error running script (call to f_" + sha + "): " + err.Error()}
+}
+
+// scriptState returns the shared interpreter, creating it on first use
+// with the libraries scripts may use and the redis table. They are kept in
+// scriptLibs rather than in the interpreter's globals, which every script
+// replaces with its own.
+func scriptState() *lua.LState {
+	if luaState != nil {
+		return luaState
+	}
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "_G"} {
+		L.G.Global.RawSetString(name, lua.LNil)
+	}
+
+	L.SetGlobal("redis", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"call":         func(L *lua.LState) int { return scriptCall(L, true) },
+		"pcall":        func(L *lua.LState) int { return scriptCall(L, false) },
+		"error_reply":  func(L *lua.LState) int { return replyTable(L, "err") },
+		"status_reply": func(L *lua.LState) int { return replyTable(L, "ok") },
+		"sha1hex": func(L *lua.LState) int {
+			L.Push(lua.LString(scriptSHA(L.CheckString(1))))
+			return 1
+		},
+	}))
+
+	scriptLibs = L.G.Global
+	luaState = L
+	return L
+}
+
+// scriptEnvironment installs fresh globals for a script and returns them:
+// copies of the libraries, protected against accidental assignment.
+// Strings get a copy of the string library as their metatable, since that
+// is shared too.
+func scriptEnvironment(L *lua.LState) *lua.LTable {
+	env := L.CreateTable(0, 64)
+	scriptLibs.ForEach(func(name, value lua.LValue) {
+		if lib, ok := value.(*lua.LTable); ok {
+			value = copyTable(L, lib)
+		}
+		env.RawSet(name, value)
+	})
+	env.RawSetString("_G", env)
+	stringLib := env.RawGetString(lua.StringLibName).(*lua.LTable)
+	stringLib.RawSetString("__index", stringLib)
+	L.SetMetatable(lua.LString(""), stringLib)
+
+	globals := L.NewTable()
+	globals.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("Script attempted to create global variable '%s'", L.CheckString(2))
+		return 0
+	}))
+	globals.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("Script attempted to access nonexistent global variable '%s'", L.CheckString(2))
+		return 0
+	}))
+	L.SetMetatable(env, globals)
+
+	L.G.Global = env
+	L.Env = env
+	return env
+}
+
+func copyTable(L *lua.LState, table *lua.LTable) *lua.LTable {
+	copied := L.CreateTable(0, table.Len())
+	table.ForEach(func(key, value lua.LValue) {
+		copied.RawSet(key, value)
+	})
+	return copied
+}
+
+// scriptCall implements redis.call and redis.pcall. An error reply is
+// raised as a Lua error by redis.call, and returned as an error table by
+// redis.pcall.
+func scriptCall(L *lua.LState, raise bool) int {
+	// Scripts see replies as a RESP2 client would
+	reply := scriptCommand(L).ForProtocol(2)
+	if reply.DataType == resp.TypeError && raise {
+		L.Error(valueToLua(L, reply), 1)
+		return 0
+	}
+	L.Push(valueToLua(L, reply))
+	return 1
+}
+
+func scriptCommand(L *lua.LState) resp.Value {
+	if L.GetTop() == 0 {
+		return resp.Value{DataType: resp.TypeError, Err: errScriptWrongArgsCount}
+	}
+	args := make([]resp.Value, L.GetTop())
+	for i := range args {
+		switch arg := L.Get(i + 1).(type) {
+		case lua.LString:
+			args[i] = resp.Value{DataType: resp.TypeBulk, Bulk: string(arg)}
+		case lua.LNumber:
+			args[i] = resp.Value{DataType: resp.TypeBulk, Bulk: strconv.FormatFloat(float64(arg), 'f', -1, 64)}
+		default:
+			return resp.Value{DataType: resp.TypeError, Err: errScriptArgumentType}
+		}
+	}
+
+	name := strings.ToUpper(args[0].Bulk)
+	handler, ok := CommandHandler[name]
+	if !ok {
+		if _, ok := ClientCommandHandler[name]; ok {
+			return resp.Value{DataType: resp.TypeError, Err: errScriptCommandNotAllowed}
+		}
+		if _, ok := AdminCommandHandler[name]; ok {
+			return resp.Value{DataType: resp.TypeError, Err: errScriptCommandNotAllowed}
+		}
+		return resp.Value{DataType: resp.TypeError, Err: errScriptUnknownCommand}
+	}
+	if noScriptCommands[name] {
+		return resp.Value{DataType: resp.TypeError, Err: errScriptCommandNotAllowed}
+	}
+	if !validArity(name, len(args)-1) {
+		return resp.Value{DataType: resp.TypeError, Err: errScriptWrongArgsCount}
+	}
+	if _, ok := writeCommands[name]; !ok {
+		return callCommand(scriptDB, name, handler, args[1:])
+	}
+
+	// Writes run under stopScriptMu, so that the script cannot be stopped
+	// between making one and recording it. A script stopped before the
+	// write does not make it, as it stops at its next instruction anyway.
+	stopScriptMu.Lock()
+	defer stopScriptMu.Unlock()
+	if context.Cause(L.Context()) != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errScriptKilled}
+	}
+	before := modifications.Load()
+	reply := callCommand(scriptDB, name, handler, args[1:])
+	if modifications.Load() != before {
+		scriptWrote = true
+	}
+	return reply
+}
+
+// replyTable implements redis.error_reply and redis.status_reply.
+func replyTable(L *lua.LState, field string) int {
+	table := L.NewTable()
+	table.RawSetString(field, lua.LString(L.CheckString(1)))
+	L.Push(table)
+	return 1
+}
+
+func stringsTable(L *lua.LState, values []resp.Value) *lua.LTable {
+	table := L.CreateTable(len(values), 0)
+	for _, v := range values {
+		table.Append(lua.LString(v.Bulk))
+	}
+	return table
+}
+
+// valueToLua converts a command reply for a script the way Redis does:
+// nulls become false, and status and error replies become tables with an
+// ok or err field.
+func valueToLua(L *lua.LState, v resp.Value) lua.LValue {
+	switch v.DataType {
+	case resp.TypeString:
+		table := L.NewTable()
+		table.RawSetString("ok", lua.LString(v.Str))
+		return table
+	case resp.TypeError:
+		table := L.NewTable()
+		table.RawSetString("err", lua.LString(v.Err))
+		return table
+	case resp.TypeInteger:
+		return lua.LNumber(v.Num)
+	case resp.TypeBulk:
+		return lua.LString(v.Bulk)
+	case resp.TypeArray:
+		if v.IsNull {
+			return lua.LFalse
+		}
+		table := L.CreateTable(len(v.Array), 0)
+		for _, element := range v.Array {
+			table.Append(valueToLua(L, element))
+		}
+		return table
+	default:
+		return lua.LFalse
+	}
+}
+
+// luaToValue converts the value returned by a script into its reply.
+// Numbers are truncated to integers, true becomes 1, false and nil become a
+// null reply, and a table is read as an array up to its first nil unless
+// it is an ok or err table.
+func luaToValue(lv lua.LValue) resp.Value {
+	switch lv := lv.(type) {
+	case lua.LString:
+		return resp.Value{DataType: resp.TypeBulk, Bulk: string(lv)}
+	case lua.LNumber:
+		return resp.Value{DataType: resp.TypeInteger, Num: int64(lv)}
+	case lua.LBool:
+		if lv {
+			return resp.Value{DataType: resp.TypeInteger, Num: 1}
+		}
+	case *lua.LTable:
+		if message, ok := lv.RawGetString("err").(lua.LString); ok {
+			return resp.Value{DataType: resp.TypeError, Err: string(message)}
+		}
+		if status, ok := lv.RawGetString("ok").(lua.LString); ok {
+			return resp.Value{DataType: resp.TypeString, Str: string(status)}
+		}
+		values := []resp.Value{}
+		for i := 1; ; i++ {
+			element := lv.RawGetInt(i)
+			if element == lua.LNil {
+				break
+			}
+			values = append(values, luaToValue(element))
+		}
+		return resp.Value{DataType: resp.TypeArray, Array: values}
+	}
+	return resp.Value{DataType: resp.TypeNull, IsNull: true}
+}
+
+func handleScript(args []resp.Value) resp.Value {
+	switch strings.ToUpper(args[0].Bulk) {
+	case "KILL":
+		if len(args) != 1 {
+			break
+		}
+		stopScriptMu.Lock()
+		defer stopScriptMu.Unlock()
+		if stopScript == nil {
+			return resp.Value{DataType: resp.TypeError, Err: errNoScriptRunning}
+		}
+		if scriptWrote {
+			return resp.Value{DataType: resp.TypeError, Err: errScriptUnkillable}
+		}
+		stopScript(scriptKilled)
+		return resp.Value{DataType: resp.TypeString, Str: okResponse}
+	case "LOAD":
+		if len(args) != 2 {
+			break
+		}
+		sha, _, err := loadScript(args[1].Bulk)
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: "ERR Error compiling script (new function): " + err.Error()}
+		}
+		return resp.Value{DataType: resp.TypeBulk, Bulk: sha}
+	case "EXISTS":
+		if len(args) < 2 {
+			break
+		}
+		scriptsMu.Lock()
+		defer scriptsMu.Unlock()
+		found := make([]resp.Value, len(args)-1)
+		for i, arg := range args[1:] {
+			found[i] = resp.Value{DataType: resp.TypeInteger}
+			if _, ok := scripts[strings.ToLower(arg.Bulk)]; ok {
+				found[i].Num = 1
+			}
+		}
+		return resp.Value{DataType: resp.TypeArray, Array: found}
+	case "FLUSH":
+		if len(args) > 2 {
+			break
+		}
+		if len(args) == 2 {
+			if mode := strings.ToUpper(args[1].Bulk); mode != "ASYNC" && mode != "SYNC" {
+				return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+			}
+		}
+		scriptsMu.Lock()
+		scripts = make(map[string]*lua.FunctionProto)
+		scriptsMu.Unlock()
+		return resp.Value{DataType: resp.TypeString, Str: okResponse}
+	}
+	return resp.Value{DataType: resp.TypeError, Err: errScriptUnknownSubcommand}
+}
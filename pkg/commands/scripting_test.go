@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEvalConvertsValues(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	Execute(client, "RPUSH", bulkValues("list", "a", "b"))
+
+	reply, _ := Execute(client, "EVAL", bulkValues(
+		"return {KEYS[1], ARGV[1], 3.9, true, redis.call('LRANGE', KEYS[1], 0, -1), redis.call('GET', 'missing') == false, nil, 'unreached'}",
+		"1", "list", "arg"))
+	expectMessages(t, []resp.Value{reply}, []string{"list", "arg", "3", "1", "", "1"})
+	if got := flatten(reply.Array[4]); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("expected the LRANGE reply as a table, got %v", got)
+	}
+
+	if reply, _ := Execute(client, "EVAL", bulkValues("return redis.call('SET', KEYS[1], 'v')", "1", "key")); reply.DataType != resp.TypeString || reply.Str != okResponse {
+		t.Fatalf("expected a status reply, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("return redis.error_reply('MY error')", "0")); reply.Err != "MY error" {
+		t.Fatalf("expected an error reply, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("return nil", "0")); !reply.IsNull {
+		t.Fatalf("expected a null reply, got %+v", reply)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	Execute(client, "SET", bulkValues("str", "v"))
+
+	if reply, _ := Execute(client, "EVAL", bulkValues("return redis.call('LPUSH', KEYS[1], 'x')", "1", "str")); reply.Err != errWrongType {
+		t.Fatalf("expected the error raised by redis.call to be passed on, got %+v", reply)
+	}
+	reply, _ := Execute(client, "EVAL", bulkValues("local r = redis.pcall('LPUSH', KEYS[1], 'x'); return r.err", "1", "str"))
+	if reply.Bulk != errWrongType {
+		t.Fatalf("expected redis.pcall to return the error, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("return redis.call('NOSUCHCOMMAND')", "0")); reply.Err != errScriptUnknownCommand {
+		t.Fatalf("expected an unknown command error, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("return redis.call('EVAL', 'return 1', 0)", "0")); reply.Err != errScriptCommandNotAllowed {
+		t.Fatalf("expected EVAL to be refused inside a script, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("counter = 1", "0")); !strings.Contains(reply.Err, "global variable 'counter'") {
+		t.Fatalf("expected creating a global to fail, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("return (", "0")); !strings.HasPrefix(reply.Err, "ERR Error compiling script") {
+		t.Fatalf("expected a compile error, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("return 1", "2", "key")); reply.Err != errNumKeysTooLarge {
+		t.Fatalf("expected a numkeys error, got %+v", reply)
+	}
+}
+
+func TestScriptCache(t *testing.T) {
+	client := NewClient()
+	script := "return tonumber(ARGV[1]) * 2"
+	sha := scriptSHA(script)
+
+	Execute(client, "SCRIPT", bulkValues("FLUSH"))
+	if reply, _ := Execute(client, "EVALSHA", bulkValues(sha, "0", "21")); reply.Err != errNoScript {
+		t.Fatalf("expected NOSCRIPT, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "SCRIPT", bulkValues("LOAD", script)); reply.Bulk != sha {
+		t.Fatalf("expected SCRIPT LOAD to return %s, got %+v", sha, reply)
+	}
+	if reply, _ := Execute(client, "EVALSHA", bulkValues(strings.ToUpper(sha), "0", "21")); reply.Num != 42 {
+		t.Fatalf("expected 42, got %+v", reply)
+	}
+	reply, _ := Execute(client, "SCRIPT", bulkValues("EXISTS", sha, scriptSHA("return 0")))
+	expectMessages(t, []resp.Value{reply}, []string{"1", "0"})
+
+	Execute(client, "SCRIPT", bulkValues("FLUSH"))
+	if reply, _ := Execute(client, "EVALSHA", bulkValues(sha, "0", "21")); reply.Err != errNoScript {
+		t.Fatalf("expected the cache to be flushed, got %+v", reply)
+	}
+}
+
+func TestScriptsRunAtomically(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	const clients, rounds = 16, 200
+	// Not atomic at all without the keyspace lock: GET and SET are separate
+	script := "local n = tonumber(redis.call('GET', KEYS[1]) or '0'); redis.call('SET', KEYS[1], n + 1); return n + 1"
+
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := NewClient()
+			for round := 0; round < rounds; round++ {
+				Execute(client, "EVAL", bulkValues(script, "1", "counter"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _, _ := db.loadString("counter"); value != strconv.Itoa(clients*rounds) {
+		t.Fatalf("expected counter %d, got %s", clients*rounds, value)
+	}
+}
+
+func TestEvalIsLoggedAsItsEffects(t *testing.T) {
+	path := openTestAppendOnly(t)
+	client := NewClient()
+
+	Execute(client, "EVAL", bulkValues("redis.call('GET', KEYS[1]); redis.call('SET', KEYS[1], ARGV[1]); redis.call('SPOP', KEYS[2])", "2", "key", "set", "v"))
+	Execute(client, "EVAL", bulkValues("return redis.call('GET', KEYS[1])", "1", "key"))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ""
+	for _, command := range [][]string{{"SELECT", "0"}, {"MULTI"}, {"SET", "key", "v"}, {"EXEC"}} {
+		expected += string(resp.Value{DataType: resp.TypeArray, Array: bulkValues(command...)}.Serialize())
+	}
+	if string(data) != expected {
+		t.Fatalf("expected the script to be logged as\n%q\ngot\n%q", expected, data)
+	}
+}
+
+func TestScriptTimeLimit(t *testing.T) {
+	SetScriptTimeLimit(50 * time.Millisecond)
+	defer SetScriptTimeLimit(DefaultScriptTimeLimit)
+	client := NewClient()
+
+	start := time.Now()
+	if reply, _ := Execute(client, "EVAL", bulkValues("while true do pcall(function() while true do end end) end", "0")); reply.Err != errScriptTimedOut {
+		t.Fatalf("expected the script to be stopped, got %+v", reply)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("the script ran for %v", elapsed)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("return 1", "0")); reply.Num != 1 {
+		t.Fatalf("expected scripts to run again, got %+v", reply)
+	}
+}
+
+func TestScriptKill(t *testing.T) {
+	client := NewClient()
+	if reply, _ := Execute(client, "SCRIPT", bulkValues("KILL")); reply.Err != errNoScriptRunning {
+		t.Fatalf("expected NOTBUSY, got %+v", reply)
+	}
+
+	done := make(chan resp.Value)
+	go func() {
+		reply, _ := Execute(NewClient(), "EVAL", bulkValues("while true do end", "0"))
+		done <- reply
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		reply, _ := Execute(client, "SCRIPT", bulkValues("KILL"))
+		if reply.Str == okResponse {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("could not kill the script, got %+v", reply)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if reply := <-done; reply.Err != errScriptKilled {
+		t.Fatalf("expected the script to be killed, got %+v", reply)
+	}
+}
+
+func TestScriptsCannotChangeWhatLaterScriptsSee(t *testing.T) {
+	client := NewClient()
+	for _, script := range []string{
+		"string.len = nil; math = nil; redis.call = nil",
+		"getmetatable('').__index = {}",
+		"setmetatable(_G, nil); counter = 1",
+		"rawset(getfenv(0), 'leaked', 1)",
+	} {
+		Execute(client, "EVAL", bulkValues(script, "0"))
+	}
+
+	reply, _ := Execute(client, "EVAL", bulkValues("return {string.len('abc'), ('abc'):len(), math.floor(1.5), redis.call('PING')['ok']}", "0"))
+	expectMessages(t, []resp.Value{reply}, []string{"3", "3", "1", "PONG"})
+	if reply, _ := Execute(client, "EVAL", bulkValues("return counter", "0")); !strings.Contains(reply.Err, "global variable 'counter'") {
+		t.Fatalf("expected the global to be gone, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EVAL", bulkValues("return rawget(getfenv(0), 'leaked')", "0")); !reply.IsNull {
+		t.Fatalf("expected the global to be gone, got %+v", reply)
+	}
+}
+
+func TestScriptThatWroteRunsToCompletion(t *testing.T) {
+	flushKeyspace()
+	SetScriptTimeLimit(10 * time.Millisecond)
+	defer SetScriptTimeLimit(DefaultScriptTimeLimit)
+	client := NewClient()
+
+	done := make(chan resp.Value)
+	go func() {
+		reply, _ := Execute(NewClient(), "EVAL", bulkValues("redis.call('SET', KEYS[1], 'v') for i = 1, 5e6 do end return 'done'", "1", "key"))
+		done <- reply
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		stopScriptMu.Lock()
+		wrote := scriptWrote
+		stopScriptMu.Unlock()
+		if wrote {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the script never wrote")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if reply, _ := Execute(client, "SCRIPT", bulkValues("KILL")); reply.Err != errScriptUnkillable {
+		t.Fatalf("expected UNKILLABLE, got %+v", reply)
+	}
+	if reply := <-done; reply.Bulk != "done" {
+		t.Fatalf("expected the script to run past the time limit to completion, got %+v", reply)
+	}
+}
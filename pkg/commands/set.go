@@ -2,19 +2,21 @@ package commands
 
 import (
 	"errors"
-	"fmt"
 	"go-redis/pkg/resp"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type SetOptions struct {
-	NX   bool
-	XX   bool
-	EX   int64
-	PX   int64
-	EXAT int64
-	PXAT int64
+	NX      bool
+	XX      bool
+	GET     bool
+	KEEPTTL bool
+	EX      int64
+	PX      int64
+	EXAT    int64
+	PXAT    int64
 }
 
 func parseSetOptions(args []resp.Value) (SetOptions, error) {
@@ -26,24 +28,40 @@ func parseSetOptions(args []resp.Value) (SetOptions, error) {
 	}
 	var timeOptionSet = false
 
-	for i := 2; i < len(args); i++ {
-		arg := args[i].Bulk
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToUpper(args[i].Bulk)
 		switch arg {
 		case "NX":
+			if opts.XX {
+				return opts, errors.New(errSyntax)
+			}
 			opts.NX = true
 		case "XX":
+			if opts.NX {
+				return opts, errors.New(errSyntax)
+			}
 			opts.XX = true
-		case "EX", "PX", "EXAT", "PXAT":
+		case "GET":
+			opts.GET = true
+		case "KEEPTTL":
 			if timeOptionSet {
-				return opts, errors.New("only one time-based option (EX, PX, EXAT, PXAT) can be set")
+				return opts, errors.New(errSyntax)
+			}
+			opts.KEEPTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if timeOptionSet || opts.KEEPTTL {
+				return opts, errors.New(errSyntax)
 			}
 			if i+1 >= len(args) {
-				return opts, errors.New(fmt.Sprintf(errWrongArgsCount, "set"))
+				return opts, errors.New(errSyntax)
 			}
 			value, err := strconv.ParseInt(args[i+1].Bulk, 10, 64)
 			if err != nil {
 				return opts, errors.New(errNotInteger)
 			}
+			if _, ok := expiryUnixMilli(arg, value); !ok || value <= 0 {
+				return opts, errors.New(errInvalidExpireTime("set"))
+			}
 			switch arg {
 			case "EX":
 				opts.EX = value
@@ -63,11 +81,26 @@ func parseSetOptions(args []resp.Value) (SetOptions, error) {
 	return opts, nil
 }
 
-func handleSet(args []resp.Value) resp.Value {
-	if len(args) < 2 {
-		return resp.Value{DataType: resp.TypeError, Err: errWrongArgsCount}
+// expiryTime returns the absolute expiry requested by the EX, PX, EXAT or
+// PXAT option, or nil when none was given.
+func (opts SetOptions) expiryTime() *time.Time {
+	var at int64
+	if opts.EX > 0 {
+		at, _ = expiryUnixMilli("EX", opts.EX)
+	} else if opts.PX > 0 {
+		at, _ = expiryUnixMilli("PX", opts.PX)
+	} else if opts.EXAT > 0 {
+		at, _ = expiryUnixMilli("EXAT", opts.EXAT)
+	} else if opts.PXAT > 0 {
+		at, _ = expiryUnixMilli("PXAT", opts.PXAT)
+	} else {
+		return nil
 	}
+	expirationTime := time.UnixMilli(at)
+	return &expirationTime
+}
 
+func handleSet(db *Database, args []resp.Value) resp.Value {
 	key := args[0].Bulk
 	value := args[1].Bulk
 
@@ -76,33 +109,32 @@ func handleSet(args []resp.Value) resp.Value {
 		return resp.Value{DataType: resp.TypeError, Err: err.Error()}
 	}
 
-	record := Record{Type: TypeString, Value: value}
+	record := Record{Type: TypeString, Value: value, ExpiryTime: opts.expiryTime()}
 
-	if opts.EX > 0 {
-		expirationTime := time.Now().Add(time.Duration(opts.EX) * time.Second)
-		record.ExpiryTime = &expirationTime
-	} else if opts.PX > 0 {
-		expirationTime := time.Now().Add(time.Duration(opts.PX) * time.Millisecond)
-		record.ExpiryTime = &expirationTime
-	} else if opts.EXAT > 0 {
-		expirationTime := time.Unix(opts.EXAT, 0)
-		record.ExpiryTime = &expirationTime
-	} else if opts.PXAT > 0 {
-		secs := opts.PXAT / 1000
-		nsecs := (opts.PXAT % 1000) * 1000000
-		expirationTime := time.Unix(secs, nsecs)
-		record.ExpiryTime = &expirationTime
+	// With GET the reply is the old value, whether or not the key gets set
+	reply := resp.Value{DataType: resp.TypeNull, IsNull: true}
+	oldRec, exists := db.lookupKey(key)
+	if opts.GET && exists {
+		if oldRec.Type != TypeString {
+			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+		}
+		reply = resp.Value{DataType: resp.TypeBulk, Bulk: stringValue(oldRec)}
 	}
 
-	if oldRecord, exists := dataSet.Load(key); exists {
-		oldRec := oldRecord.(Record)
+	if exists {
 		if opts.NX || (opts.XX && oldRec.Type != TypeString) {
-			return resp.Value{DataType: resp.TypeNull, IsNull: true}
+			return reply
+		}
+		if opts.KEEPTTL {
+			record.ExpiryTime = oldRec.ExpiryTime
 		}
 	} else if opts.XX {
-		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+		return reply
 	}
 
-	dataSet.Store(key, record)
-	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+	db.storeRecord(key, record)
+	if !opts.GET {
+		reply = resp.Value{DataType: resp.TypeString, Str: okResponse}
+	}
+	return reply
 }
@@ -0,0 +1,440 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	errNumKeysNotPositive = "ERR numkeys should be greater than 0"
+	errNumKeysTooLarge    = "ERR Number of keys can't be greater than number of args"
+	errLimitNegative      = "ERR LIMIT can't be negative"
+	errNotPositive        = "ERR value is out of range, must be positive"
+)
+
+// loadSet returns the set stored at key. A missing key yields a nil set,
+// which behaves as an empty one, while a key holding another type reports
+// wrongType.
+func (db *Database) loadSet(key string) (set *stringSet, wrongType bool) {
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return nil, false
+	}
+	if record.Type != TypeSet {
+		return nil, true
+	}
+	return record.Value.(*stringSet), false
+}
+
+// loadOrCreateSet is like loadSet but creates and stores an empty set when
+// the key does not exist yet.
+func (db *Database) loadOrCreateSet(key string) (set *stringSet, wrongType bool) {
+	set, wrongType = db.loadSet(key)
+	if wrongType || set != nil {
+		return set, wrongType
+	}
+	set = newDict[struct{}](0)
+	db.storeRecord(key, Record{Type: TypeSet, Value: set})
+	return set, false
+}
+
+// storeSet replaces whatever is stored at key with set, deleting the key
+// instead when the set is empty.
+func (db *Database) storeSet(key string, set *stringSet) {
+	if set.len() == 0 {
+		db.deleteKey(key)
+		return
+	}
+	db.storeRecord(key, Record{Type: TypeSet, Value: set})
+}
+
+func setMembersArray(members []string) resp.Value {
+	result := make([]resp.Value, len(members))
+	for i, member := range members {
+		result[i] = resp.Value{DataType: resp.TypeBulk, Bulk: member}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
+
+// setMembersSet is setMembersArray for replies that hold a set rather than
+// a sample of one, which RESP3 clients receive as a set.
+func setMembersSet(members []string) resp.Value {
+	reply := setMembersArray(members)
+	reply.DataType = resp.TypeSet
+	return reply
+}
+
+// setToSlice returns the members of set. The slice is the set's own, so it
+// must not be modified, nor kept past a change to the set.
+func setToSlice(set *stringSet) []string {
+	if set == nil {
+		return nil
+	}
+	return set.keys
+}
+
+func handleSAdd(db *Database, args []resp.Value) resp.Value {
+	set, wrongType := db.loadOrCreateSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	added := 0
+	for _, arg := range args[1:] {
+		if set.set(arg.Bulk, struct{}{}) {
+			added++
+		}
+	}
+	if added > 0 {
+		signalModified()
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(added)}
+}
+
+func handleSRem(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	set, wrongType := db.loadSet(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	removed := 0
+	for _, arg := range args[1:] {
+		if set.delete(arg.Bulk) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		signalModified()
+	}
+	if set != nil && set.len() == 0 {
+		db.deleteKey(key)
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(removed)}
+}
+
+func handleSIsMember(db *Database, args []resp.Value) resp.Value {
+	set, wrongType := db.loadSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	if set.has(args[1].Bulk) {
+		return resp.Value{DataType: resp.TypeInteger, Num: 1}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: 0}
+}
+
+func handleSMIsMember(db *Database, args []resp.Value) resp.Value {
+	set, wrongType := db.loadSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	result := make([]resp.Value, len(args)-1)
+	for i, arg := range args[1:] {
+		result[i] = resp.Value{DataType: resp.TypeInteger, Num: 0}
+		if set.has(arg.Bulk) {
+			result[i].Num = 1
+		}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
+
+func handleSMembers(db *Database, args []resp.Value) resp.Value {
+	set, wrongType := db.loadSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	return setMembersSet(setToSlice(set))
+}
+
+func handleSCard(db *Database, args []resp.Value) resp.Value {
+	set, wrongType := db.loadSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(set.len())}
+}
+
+func handleSPop(db *Database, args []resp.Value) resp.Value {
+	if len(args) > 2 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError("SPOP")}
+	}
+
+	key := args[0].Bulk
+	count := int64(1)
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.ParseInt(args[1].Bulk, 10, 64)
+		if err != nil || count < 0 {
+			return resp.Value{DataType: resp.TypeError, Err: errNotPositive}
+		}
+	}
+
+	set, wrongType := db.loadSet(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	// Popping every member hands over the set's own slice of them, as the
+	// set goes away with the key. Otherwise members are removed one at a
+	// time, each picked at random among those left.
+	var popped []string
+	if count >= int64(set.len()) {
+		popped = setToSlice(set)
+		if set != nil {
+			db.deleteKey(key)
+		}
+	} else if count > 0 {
+		popped = make([]string, 0, count)
+		for range count {
+			member, _ := set.popRandom()
+			popped = append(popped, member)
+		}
+		signalModified()
+	}
+
+	if len(args) == 1 {
+		if len(popped) == 0 {
+			return resp.Value{DataType: resp.TypeNull, IsNull: true}
+		}
+		return resp.Value{DataType: resp.TypeBulk, Bulk: popped[0]}
+	}
+	return setMembersArray(popped)
+}
+
+func handleSRandMember(db *Database, args []resp.Value) resp.Value {
+	if len(args) > 2 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError("SRANDMEMBER")}
+	}
+
+	set, wrongType := db.loadSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	if len(args) == 1 {
+		if set.len() == 0 {
+			return resp.Value{DataType: resp.TypeNull, IsNull: true}
+		}
+		return resp.Value{DataType: resp.TypeBulk, Bulk: set.keys[rand.IntN(set.len())]}
+	}
+
+	count, errMsg := parseRandomCount(args[1].Bulk)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	picked := set.sample(count)
+	members := make([]string, len(picked))
+	for i, j := range picked {
+		members[i] = set.keys[j]
+	}
+	return setMembersArray(members)
+}
+
+func handleSMove(db *Database, args []resp.Value) resp.Value {
+	source, destination, member := args[0].Bulk, args[1].Bulk, args[2].Bulk
+	srcSet, wrongType := db.loadSet(source)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if _, wrongType := db.loadSet(destination); wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	if !srcSet.has(member) {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	if source == destination {
+		return resp.Value{DataType: resp.TypeInteger, Num: 1}
+	}
+
+	srcSet.delete(member)
+	signalModified()
+	if srcSet.len() == 0 {
+		db.deleteKey(source)
+	}
+	dstSet, _ := db.loadOrCreateSet(destination)
+	dstSet.set(member, struct{}{})
+	return resp.Value{DataType: resp.TypeInteger, Num: 1}
+}
+
+type setOperation int
+
+const (
+	setInter setOperation = iota
+	setUnion
+	setDiff
+)
+
+// loadSets loads the set at every key. Missing keys behave as empty sets;
+// any key of another type aborts with wrongType.
+func (db *Database) loadSets(keys []resp.Value) (sets []*stringSet, wrongType bool) {
+	sets = make([]*stringSet, len(keys))
+	for i, key := range keys {
+		set, wrongType := db.loadSet(key.Bulk)
+		if wrongType {
+			return nil, true
+		}
+		sets[i] = set
+	}
+	return sets, false
+}
+
+// intersectSets calls yield with each member of the intersection of sets
+// until it returns false. It walks the smallest set and probes the others.
+func intersectSets(sets []*stringSet, yield func(member string) bool) {
+	smallest := 0
+	for i, set := range sets {
+		if set.len() < sets[smallest].len() {
+			smallest = i
+		}
+	}
+members:
+	for _, member := range setToSlice(sets[smallest]) {
+		for i, set := range sets {
+			if i == smallest {
+				continue
+			}
+			if !set.has(member) {
+				continue members
+			}
+		}
+		if !yield(member) {
+			return
+		}
+	}
+}
+
+// computeSetOperation loads every key and combines them with op, as
+// loadSets does. The returned set is always freshly allocated.
+func (db *Database) computeSetOperation(keys []resp.Value, op setOperation) (result *stringSet, wrongType bool) {
+	sets, wrongType := db.loadSets(keys)
+	if wrongType {
+		return nil, true
+	}
+
+	result = newDict[struct{}](0)
+	switch op {
+	case setUnion:
+		for _, set := range sets {
+			for _, member := range setToSlice(set) {
+				result.set(member, struct{}{})
+			}
+		}
+	case setDiff:
+	members:
+		for _, member := range setToSlice(sets[0]) {
+			for _, set := range sets[1:] {
+				if set.has(member) {
+					continue members
+				}
+			}
+			result.set(member, struct{}{})
+		}
+	case setInter:
+		intersectSets(sets, func(member string) bool {
+			result.set(member, struct{}{})
+			return true
+		})
+	}
+	return result, false
+}
+
+func handleSInter(db *Database, args []resp.Value) resp.Value {
+	return setOperationCommand(db, args, setInter)
+}
+
+func handleSUnion(db *Database, args []resp.Value) resp.Value {
+	return setOperationCommand(db, args, setUnion)
+}
+
+func handleSDiff(db *Database, args []resp.Value) resp.Value {
+	return setOperationCommand(db, args, setDiff)
+}
+
+func setOperationCommand(db *Database, args []resp.Value, op setOperation) resp.Value {
+	result, wrongType := db.computeSetOperation(args, op)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	return setMembersSet(setToSlice(result))
+}
+
+func handleSInterStore(db *Database, args []resp.Value) resp.Value {
+	return setOperationStoreCommand(db, args, setInter)
+}
+
+func handleSUnionStore(db *Database, args []resp.Value) resp.Value {
+	return setOperationStoreCommand(db, args, setUnion)
+}
+
+func handleSDiffStore(db *Database, args []resp.Value) resp.Value {
+	return setOperationStoreCommand(db, args, setDiff)
+}
+
+func setOperationStoreCommand(db *Database, args []resp.Value, op setOperation) resp.Value {
+	result, wrongType := db.computeSetOperation(args[1:], op)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	db.storeSet(args[0].Bulk, result)
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(result.len())}
+}
+
+func handleSInterCard(db *Database, args []resp.Value) resp.Value {
+	numKeys, err := strconv.Atoi(args[0].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	if numKeys <= 0 {
+		return resp.Value{DataType: resp.TypeError, Err: errNumKeysNotPositive}
+	}
+	if numKeys > len(args)-1 {
+		return resp.Value{DataType: resp.TypeError, Err: errNumKeysTooLarge}
+	}
+
+	limit := 0
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		if strings.ToUpper(rest[i].Bulk) != "LIMIT" || i+1 >= len(rest) {
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+		limit, err = strconv.Atoi(rest[i+1].Bulk)
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+		}
+		if limit < 0 {
+			return resp.Value{DataType: resp.TypeError, Err: errLimitNegative}
+		}
+		i++
+	}
+
+	sets, wrongType := db.loadSets(args[1 : 1+numKeys])
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	// Counting stops as soon as LIMIT is reached
+	cardinality := 0
+	intersectSets(sets, func(string) bool {
+		cardinality++
+		return limit == 0 || cardinality < limit
+	})
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(cardinality)}
+}
+
+func handleSScan(db *Database, args []resp.Value) resp.Value {
+	opts, errMsg := parseScanArgs(args[1:], false, false)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	set, wrongType := db.loadSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	return scanDict(set, opts, nil)
+}
@@ -0,0 +1,243 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestSRandMemberCountBounds(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSAdd(db, bulkValues("set", "a", "b", "c"))
+
+	for _, count := range []string{"-9223372036854775808", "-4611686018427387904", "9223372036854775807"} {
+		if reply := handleSRandMember(db, bulkValues("set", count)); reply.Err != errOutOfRange {
+			t.Errorf("SRANDMEMBER %s: expected %q, got %+v", count, errOutOfRange, reply)
+		}
+	}
+	if reply := handleSRandMember(db, bulkValues("set", "-7")); len(reply.Array) != 7 {
+		t.Fatalf("expected 7 members, got %+v", reply)
+	}
+	if reply := handleSRandMember(db, bulkValues("set", "10")); len(reply.Array) != 3 {
+		t.Fatalf("expected all 3 members, got %+v", reply)
+	}
+	if reply := handleSRandMember(db, bulkValues("missing", "-3")); len(reply.Array) != 0 {
+		t.Fatalf("expected an empty array for a missing key, got %+v", reply)
+	}
+}
+
+func TestSInterCardLimit(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSAdd(db, bulkValues("a", "1", "2", "3", "4"))
+	handleSAdd(db, bulkValues("b", "2", "3", "4", "5"))
+
+	for limit, expected := range map[string]int64{"0": 3, "1": 1, "2": 2, "3": 3, "10": 3} {
+		if reply := handleSInterCard(db, bulkValues("2", "a", "b", "LIMIT", limit)); reply.Num != expected {
+			t.Errorf("LIMIT %s: expected %d, got %+v", limit, expected, reply)
+		}
+	}
+}
+
+func TestSPopIsUniform(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	members := make([]string, 64)
+	for i := range members {
+		members[i] = strconv.Itoa(i)
+	}
+
+	popped := map[string]int{}
+	for i := 0; i < 6400; i++ {
+		handleSAdd(db, bulkValues(append([]string{"set"}, members...)...))
+		popped[handleSPop(db, bulkValues("set")).Bulk]++
+	}
+	for _, member := range members {
+		// 100 pops are expected for each member
+		if n := popped[member]; n < 40 || n > 200 {
+			t.Errorf("member %s was popped %d times out of 6400", member, n)
+		}
+	}
+}
+
+func TestSAddAndSRem(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	if reply := handleSAdd(db, bulkValues("set", "a", "b", "a")); reply.Num != 2 {
+		t.Fatalf("expected 2 members added, got %+v", reply)
+	}
+	if reply := handleSAdd(db, bulkValues("set", "b", "c")); reply.Num != 1 {
+		t.Fatalf("expected only the new member to count, got %+v", reply)
+	}
+	if reply := handleSRem(db, bulkValues("set", "a", "missing")); reply.Num != 1 {
+		t.Fatalf("expected 1 member removed, got %+v", reply)
+	}
+	if reply := handleSRem(db, bulkValues("missing", "a")); reply.Num != 0 {
+		t.Fatalf("expected 0 for a missing key, got %+v", reply)
+	}
+	handleSRem(db, bulkValues("set", "b", "c"))
+	if reply := handleExists(db, bulkValues("set")); reply.Num != 0 {
+		t.Fatal("removing the last member left an empty set behind")
+	}
+
+	handleSet(db, bulkValues("str", "v"))
+	for _, reply := range []resp.Value{
+		handleSAdd(db, bulkValues("str", "a")),
+		handleSRem(db, bulkValues("str", "a")),
+		handleSInter(db, bulkValues("set", "str")),
+		handleSUnion(db, bulkValues("str")),
+		handleSDiff(db, bulkValues("missing", "str")),
+		handleSPop(db, bulkValues("str")),
+		handleSRandMember(db, bulkValues("str")),
+		handleSInterCard(db, bulkValues("1", "str")),
+	} {
+		if reply.Err != errWrongType {
+			t.Errorf("expected %q, got %+v", errWrongType, reply)
+		}
+	}
+}
+
+// sortedMembers returns the members of a set reply in order.
+func sortedMembers(reply resp.Value) []string {
+	members := make([]string, len(reply.Array))
+	for i, member := range reply.Array {
+		members[i] = member.Bulk
+	}
+	slices.Sort(members)
+	return members
+}
+
+func TestSetAlgebra(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSAdd(db, bulkValues("a", "1", "2", "3", "4"))
+	handleSAdd(db, bulkValues("b", "3", "4", "5"))
+	handleSAdd(db, bulkValues("c", "4", "6"))
+
+	testCases := []struct {
+		name     string
+		reply    resp.Value
+		expected []string
+	}{
+		{"SINTER", handleSInter(db, bulkValues("a", "b", "c")), []string{"4"}},
+		{"SINTER With A Missing Key", handleSInter(db, bulkValues("a", "missing")), []string{}},
+		{"SUNION", handleSUnion(db, bulkValues("a", "b", "c", "missing")), []string{"1", "2", "3", "4", "5", "6"}},
+		{"SDIFF", handleSDiff(db, bulkValues("a", "b", "missing")), []string{"1", "2"}},
+		{"SDIFF Of A Missing Key", handleSDiff(db, bulkValues("missing", "a")), []string{}},
+	}
+	for _, tc := range testCases {
+		if tc.reply.DataType != resp.TypeSet {
+			t.Errorf("%s: expected a set reply, got %+v", tc.name, tc.reply)
+		}
+		if got := sortedMembers(tc.reply); !slices.Equal(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+
+	if reply := handleSInterStore(db, bulkValues("dest", "a", "b")); reply.Num != 2 {
+		t.Fatalf("expected 2 members stored, got %+v", reply)
+	}
+	if got := sortedMembers(handleSMembers(db, bulkValues("dest"))); !slices.Equal(got, []string{"3", "4"}) {
+		t.Fatalf("expected the intersection to be stored, got %v", got)
+	}
+	handleSInterStore(db, bulkValues("dest", "a", "missing"))
+	if reply := handleExists(db, bulkValues("dest")); reply.Num != 0 {
+		t.Fatal("storing an empty result left the destination behind")
+	}
+}
+
+func TestSInterCard(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSAdd(db, bulkValues("a", "1", "2", "3"))
+	handleSAdd(db, bulkValues("b", "2", "3"))
+
+	if reply := handleSInterCard(db, bulkValues("2", "a", "b")); reply.Num != 2 {
+		t.Fatalf("expected 2, got %+v", reply)
+	}
+	if reply := handleSInterCard(db, bulkValues("2", "a", "missing")); reply.Num != 0 {
+		t.Fatalf("expected 0, got %+v", reply)
+	}
+
+	testCases := []struct {
+		args        []string
+		expectedErr string
+	}{
+		{[]string{"0", "a"}, errNumKeysNotPositive},
+		{[]string{"x", "a"}, errNotInteger},
+		{[]string{"3", "a", "b"}, errNumKeysTooLarge},
+		{[]string{"1", "a", "LIMIT"}, errSyntax},
+		{[]string{"1", "a", "LIMIT", "-1"}, errLimitNegative},
+		{[]string{"1", "a", "LIMIT", "x"}, errNotInteger},
+		{[]string{"1", "a", "b"}, errSyntax},
+	}
+	for _, tc := range testCases {
+		if reply := handleSInterCard(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+			t.Errorf("SINTERCARD %v: expected %q, got %+v", tc.args, tc.expectedErr, reply)
+		}
+	}
+}
+
+func TestSPop(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSAdd(db, bulkValues("set", "a", "b", "c", "d"))
+
+	reply := handleSPop(db, bulkValues("set"))
+	if reply.DataType != resp.TypeBulk {
+		t.Fatalf("expected a single member, got %+v", reply)
+	}
+	if ismember := handleSIsMember(db, bulkValues("set", reply.Bulk)); ismember.Num != 0 {
+		t.Fatalf("the popped member %s is still in the set", reply.Bulk)
+	}
+	if reply := handleSPop(db, bulkValues("set", "2")); len(reply.Array) != 2 {
+		t.Fatalf("expected 2 members, got %+v", reply)
+	}
+	if reply := handleSPop(db, bulkValues("set", "0")); reply.DataType != resp.TypeArray || len(reply.Array) != 0 {
+		t.Fatalf("expected an empty array, got %+v", reply)
+	}
+	if reply := handleSPop(db, bulkValues("set", "10")); len(reply.Array) != 1 {
+		t.Fatalf("expected the last member, got %+v", reply)
+	}
+	if reply := handleExists(db, bulkValues("set")); reply.Num != 0 {
+		t.Fatal("popping the last member left an empty set behind")
+	}
+	if reply := handleSPop(db, bulkValues("set")); !reply.IsNull {
+		t.Fatalf("expected a null reply for a missing key, got %+v", reply)
+	}
+
+	for _, count := range []string{"-1", "x", "-9223372036854775808"} {
+		if reply := handleSPop(db, bulkValues("set", count)); reply.Err != errNotPositive {
+			t.Errorf("SPOP %s: expected %q, got %+v", count, errNotPositive, reply)
+		}
+	}
+	if reply := handleSPop(db, bulkValues("set", "1", "2")); reply.Err != wrongArityError("SPOP") {
+		t.Fatalf("expected %q, got %+v", wrongArityError("SPOP"), reply)
+	}
+}
+
+func TestSRandMember(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	if reply := handleSRandMember(db, bulkValues("missing")); !reply.IsNull {
+		t.Fatalf("expected a null reply for a missing key, got %+v", reply)
+	}
+
+	handleSAdd(db, bulkValues("set", "a", "b", "c"))
+	if reply := handleSRandMember(db, bulkValues("set")); reply.DataType != resp.TypeBulk || reply.Bulk == "" {
+		t.Fatalf("expected a member, got %+v", reply)
+	}
+	reply := handleSRandMember(db, bulkValues("set", "2"))
+	if len(reply.Array) != 2 || reply.Array[0].Bulk == reply.Array[1].Bulk {
+		t.Fatalf("expected 2 distinct members, got %+v", reply)
+	}
+	if reply := handleSCard(db, bulkValues("set")); reply.Num != 3 {
+		t.Fatalf("SRANDMEMBER removed members: %+v", reply)
+	}
+	if reply := handleSRandMember(db, bulkValues("set", "x")); reply.Err != errNotInteger {
+		t.Fatalf("expected %q, got %+v", errNotInteger, reply)
+	}
+}
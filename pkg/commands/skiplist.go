@@ -0,0 +1,406 @@
+package commands
+
+import (
+	"math/rand/v2"
+)
+
+// The sorted set implementation follows the classic Redis design: a map
+// gives O(1) score lookups by member while a skiplist keeps members ordered
+// by (score, member). Every forward pointer records how many nodes it skips
+// ("span"), which is what makes rank queries O(log N).
+
+const (
+	skiplistMaxLevel = 32
+	skiplistP        = 0.25
+)
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+func newSkiplistNode(level int, score float64, member string) *skiplistNode {
+	return &skiplistNode{
+		member: member,
+		score:  score,
+		level:  make([]skiplistLevel, level),
+	}
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: newSkiplistNode(skiplistMaxLevel, 0, ""),
+		level:  1,
+	}
+}
+
+func randomSkiplistLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && float64(rand.Uint32()&0xFFFF) < skiplistP*0xFFFF {
+		level++
+	}
+	return level
+}
+
+// precedes reports whether node sorts strictly before (score, member).
+func (n *skiplistNode) precedes(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// insert adds a new node. The caller must make sure the member is not
+// already present.
+func (zsl *skiplist) insert(score float64, member string) *skiplistNode {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i != zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.precedes(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomSkiplistLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = newSkiplistNode(level, score, member)
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	// Levels above the new node now skip one more element
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+	return x
+}
+
+func (zsl *skiplist) deleteNode(x *skiplistNode, update *[skiplistMaxLevel]*skiplistNode) {
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+// delete removes the node matching both score and member, reporting
+// whether it was found.
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.precedes(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x != nil && x.score == score && x.member == member {
+		zsl.deleteNode(x, &update)
+		return true
+	}
+	return false
+}
+
+// rank returns the 1-based rank of the element, or 0 when it is missing.
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.precedes(score, member) ||
+				(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node at the given 1-based rank, or nil when the rank
+// is out of range.
+func (zsl *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank && x != zsl.header {
+			return x
+		}
+	}
+	return nil
+}
+
+// scoreRange is a score interval as accepted by ZRANGE BYSCORE and ZCOUNT.
+type scoreRange struct {
+	min, max                   float64
+	minExclusive, maxExclusive bool
+}
+
+func (r scoreRange) gteMin(score float64) bool {
+	if r.minExclusive {
+		return score > r.min
+	}
+	return score >= r.min
+}
+
+func (r scoreRange) lteMax(score float64) bool {
+	if r.maxExclusive {
+		return score < r.max
+	}
+	return score <= r.max
+}
+
+func (zsl *skiplist) isInScoreRange(r scoreRange) bool {
+	if r.min > r.max || (r.min == r.max && (r.minExclusive || r.maxExclusive)) {
+		return false
+	}
+	if zsl.tail == nil || !r.gteMin(zsl.tail.score) {
+		return false
+	}
+	return r.lteMax(zsl.header.level[0].forward.score)
+}
+
+func (zsl *skiplist) firstInScoreRange(r scoreRange) *skiplistNode {
+	if !zsl.isInScoreRange(r) {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if !r.lteMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInScoreRange(r scoreRange) *skiplistNode {
+	if !zsl.isInScoreRange(r) {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	if !r.gteMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+// lexBound is one end of a ZRANGE BYLEX interval. inf is -1 for "-", 1 for
+// "+" and 0 when value holds an actual string.
+type lexBound struct {
+	value     string
+	exclusive bool
+	inf       int
+}
+
+type lexRange struct {
+	min, max lexBound
+}
+
+func (r lexRange) gteMin(member string) bool {
+	switch r.min.inf {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	if r.min.exclusive {
+		return member > r.min.value
+	}
+	return member >= r.min.value
+}
+
+func (r lexRange) lteMax(member string) bool {
+	switch r.max.inf {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+	if r.max.exclusive {
+		return member < r.max.value
+	}
+	return member <= r.max.value
+}
+
+func (r lexRange) isEmpty() bool {
+	if r.min.inf == 1 || r.max.inf == -1 {
+		return true
+	}
+	if r.min.inf != 0 || r.max.inf != 0 {
+		return false
+	}
+	return r.min.value > r.max.value ||
+		(r.min.value == r.max.value && (r.min.exclusive || r.max.exclusive))
+}
+
+func (zsl *skiplist) isInLexRange(r lexRange) bool {
+	if r.isEmpty() {
+		return false
+	}
+	if zsl.tail == nil || !r.gteMin(zsl.tail.member) {
+		return false
+	}
+	return r.lteMax(zsl.header.level[0].forward.member)
+}
+
+func (zsl *skiplist) firstInLexRange(r lexRange) *skiplistNode {
+	if !zsl.isInLexRange(r) {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if !r.lteMax(x.member) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInLexRange(r lexRange) *skiplistNode {
+	if !zsl.isInLexRange(r) {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+	if !r.gteMin(x.member) {
+		return nil
+	}
+	return x
+}
+
+// sortedSet is the value stored in a TypeZSet Record.
+type sortedSet struct {
+	dict *dict[float64]
+	zsl  *skiplist
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{
+		dict: newDict[float64](0),
+		zsl:  newSkiplist(),
+	}
+}
+
+func (zs *sortedSet) len() int {
+	return zs.dict.len()
+}
+
+func (zs *sortedSet) score(member string) (float64, bool) {
+	return zs.dict.get(member)
+}
+
+// add inserts member or moves it to its new score, reporting whether the
+// member was newly added.
+func (zs *sortedSet) add(member string, score float64) bool {
+	if current, ok := zs.dict.get(member); ok {
+		if current != score {
+			zs.zsl.delete(current, member)
+			zs.zsl.insert(score, member)
+			zs.dict.set(member, score)
+		}
+		return false
+	}
+	zs.zsl.insert(score, member)
+	zs.dict.set(member, score)
+	return true
+}
+
+func (zs *sortedSet) remove(member string) bool {
+	score, ok := zs.dict.get(member)
+	if !ok {
+		return false
+	}
+	zs.zsl.delete(score, member)
+	zs.dict.delete(member)
+	return true
+}
+
+// rank returns the 0-based position of member, counted from the highest
+// score when reverse is set.
+func (zs *sortedSet) rank(member string, reverse bool) (int, bool) {
+	score, ok := zs.dict.get(member)
+	if !ok {
+		return 0, false
+	}
+	rank := zs.zsl.rank(score, member)
+	if reverse {
+		return zs.zsl.length - rank, true
+	}
+	return rank - 1, true
+}
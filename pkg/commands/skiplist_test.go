@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestSortedSetMatchesSortedSlice(t *testing.T) {
+	zset := newSortedSet()
+	reference := make(map[string]float64)
+
+	for i := 0; i < 5000; i++ {
+		member := "m" + strconv.Itoa(rand.IntN(500))
+		switch rand.IntN(3) {
+		case 0, 1:
+			score := float64(rand.IntN(100))
+			zset.add(member, score)
+			reference[member] = score
+		case 2:
+			_, expected := reference[member]
+			if removed := zset.remove(member); removed != expected {
+				t.Fatalf("remove(%q) = %v, expected %v", member, removed, expected)
+			}
+			delete(reference, member)
+		}
+	}
+
+	type entry struct {
+		member string
+		score  float64
+	}
+	expected := make([]entry, 0, len(reference))
+	for member, score := range reference {
+		expected = append(expected, entry{member, score})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].score != expected[j].score {
+			return expected[i].score < expected[j].score
+		}
+		return expected[i].member < expected[j].member
+	})
+
+	if zset.len() != len(expected) || zset.zsl.length != len(expected) {
+		t.Fatalf("length mismatch: dict %d, skiplist %d, expected %d", zset.len(), zset.zsl.length, len(expected))
+	}
+
+	node := zset.zsl.header.level[0].forward
+	for i, e := range expected {
+		if node == nil || node.member != e.member || node.score != e.score {
+			t.Fatalf("position %d: got %+v, expected %+v", i, node, e)
+		}
+		if rank, _ := zset.rank(e.member, false); rank != i {
+			t.Fatalf("rank(%q) = %d, expected %d", e.member, rank, i)
+		}
+		if rank, _ := zset.rank(e.member, true); rank != len(expected)-1-i {
+			t.Fatalf("reverse rank(%q) = %d, expected %d", e.member, rank, len(expected)-1-i)
+		}
+		if byRank := zset.zsl.byRank(i + 1); byRank != node {
+			t.Fatalf("byRank(%d) returned the wrong node", i+1)
+		}
+		if i > 0 && node.backward.member != expected[i-1].member {
+			t.Fatalf("broken backward link at position %d", i)
+		}
+		node = node.level[0].forward
+	}
+	if node != nil {
+		t.Fatal("skiplist has more nodes than expected")
+	}
+}
+
+func TestSkiplistScoreRange(t *testing.T) {
+	zset := newSortedSet()
+	for i := 1; i <= 10; i++ {
+		zset.add("m"+strconv.Itoa(i), float64(i))
+	}
+
+	testCases := []struct {
+		name        string
+		r           scoreRange
+		first, last string
+	}{
+		{"inclusive", scoreRange{min: 3, max: 5}, "m3", "m5"},
+		{"exclusive", scoreRange{min: 3, max: 5, minExclusive: true, maxExclusive: true}, "m4", "m4"},
+		{"below", scoreRange{min: -10, max: 0}, "", ""},
+		{"above", scoreRange{min: 11, max: 20}, "", ""},
+		{"empty interval", scoreRange{min: 5, max: 5, minExclusive: true}, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			first := zset.zsl.firstInScoreRange(tc.r)
+			last := zset.zsl.lastInScoreRange(tc.r)
+			if tc.first == "" {
+				if first != nil || last != nil {
+					t.Fatalf("expected an empty range, got %v..%v", first, last)
+				}
+				return
+			}
+			if first == nil || first.member != tc.first || last == nil || last.member != tc.last {
+				t.Fatalf("expected %s..%s, got %v..%v", tc.first, tc.last, first, last)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errStringTooLong    = "ERR string exceeds maximum allowed size (proto-max-bulk-len)"
+	errOffsetOutOfRange = "ERR offset is out of range"
+	errLCSLenAndIdx     = "ERR If you want both the length and indexes, please just use IDX."
+	errLCSTooLarge      = "ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len"
+)
+
+// storeString stores value at key, keeping the TTL of the string it
+// replaces. record is the record lookupKey returned for key, if any.
+func (db *Database) storeString(key string, record Record, value string) {
+	db.storeRecord(key, Record{Type: TypeString, Value: value, ExpiryTime: record.ExpiryTime})
+}
+
+// handleAppend keeps the value it appends to as a byte slice, like a
+// bitmap, which grows in place with room to spare. Building a string with
+// repeated APPENDs thus takes time linear in its length, where appending to
+// an immutable string would copy all of it every time.
+func handleAppend(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	value, record, wrongType := db.loadBitmap(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if len(value)+len(args[1].Bulk) > resp.MaxBulkLen() {
+		return resp.Value{DataType: resp.TypeError, Err: errStringTooLong}
+	}
+
+	value = append(value, args[1].Bulk...)
+	db.storeBitmap(key, record, value)
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(len(value))}
+}
+
+func handleStrLen(db *Database, args []resp.Value) resp.Value {
+	value, _, wrongType := db.loadBitmapView(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(value.len())}
+}
+
+// handleGetRange returns the bytes of a string between two inclusive
+// offsets, which count from the end when negative and are clamped to the
+// string.
+func handleGetRange(db *Database, args []resp.Value) resp.Value {
+	start, err1 := strconv.Atoi(args[1].Bulk)
+	end, err2 := strconv.Atoi(args[2].Bulk)
+	if err1 != nil || err2 != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	value, _, wrongType := db.loadBitmapView(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	empty := resp.Value{DataType: resp.TypeBulk, Bulk: ""}
+	if start < 0 && end < 0 && start > end {
+		return empty
+	}
+	n := value.len()
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = max(n+end, 0)
+	}
+	end = min(end, n-1)
+	if start > end || n == 0 {
+		return empty
+	}
+	return resp.Value{DataType: resp.TypeBulk, Bulk: value.slice(start, end+1)}
+}
+
+// handleSetRange overwrites part of a string starting at offset, padding it
+// with zero bytes first if it is shorter than offset. An empty value
+// changes nothing, so it does not create a missing key either.
+func handleSetRange(db *Database, args []resp.Value) resp.Value {
+	key, patch := args[0].Bulk, args[2].Bulk
+	offset, err := strconv.Atoi(args[1].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	if offset < 0 {
+		return resp.Value{DataType: resp.TypeError, Err: errOffsetOutOfRange}
+	}
+
+	record, exists := db.lookupKey(key)
+	value := ""
+	if exists {
+		if record.Type != TypeString {
+			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+		}
+		value = stringValue(record)
+	}
+	if patch == "" {
+		return resp.Value{DataType: resp.TypeInteger, Num: int64(len(value))}
+	}
+	if offset > resp.MaxBulkLen()-len(patch) {
+		return resp.Value{DataType: resp.TypeError, Err: errStringTooLong}
+	}
+
+	buf := make([]byte, max(len(value), offset+len(patch)))
+	copy(buf, value)
+	copy(buf[offset:], patch)
+	db.storeString(key, record, string(buf))
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(len(buf))}
+}
+
+// handleMGet returns the value of each key, or nil for one that is missing
+// or does not hold a string.
+func handleMGet(db *Database, args []resp.Value) resp.Value {
+	result := make([]resp.Value, len(args))
+	for i, arg := range args {
+		if value, exists, _ := db.loadString(arg.Bulk); exists {
+			result[i] = resp.Value{DataType: resp.TypeBulk, Bulk: value}
+		} else {
+			result[i] = resp.Value{DataType: resp.TypeNull, IsNull: true}
+		}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
+
+func handleMSet(db *Database, args []resp.Value) resp.Value {
+	if len(args)%2 != 0 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError("MSET")}
+	}
+	for i := 0; i < len(args); i += 2 {
+		db.storeRecord(args[i].Bulk, Record{Type: TypeString, Value: args[i+1].Bulk})
+	}
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+// handleMSetNX sets every key, or none of them if any already exists.
+func handleMSetNX(db *Database, args []resp.Value) resp.Value {
+	if len(args)%2 != 0 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError("MSETNX")}
+	}
+	for i := 0; i < len(args); i += 2 {
+		if _, exists := db.lookupKey(args[i].Bulk); exists {
+			return resp.Value{DataType: resp.TypeInteger, Num: 0}
+		}
+	}
+	handleMSet(db, args)
+	return resp.Value{DataType: resp.TypeInteger, Num: 1}
+}
+
+func handleGetSet(db *Database, args []resp.Value) resp.Value {
+	return handleSet(db, []resp.Value{args[0], args[1], {DataType: resp.TypeBulk, Bulk: "GET"}})
+}
+
+func handleSetNX(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	if _, exists := db.lookupKey(key); exists {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	db.storeRecord(key, Record{Type: TypeString, Value: args[1].Bulk})
+	return resp.Value{DataType: resp.TypeInteger, Num: 1}
+}
+
+func handleSetEx(db *Database, args []resp.Value) resp.Value {
+	return setWithExpiry(db, args, "EX", "setex")
+}
+
+func handlePSetEx(db *Database, args []resp.Value) resp.Value {
+	return setWithExpiry(db, args, "PX", "psetex")
+}
+
+// setWithExpiry backs SETEX and PSETEX, which take "key ttl value" with the
+// ttl in the given unit.
+func setWithExpiry(db *Database, args []resp.Value, unit, command string) resp.Value {
+	ttl, err := strconv.ParseInt(args[1].Bulk, 10, 64)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	at, ok := expiryUnixMilli(unit, ttl)
+	if !ok || ttl <= 0 {
+		return resp.Value{DataType: resp.TypeError, Err: errInvalidExpireTime(command)}
+	}
+	expiryTime := time.UnixMilli(at)
+	db.storeRecord(args[0].Bulk, Record{Type: TypeString, Value: args[2].Bulk, ExpiryTime: &expiryTime})
+	return resp.Value{DataType: resp.TypeString, Str: okResponse}
+}
+
+// handleLCS finds the longest common subsequence of two strings, missing
+// keys counting as empty strings. It replies with the subsequence itself,
+// its length with LEN, or with IDX the ranges of both strings that make it
+// up, from the last to the first, skipping ranges shorter than
+// MINMATCHLEN.
+func handleLCS(db *Database, args []resp.Value) resp.Value {
+	var getLen, getIdx, withMatchLen bool
+	minMatchLen := 0
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i].Bulk); {
+		case option == "LEN":
+			getLen = true
+		case option == "IDX":
+			getIdx = true
+		case option == "WITHMATCHLEN":
+			withMatchLen = true
+		case option == "MINMATCHLEN" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1].Bulk)
+			if err != nil {
+				return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+			}
+			minMatchLen = max(n, 0)
+			i++
+		default:
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+	}
+	if getLen && getIdx {
+		return resp.Value{DataType: resp.TypeError, Err: errLCSLenAndIdx}
+	}
+
+	a, _, wrongType := db.loadString(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	b, _, wrongType := db.loadString(args[1].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if (len(a)+1)*(len(b)+1) > resp.MaxBulkLen()/4 {
+		return resp.Value{DataType: resp.TypeError, Err: errLCSTooLarge}
+	}
+
+	// lengths[i*(len(b)+1)+j] is the length of the LCS of a[:i] and b[:j]
+	width := len(b) + 1
+	lengths := make([]int32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				lengths[i*width+j] = lengths[(i-1)*width+j-1] + 1
+			} else {
+				lengths[i*width+j] = max(lengths[(i-1)*width+j], lengths[i*width+j-1])
+			}
+		}
+	}
+	total := int(lengths[len(a)*width+len(b)])
+	if getLen {
+		return resp.Value{DataType: resp.TypeInteger, Num: int64(total)}
+	}
+
+	// Walk back from the end, collecting the subsequence and the ranges of
+	// consecutive matches it is made of
+	subsequence := make([]byte, total)
+	var matches []resp.Value
+	rangeLen := 0
+	var aEnd, bEnd int
+	emit := func(aStart, bStart int) {
+		if rangeLen > 0 && rangeLen >= minMatchLen {
+			match := []resp.Value{rangeValue(aStart, aEnd), rangeValue(bStart, bEnd)}
+			if withMatchLen {
+				match = append(match, resp.Value{DataType: resp.TypeInteger, Num: int64(rangeLen)})
+			}
+			matches = append(matches, resp.Value{DataType: resp.TypeArray, Array: match})
+		}
+		rangeLen = 0
+	}
+	for i, j, k := len(a), len(b), total; i > 0 && j > 0; {
+		switch {
+		case a[i-1] == b[j-1]:
+			k--
+			subsequence[k] = a[i-1]
+			if rangeLen > 0 && (i != aEnd-rangeLen+1 || j != bEnd-rangeLen+1) {
+				emit(aEnd-rangeLen+1, bEnd-rangeLen+1)
+			}
+			if rangeLen == 0 {
+				aEnd, bEnd = i-1, j-1
+			}
+			rangeLen++
+			i--
+			j--
+		case lengths[(i-1)*width+j] > lengths[i*width+j-1]:
+			i--
+		default:
+			j--
+		}
+	}
+	emit(aEnd-rangeLen+1, bEnd-rangeLen+1)
+
+	if !getIdx {
+		return resp.Value{DataType: resp.TypeBulk, Bulk: string(subsequence)}
+	}
+	if matches == nil {
+		matches = []resp.Value{}
+	}
+	return resp.Value{DataType: resp.TypeMap, Array: []resp.Value{
+		{DataType: resp.TypeBulk, Bulk: "matches"},
+		{DataType: resp.TypeArray, Array: matches},
+		{DataType: resp.TypeBulk, Bulk: "len"},
+		{DataType: resp.TypeInteger, Num: int64(total)},
+	}}
+}
+
+// rangeValue is an inclusive range of offsets as LCS IDX replies with it.
+func rangeValue(start, end int) resp.Value {
+	return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{
+		{DataType: resp.TypeInteger, Num: int64(start)},
+		{DataType: resp.TypeInteger, Num: int64(end)},
+	}}
+}
@@ -0,0 +1,264 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"strings"
+	"testing"
+)
+
+func TestAppendAndStrLen(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+
+	if reply := handleAppend(db, bulkValues("key", "Hello")); reply.Num != 5 {
+		t.Fatalf("expected 5, got %+v", reply)
+	}
+	handleExpire(db, bulkValues("key", "100"))
+	if reply := handleAppend(db, bulkValues("key", " World")); reply.Num != 11 {
+		t.Fatalf("expected 11, got %+v", reply)
+	}
+	if record, _ := db.lookupKey("key"); stringValue(record) != "Hello World" || record.ExpiryTime == nil {
+		t.Fatalf("APPEND lost the value or its TTL: %+v", record)
+	}
+	if reply := handleGet(db, bulkValues("key")); reply.Bulk != "Hello World" {
+		t.Fatalf("expected Hello World, got %+v", reply)
+	}
+	if reply := handleStrLen(db, bulkValues("key")); reply.Num != 11 {
+		t.Fatalf("expected 11, got %+v", reply)
+	}
+	if reply := handleStrLen(db, bulkValues("missing")); reply.Num != 0 {
+		t.Fatalf("expected 0 for a missing key, got %+v", reply)
+	}
+
+	handleRPush(db, bulkValues("list", "a"))
+	for _, reply := range []resp.Value{
+		handleAppend(db, bulkValues("list", "x")),
+		handleStrLen(db, bulkValues("list")),
+	} {
+		if reply.Err != errWrongType {
+			t.Errorf("expected %q, got %+v", errWrongType, reply)
+		}
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("key", "This is a string"))
+
+	testCases := []struct {
+		start, end string
+		expected   string
+	}{
+		{"0", "3", "This"},
+		{"-3", "-1", "ing"},
+		{"0", "-1", "This is a string"},
+		{"10", "100", "string"},
+		{"-100", "3", "This"},
+		{"5", "2", ""},
+		{"-1", "-5", ""},
+		{"100", "200", ""},
+	}
+	for _, tc := range testCases {
+		if reply := handleGetRange(db, bulkValues("key", tc.start, tc.end)); reply.Bulk != tc.expected {
+			t.Errorf("GETRANGE %s %s: expected %q, got %+v", tc.start, tc.end, tc.expected, reply)
+		}
+	}
+	if reply := handleGetRange(db, bulkValues("missing", "0", "-1")); reply.DataType != resp.TypeBulk || reply.Bulk != "" {
+		t.Fatalf("expected an empty string for a missing key, got %+v", reply)
+	}
+}
+
+func TestSetRange(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("key", "Hello World", "EX", "100"))
+
+	if reply := handleSetRange(db, bulkValues("key", "6", "Redis")); reply.Num != 11 {
+		t.Fatalf("expected 11, got %+v", reply)
+	}
+	if record, _ := db.lookupKey("key"); record.Value != "Hello Redis" || record.ExpiryTime == nil {
+		t.Fatalf("SETRANGE lost the value or its TTL: %+v", record)
+	}
+	if reply := handleSetRange(db, bulkValues("padded", "3", "x")); reply.Num != 4 {
+		t.Fatalf("expected 4, got %+v", reply)
+	}
+	if value, _, _ := db.loadString("padded"); value != "\x00\x00\x00x" {
+		t.Fatalf("expected zero padding, got %q", value)
+	}
+	if reply := handleSetRange(db, bulkValues("missing", "10", "")); reply.Num != 0 {
+		t.Fatalf("expected 0, got %+v", reply)
+	}
+	if _, ok := db.lookupKey("missing"); ok {
+		t.Fatal("SETRANGE with an empty value created the key")
+	}
+
+	testCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{"Negative Offset", []string{"key", "-1", "x"}, errOffsetOutOfRange},
+		{"Not A Number", []string{"key", "x", "x"}, errNotInteger},
+		{"Too Long", []string{"key", "536870911", "xy"}, errStringTooLong},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if reply := handleSetRange(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+				t.Errorf("expected %q, got %+v", tc.expectedErr, reply)
+			}
+		})
+	}
+}
+
+func TestMGetMSetAndMSetNX(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("a", "old", "EX", "100"))
+	handleSAdd(db, bulkValues("set", "x"))
+
+	if reply := handleMSet(db, bulkValues("a", "1", "b", "2")); reply.Str != okResponse {
+		t.Fatalf("MSET failed: %+v", reply)
+	}
+	if record, _ := db.lookupKey("a"); record.ExpiryTime != nil {
+		t.Fatal("MSET kept the TTL of the key it overwrote")
+	}
+	reply := handleMGet(db, bulkValues("a", "b", "missing", "set"))
+	if len(reply.Array) != 4 || reply.Array[0].Bulk != "1" || reply.Array[1].Bulk != "2" ||
+		!reply.Array[2].IsNull || !reply.Array[3].IsNull {
+		t.Fatalf("unexpected MGET reply %+v", reply)
+	}
+	if reply := handleMSet(db, bulkValues("a", "1", "b")); reply.Err != wrongArityError("MSET") {
+		t.Fatalf("expected %q, got %+v", wrongArityError("MSET"), reply)
+	}
+
+	if reply := handleMSetNX(db, bulkValues("c", "3", "a", "x")); reply.Num != 0 {
+		t.Fatalf("expected MSETNX to fail, got %+v", reply)
+	}
+	if _, ok := db.lookupKey("c"); ok {
+		t.Fatal("a failed MSETNX set some of its keys")
+	}
+	if reply := handleMSetNX(db, bulkValues("c", "3", "d")); reply.Err != wrongArityError("MSETNX") {
+		t.Fatalf("expected %q, got %+v", wrongArityError("MSETNX"), reply)
+	}
+	if reply := handleMSetNX(db, bulkValues("c", "3", "d", "4")); reply.Num != 1 {
+		t.Fatalf("expected MSETNX to succeed, got %+v", reply)
+	}
+}
+
+func TestGetSetSetNXAndSetEx(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("key", "old", "EX", "100"))
+
+	if reply := handleGetSet(db, bulkValues("key", "new")); reply.Bulk != "old" {
+		t.Fatalf("expected old, got %+v", reply)
+	}
+	if record, _ := db.lookupKey("key"); record.Value != "new" || record.ExpiryTime != nil {
+		t.Fatalf("expected GETSET to set the value and drop the TTL, got %+v", record)
+	}
+	if reply := handleGetSet(db, bulkValues("missing", "v")); !reply.IsNull {
+		t.Fatalf("expected nil for a missing key, got %+v", reply)
+	}
+
+	if reply := handleSetNX(db, bulkValues("key", "other")); reply.Num != 0 {
+		t.Fatalf("expected SETNX to leave an existing key alone, got %+v", reply)
+	}
+	if reply := handleSetNX(db, bulkValues("fresh", "v")); reply.Num != 1 {
+		t.Fatalf("expected SETNX to set a missing key, got %+v", reply)
+	}
+
+	if reply := handleSetEx(db, bulkValues("key", "100", "v")); reply.Str != okResponse {
+		t.Fatalf("SETEX failed: %+v", reply)
+	}
+	if reply := handleTTL(db, bulkValues("key")); reply.Num != 100 {
+		t.Fatalf("expected a TTL of 100, got %+v", reply)
+	}
+	if reply := handlePSetEx(db, bulkValues("key", "5000", "v")); reply.Str != okResponse {
+		t.Fatalf("PSETEX failed: %+v", reply)
+	}
+	if reply := handleTTL(db, bulkValues("key")); reply.Num != 5 {
+		t.Fatalf("expected a TTL of 5, got %+v", reply)
+	}
+	for _, ttl := range []string{"0", "-1"} {
+		if reply := handleSetEx(db, bulkValues("key", ttl, "v")); reply.Err != errInvalidExpireTime("setex") {
+			t.Errorf("expected SETEX with %s to be rejected, got %+v", ttl, reply)
+		}
+	}
+}
+
+func TestLCS(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleMSet(db, bulkValues("key1", "ohmytext", "key2", "mynewtext"))
+
+	if reply := handleLCS(db, bulkValues("key1", "key2")); reply.Bulk != "mytext" {
+		t.Fatalf("expected mytext, got %+v", reply)
+	}
+	if reply := handleLCS(db, bulkValues("key1", "key2", "LEN")); reply.Num != 6 {
+		t.Fatalf("expected 6, got %+v", reply)
+	}
+	if reply := handleLCS(db, bulkValues("key1", "missing")); reply.DataType != resp.TypeBulk || reply.Bulk != "" {
+		t.Fatalf("expected an empty string, got %+v", reply)
+	}
+
+	reply := handleLCS(db, bulkValues("key1", "key2", "IDX", "WITHMATCHLEN"))
+	matches := reply.Array[1].Array
+	if reply.DataType != resp.TypeMap || reply.Array[3].Num != 6 || len(matches) != 2 {
+		t.Fatalf("unexpected LCS IDX reply %+v", reply)
+	}
+	for i, expected := range [][5]int64{{4, 7, 5, 8, 4}, {2, 3, 0, 1, 2}} {
+		match := matches[i].Array
+		got := [5]int64{match[0].Array[0].Num, match[0].Array[1].Num, match[1].Array[0].Num, match[1].Array[1].Num, match[2].Num}
+		if got != expected {
+			t.Errorf("expected match %d to be %v, got %v", i, expected, got)
+		}
+	}
+
+	reply = handleLCS(db, bulkValues("key1", "key2", "IDX", "MINMATCHLEN", "4"))
+	if matches := reply.Array[1].Array; len(matches) != 1 || len(matches[0].Array) != 2 {
+		t.Fatalf("expected MINMATCHLEN to leave one match, got %+v", reply)
+	}
+
+	testCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{"Len And Idx", []string{"key1", "key2", "LEN", "IDX"}, errLCSLenAndIdx},
+		{"Unknown Option", []string{"key1", "key2", "ALL"}, errSyntax},
+		{"MinMatchLen Not A Number", []string{"key1", "key2", "MINMATCHLEN", "x"}, errNotInteger},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if reply := handleLCS(db, bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+				t.Errorf("expected %q, got %+v", tc.expectedErr, reply)
+			}
+		})
+	}
+
+	handleSet(db, bulkValues("long", strings.Repeat("x", 20000)))
+	if reply := handleLCS(db, bulkValues("long", "long")); reply.Err != errLCSTooLarge {
+		t.Fatalf("expected %q, got %+v", errLCSTooLarge, reply)
+	}
+}
+
+func TestAppendGrowsInPlace(t *testing.T) {
+	flushKeyspace()
+	db := databases[0]
+	handleSet(db, bulkValues("key", "a"))
+
+	for range 1000 {
+		handleAppend(db, bulkValues("key", "b"))
+	}
+	record, _ := db.lookupKey("key")
+	before, ok := record.Value.([]byte)
+	if !ok || len(before) != 1001 || cap(before) == len(before) {
+		t.Fatalf("expected a byte slice with room to grow, got %T of length %d", record.Value, len(before))
+	}
+	handleAppend(db, bulkValues("key", "b"))
+	record, _ = db.lookupKey("key")
+	if after := record.Value.([]byte); &after[0] != &before[0] {
+		t.Fatal("APPEND copied a value it had room to grow in place")
+	}
+}
@@ -0,0 +1,806 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	errZAddXXAndNX       = "ERR XX and NX options at the same time are not compatible"
+	errZAddGTLTNX        = "ERR GT, LT, and/or NX options at the same time are not compatible"
+	errZAddIncrPair      = "ERR INCR option supports a single increment-element pair"
+	errScoreNaN          = "ERR resulting score is not a number (NaN)"
+	errMinMaxNotFloat    = "ERR min or max is not a float"
+	errMinMaxNotLex      = "ERR min or max not valid string range item"
+	errWeightNotFloat    = "ERR weight value is not a float"
+	errLimitNeedsRange   = "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX"
+	errWithScoresBYLEX   = "ERR syntax error, WITHSCORES not supported in combination with BYLEX"
+	errZStoreNeedsOneKey = "ERR at least 1 input key is needed for this command"
+)
+
+// loadZSet returns the sorted set stored at key. A missing key yields nil,
+// while a key holding another type reports wrongType.
+func (db *Database) loadZSet(key string) (zset *sortedSet, wrongType bool) {
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return nil, false
+	}
+	if record.Type != TypeZSet {
+		return nil, true
+	}
+	return record.Value.(*sortedSet), false
+}
+
+// storeZSet replaces whatever is stored at key with zset, deleting the key
+// instead when the sorted set is empty.
+func (db *Database) storeZSet(key string, zset *sortedSet) {
+	if zset.len() == 0 {
+		db.deleteKey(key)
+		return
+	}
+	db.storeRecord(key, Record{Type: TypeZSet, Value: zset})
+}
+
+// deleteZSetIfEmpty removes key once its sorted set has no members left.
+func (db *Database) deleteZSetIfEmpty(key string, zset *sortedSet) {
+	if zset.len() == 0 {
+		db.deleteKey(key)
+	}
+}
+
+func parseScoreBound(s string) (value float64, exclusive bool, ok bool) {
+	if strings.HasPrefix(s, "(") {
+		exclusive = true
+		s = s[1:]
+	}
+	value, err := parseFloat(s)
+	return value, exclusive, err == nil
+}
+
+func parseScoreRange(min, max string) (scoreRange, bool) {
+	var r scoreRange
+	var okMin, okMax bool
+	r.min, r.minExclusive, okMin = parseScoreBound(min)
+	r.max, r.maxExclusive, okMax = parseScoreBound(max)
+	return r, okMin && okMax
+}
+
+func parseLexBound(s string) (lexBound, bool) {
+	switch {
+	case s == "-":
+		return lexBound{inf: -1}, true
+	case s == "+":
+		return lexBound{inf: 1}, true
+	case strings.HasPrefix(s, "["):
+		return lexBound{value: s[1:]}, true
+	case strings.HasPrefix(s, "("):
+		return lexBound{value: s[1:], exclusive: true}, true
+	}
+	return lexBound{}, false
+}
+
+func parseLexRange(min, max string) (lexRange, bool) {
+	minBound, okMin := parseLexBound(min)
+	maxBound, okMax := parseLexBound(max)
+	return lexRange{min: minBound, max: maxBound}, okMin && okMax
+}
+
+// zsetEntriesArray renders nodes as an array of members or, with scores,
+// of [member, score] pairs, which RESP2 clients get as one flat array.
+func zsetEntriesArray(nodes []*skiplistNode, withScores bool) resp.Value {
+	result := make([]resp.Value, 0, len(nodes))
+	for _, node := range nodes {
+		if withScores {
+			result = append(result, zsetEntry(node))
+		} else {
+			result = append(result, resp.Value{DataType: resp.TypeBulk, Bulk: node.member})
+		}
+	}
+	if withScores {
+		return resp.Value{DataType: resp.TypePairs, Array: result}
+	}
+	return resp.Value{DataType: resp.TypeArray, Array: result}
+}
+
+// zsetEntry renders node as a [member, score] array.
+func zsetEntry(node *skiplistNode) resp.Value {
+	return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{
+		{DataType: resp.TypeBulk, Bulk: node.member},
+		{DataType: resp.TypeDouble, Double: node.score},
+	}}
+}
+
+type zaddFlags struct {
+	nx, xx, gt, lt, ch, incr bool
+}
+
+func handleZAdd(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	var flags zaddFlags
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "NX":
+			flags.nx = true
+		case "XX":
+			flags.xx = true
+		case "GT":
+			flags.gt = true
+		case "LT":
+			flags.lt = true
+		case "CH":
+			flags.ch = true
+		case "INCR":
+			flags.incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+	if flags.nx && flags.xx {
+		return resp.Value{DataType: resp.TypeError, Err: errZAddXXAndNX}
+	}
+	if (flags.gt && flags.nx) || (flags.lt && flags.nx) || (flags.gt && flags.lt) {
+		return resp.Value{DataType: resp.TypeError, Err: errZAddGTLTNX}
+	}
+	if flags.incr && len(pairs) > 2 {
+		return resp.Value{DataType: resp.TypeError, Err: errZAddIncrPair}
+	}
+
+	// Validate every score before touching the keyspace
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		score, err := parseFloat(pairs[j*2].Bulk)
+		if err != nil {
+			return resp.Value{DataType: resp.TypeError, Err: errNotFloat}
+		}
+		scores[j] = score
+	}
+
+	zset, wrongType := db.loadZSet(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	created := zset == nil
+	if created {
+		if flags.xx {
+			if flags.incr {
+				return resp.Value{DataType: resp.TypeNull, IsNull: true}
+			}
+			return resp.Value{DataType: resp.TypeInteger, Num: 0}
+		}
+		zset = newSortedSet()
+	}
+
+	added, updated := 0, 0
+	var incrResult *float64
+	for j, score := range scores {
+		member := pairs[j*2+1].Bulk
+		current, exists := zset.score(member)
+
+		if (exists && flags.nx) || (!exists && flags.xx) {
+			continue
+		}
+		if flags.incr {
+			score += current
+			if math.IsNaN(score) {
+				return resp.Value{DataType: resp.TypeError, Err: errScoreNaN}
+			}
+		}
+		if exists && ((flags.gt && score <= current) || (flags.lt && score >= current)) {
+			continue
+		}
+
+		newScore := score
+		incrResult = &newScore
+		if !exists {
+			added++
+		} else if current != score {
+			updated++
+		}
+		zset.add(member, score)
+	}
+	if created && zset.len() > 0 {
+		db.storeRecord(key, Record{Type: TypeZSet, Value: zset})
+	} else if added+updated > 0 {
+		signalModified()
+	}
+
+	if flags.incr {
+		if incrResult == nil {
+			return resp.Value{DataType: resp.TypeNull, IsNull: true}
+		}
+		return resp.Value{DataType: resp.TypeDouble, Double: *incrResult}
+	}
+	if flags.ch {
+		return resp.Value{DataType: resp.TypeInteger, Num: int64(added + updated)}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(added)}
+}
+
+func handleZIncrBy(db *Database, args []resp.Value) resp.Value {
+	return handleZAdd(db, []resp.Value{args[0], {DataType: resp.TypeBulk, Bulk: "INCR"}, args[1], args[2]})
+}
+
+func handleZRem(db *Database, args []resp.Value) resp.Value {
+	key := args[0].Bulk
+	zset, wrongType := db.loadZSet(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if zset == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+
+	removed := 0
+	for _, arg := range args[1:] {
+		if zset.remove(arg.Bulk) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		signalModified()
+	}
+	db.deleteZSetIfEmpty(key, zset)
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(removed)}
+}
+
+func handleZScore(db *Database, args []resp.Value) resp.Value {
+	zset, wrongType := db.loadZSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if zset == nil {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+
+	score, ok := zset.score(args[1].Bulk)
+	if !ok {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+	return resp.Value{DataType: resp.TypeDouble, Double: score}
+}
+
+func handleZCard(db *Database, args []resp.Value) resp.Value {
+	zset, wrongType := db.loadZSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if zset == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(zset.len())}
+}
+
+func handleZRank(db *Database, args []resp.Value) resp.Value {
+	return zrankCommand(db, "ZRANK", args, false)
+}
+
+func handleZRevRank(db *Database, args []resp.Value) resp.Value {
+	return zrankCommand(db, "ZREVRANK", args, true)
+}
+
+func zrankCommand(db *Database, name string, args []resp.Value, reverse bool) resp.Value {
+	if len(args) > 3 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError(name)}
+	}
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2].Bulk) != "WITHSCORE" {
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+		withScore = true
+	}
+
+	zset, wrongType := db.loadZSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if zset == nil {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+
+	member := args[1].Bulk
+	rank, ok := zset.rank(member, reverse)
+	if !ok {
+		return resp.Value{DataType: resp.TypeNull, IsNull: true}
+	}
+	if !withScore {
+		return resp.Value{DataType: resp.TypeInteger, Num: int64(rank)}
+	}
+	score, _ := zset.score(member)
+	return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{
+		{DataType: resp.TypeInteger, Num: int64(rank)},
+		{DataType: resp.TypeDouble, Double: score},
+	}}
+}
+
+func handleZCount(db *Database, args []resp.Value) resp.Value {
+	r, ok := parseScoreRange(args[1].Bulk, args[2].Bulk)
+	if !ok {
+		return resp.Value{DataType: resp.TypeError, Err: errMinMaxNotFloat}
+	}
+
+	zset, wrongType := db.loadZSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if zset == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+
+	first := zset.zsl.firstInScoreRange(r)
+	if first == nil {
+		return resp.Value{DataType: resp.TypeInteger, Num: 0}
+	}
+	last := zset.zsl.lastInScoreRange(r)
+	count := zset.zsl.rank(last.score, last.member) - zset.zsl.rank(first.score, first.member) + 1
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(count)}
+}
+
+type zrangeKind int
+
+const (
+	zrangeByIndex zrangeKind = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+// zrangeSpec holds a parsed ZRANGE/ZRANGESTORE request.
+type zrangeSpec struct {
+	kind       zrangeKind
+	reverse    bool
+	withScores bool
+	hasLimit   bool
+	offset     int
+	count      int
+
+	start, stop int
+	scores      scoreRange
+	lex         lexRange
+}
+
+// parseZRangeSpec parses the "min max [BYSCORE|BYLEX] [REV] [LIMIT offset
+// count] [WITHSCORES]" tail shared by ZRANGE and ZRANGESTORE. It returns an
+// error message on failure.
+func parseZRangeSpec(args []resp.Value, allowWithScores bool) (zrangeSpec, string) {
+	spec := zrangeSpec{count: -1}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "BYSCORE":
+			spec.kind = zrangeByScore
+		case "BYLEX":
+			spec.kind = zrangeByLex
+		case "REV":
+			spec.reverse = true
+		case "WITHSCORES":
+			if !allowWithScores {
+				return spec, errSyntax
+			}
+			spec.withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return spec, errSyntax
+			}
+			offset, err := strconv.Atoi(args[i+1].Bulk)
+			if err != nil {
+				return spec, errNotInteger
+			}
+			count, err := strconv.Atoi(args[i+2].Bulk)
+			if err != nil {
+				return spec, errNotInteger
+			}
+			spec.hasLimit = true
+			spec.offset, spec.count = offset, count
+			i += 2
+		default:
+			return spec, errSyntax
+		}
+	}
+
+	if spec.hasLimit && spec.kind == zrangeByIndex {
+		return spec, errLimitNeedsRange
+	}
+	if spec.withScores && spec.kind == zrangeByLex {
+		return spec, errWithScoresBYLEX
+	}
+
+	// With REV the bounds are given as "max min"
+	min, max := args[0].Bulk, args[1].Bulk
+	if spec.reverse && spec.kind != zrangeByIndex {
+		min, max = max, min
+	}
+
+	var ok bool
+	switch spec.kind {
+	case zrangeByIndex:
+		var err error
+		if spec.start, err = strconv.Atoi(min); err != nil {
+			return spec, errNotInteger
+		}
+		if spec.stop, err = strconv.Atoi(max); err != nil {
+			return spec, errNotInteger
+		}
+	case zrangeByScore:
+		if spec.scores, ok = parseScoreRange(min, max); !ok {
+			return spec, errMinMaxNotFloat
+		}
+	case zrangeByLex:
+		if spec.lex, ok = parseLexRange(min, max); !ok {
+			return spec, errMinMaxNotLex
+		}
+	}
+	return spec, ""
+}
+
+// rangeNodes returns the nodes selected by spec in reply order.
+func (zs *sortedSet) rangeNodes(spec zrangeSpec) []*skiplistNode {
+	if spec.kind == zrangeByIndex {
+		return zs.rangeByIndex(spec.start, spec.stop, spec.reverse)
+	}
+	if spec.offset < 0 {
+		return nil
+	}
+
+	zsl := zs.zsl
+	var first *skiplistNode
+	var inRange func(*skiplistNode) bool
+	switch spec.kind {
+	case zrangeByScore:
+		inRange = func(n *skiplistNode) bool { return spec.scores.gteMin(n.score) && spec.scores.lteMax(n.score) }
+		if spec.reverse {
+			first = zsl.lastInScoreRange(spec.scores)
+		} else {
+			first = zsl.firstInScoreRange(spec.scores)
+		}
+	case zrangeByLex:
+		inRange = func(n *skiplistNode) bool { return spec.lex.gteMin(n.member) && spec.lex.lteMax(n.member) }
+		if spec.reverse {
+			first = zsl.lastInLexRange(spec.lex)
+		} else {
+			first = zsl.firstInLexRange(spec.lex)
+		}
+	}
+	if first == nil {
+		return nil
+	}
+
+	// Jump over the offset using ranks instead of walking the list
+	if spec.offset > 0 {
+		rank := zsl.rank(first.score, first.member)
+		if spec.reverse {
+			rank -= spec.offset
+		} else {
+			rank += spec.offset
+		}
+		first = zsl.byRank(rank)
+	}
+
+	var nodes []*skiplistNode
+	for node := first; node != nil && inRange(node); {
+		if spec.count >= 0 && len(nodes) == spec.count {
+			break
+		}
+		nodes = append(nodes, node)
+		if spec.reverse {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+	}
+	return nodes
+}
+
+func (zs *sortedSet) rangeByIndex(start, stop int, reverse bool) []*skiplistNode {
+	length := zs.len()
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= length {
+		return nil
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+
+	nodes := make([]*skiplistNode, 0, stop-start+1)
+	if reverse {
+		for node := zs.zsl.byRank(length - start); len(nodes) < stop-start+1; node = node.backward {
+			nodes = append(nodes, node)
+		}
+	} else {
+		for node := zs.zsl.byRank(start + 1); len(nodes) < stop-start+1; node = node.level[0].forward {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func handleZRange(db *Database, args []resp.Value) resp.Value {
+	spec, errMsg := parseZRangeSpec(args[1:], true)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+
+	zset, wrongType := db.loadZSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if zset == nil {
+		return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{}}
+	}
+	return zsetEntriesArray(zset.rangeNodes(spec), spec.withScores)
+}
+
+func handleZRangeStore(db *Database, args []resp.Value) resp.Value {
+	spec, errMsg := parseZRangeSpec(args[2:], false)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+
+	src, wrongType := db.loadZSet(args[1].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	result := newSortedSet()
+	if src != nil {
+		for _, node := range src.rangeNodes(spec) {
+			result.add(node.member, node.score)
+		}
+	}
+	db.storeZSet(args[0].Bulk, result)
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(result.len())}
+}
+
+func handleZPopMin(db *Database, args []resp.Value) resp.Value {
+	return zpopCommand(db, "ZPOPMIN", args, false)
+}
+
+func handleZPopMax(db *Database, args []resp.Value) resp.Value {
+	return zpopCommand(db, "ZPOPMAX", args, true)
+}
+
+func zpopCommand(db *Database, name string, args []resp.Value, max bool) resp.Value {
+	if len(args) > 2 {
+		return resp.Value{DataType: resp.TypeError, Err: wrongArityError(name)}
+	}
+
+	key := args[0].Bulk
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(args[1].Bulk)
+		if err != nil || count < 0 {
+			return resp.Value{DataType: resp.TypeError, Err: errNotPositive}
+		}
+	}
+
+	zset, wrongType := db.loadZSet(key)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+	if zset == nil {
+		return resp.Value{DataType: resp.TypeArray, Array: []resp.Value{}}
+	}
+
+	var nodes []*skiplistNode
+	for len(nodes) < count && zset.len() > 0 {
+		node := zset.zsl.header.level[0].forward
+		if max {
+			node = zset.zsl.tail
+		}
+		nodes = append(nodes, node)
+		zset.remove(node.member)
+	}
+	if len(nodes) > 0 {
+		signalModified()
+	}
+	db.deleteZSetIfEmpty(key, zset)
+	// Without a count the reply is a single flat pair, as in Redis
+	if len(args) == 1 && len(nodes) == 1 {
+		return zsetEntry(nodes[0])
+	}
+	return zsetEntriesArray(nodes, true)
+}
+
+type zsetAggregate int
+
+const (
+	aggregateSum zsetAggregate = iota
+	aggregateMin
+	aggregateMax
+)
+
+func (agg zsetAggregate) apply(a, b float64) float64 {
+	switch agg {
+	case aggregateMin:
+		return math.Min(a, b)
+	case aggregateMax:
+		return math.Max(a, b)
+	}
+	// inf + -inf is NaN; Redis treats that sum as zero
+	sum := a + b
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
+
+// loadZSetSource returns the member/score pairs of a ZUNIONSTORE or
+// ZINTERSTORE input, treating plain sets as if every score were 1 and a
+// missing key as empty.
+func (db *Database) loadZSetSource(key string) (*dict[float64], bool) {
+	record, ok := db.lookupKey(key)
+	if !ok {
+		return newDict[float64](0), false
+	}
+	switch record.Type {
+	case TypeZSet:
+		return record.Value.(*sortedSet).dict, false
+	case TypeSet:
+		set := record.Value.(*stringSet)
+		scores := newDict[float64](set.len())
+		for _, member := range set.keys {
+			scores.set(member, 1)
+		}
+		return scores, false
+	}
+	return nil, true
+}
+
+func handleZUnionStore(db *Database, args []resp.Value) resp.Value {
+	return zsetOperationStore(db, args, false)
+}
+
+func handleZInterStore(db *Database, args []resp.Value) resp.Value {
+	return zsetOperationStore(db, args, true)
+}
+
+func zsetOperationStore(db *Database, args []resp.Value, intersect bool) resp.Value {
+	destination := args[0].Bulk
+	numKeys, err := strconv.Atoi(args[1].Bulk)
+	if err != nil {
+		return resp.Value{DataType: resp.TypeError, Err: errNotInteger}
+	}
+	if numKeys < 1 {
+		return resp.Value{DataType: resp.TypeError, Err: errZStoreNeedsOneKey}
+	}
+	if numKeys > len(args)-2 {
+		return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+	}
+
+	keys := args[2 : 2+numKeys]
+	weights := make([]float64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := aggregateSum
+
+	rest := args[2+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		switch strings.ToUpper(rest[i].Bulk) {
+		case "WEIGHTS":
+			if i+numKeys >= len(rest) {
+				return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+			}
+			for j := range weights {
+				weight, err := parseFloat(rest[i+1+j].Bulk)
+				if err != nil {
+					return resp.Value{DataType: resp.TypeError, Err: errWeightNotFloat}
+				}
+				weights[j] = weight
+			}
+			i += numKeys
+		case "AGGREGATE":
+			if i+1 >= len(rest) {
+				return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+			}
+			switch strings.ToUpper(rest[i+1].Bulk) {
+			case "SUM":
+				aggregate = aggregateSum
+			case "MIN":
+				aggregate = aggregateMin
+			case "MAX":
+				aggregate = aggregateMax
+			default:
+				return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+			}
+			i++
+		default:
+			return resp.Value{DataType: resp.TypeError, Err: errSyntax}
+		}
+	}
+
+	sources := make([]*dict[float64], numKeys)
+	for i, key := range keys {
+		source, wrongType := db.loadZSetSource(key.Bulk)
+		if wrongType {
+			return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+		}
+		sources[i] = source
+	}
+
+	weighted := func(score, weight float64) float64 {
+		// 0 * inf is NaN; treat it as zero like Redis does
+		if v := score * weight; !math.IsNaN(v) {
+			return v
+		}
+		return 0
+	}
+
+	result := newSortedSet()
+	if intersect {
+		smallest := 0
+		for i, source := range sources {
+			if source.len() < sources[smallest].len() {
+				smallest = i
+			}
+		}
+	members:
+		for _, member := range sources[smallest].keys {
+			var score float64
+			for i, source := range sources {
+				s, ok := source.get(member)
+				if !ok {
+					continue members
+				}
+				if i == 0 {
+					score = weighted(s, weights[i])
+				} else {
+					score = aggregate.apply(score, weighted(s, weights[i]))
+				}
+			}
+			result.add(member, score)
+		}
+	} else {
+		scores := make(map[string]float64)
+		for i, source := range sources {
+			for j, member := range source.keys {
+				s := source.values[j]
+				if current, ok := scores[member]; ok {
+					scores[member] = aggregate.apply(current, weighted(s, weights[i]))
+				} else {
+					scores[member] = weighted(s, weights[i])
+				}
+			}
+		}
+		for member, score := range scores {
+			result.add(member, score)
+		}
+	}
+
+	db.storeZSet(destination, result)
+	return resp.Value{DataType: resp.TypeInteger, Num: int64(result.len())}
+}
+
+func handleZScan(db *Database, args []resp.Value) resp.Value {
+	opts, errMsg := parseScanArgs(args[1:], false, false)
+	if errMsg != "" {
+		return resp.Value{DataType: resp.TypeError, Err: errMsg}
+	}
+	zset, wrongType := db.loadZSet(args[0].Bulk)
+	if wrongType {
+		return resp.Value{DataType: resp.TypeError, Err: errWrongType}
+	}
+
+	// A missing key scans like an empty sorted set
+	var scores *dict[float64]
+	if zset != nil {
+		scores = zset.dict
+	}
+	return scanDict(scores, opts, resp.FormatDouble)
+}
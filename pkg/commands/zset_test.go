@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"go-redis/pkg/resp"
+	"slices"
+	"testing"
+)
+
+func TestZAddFlags(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "ZADD", bulkValues("zset", "1", "a", "2", "b"))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected int64
+		score    float64
+	}{
+		{"Add", []string{"zset", "3", "c"}, 1, 1},
+		{"Update Does Not Count", []string{"zset", "10", "a"}, 0, 10},
+		{"CH Counts Updates", []string{"zset", "CH", "11", "a", "4", "d"}, 2, 11},
+		{"NX Skips Existing", []string{"zset", "NX", "0", "a"}, 0, 11},
+		{"NX Adds Missing", []string{"zset", "NX", "5", "e"}, 1, 11},
+		{"XX Skips Missing", []string{"zset", "XX", "CH", "1", "a", "6", "f"}, 1, 1},
+		{"GT Skips Lower", []string{"zset", "GT", "CH", "0", "a"}, 0, 1},
+		{"GT Takes Higher", []string{"zset", "GT", "CH", "7", "a"}, 1, 7},
+		{"LT Skips Higher", []string{"zset", "LT", "CH", "8", "a"}, 0, 7},
+		{"LT Takes Lower", []string{"zset", "LT", "CH", "-1", "a"}, 1, -1},
+	}
+	for _, tc := range testCases {
+		if reply, _ := Execute(client, "ZADD", bulkValues(tc.args...)); reply.Num != tc.expected {
+			t.Errorf("%s: expected %d, got %+v", tc.name, tc.expected, reply)
+		}
+		if reply, _ := Execute(client, "ZSCORE", bulkValues("zset", "a")); reply.Double != tc.score {
+			t.Errorf("%s: expected a to score %g, got %+v", tc.name, tc.score, reply)
+		}
+	}
+	if reply, _ := Execute(client, "ZSCORE", bulkValues("zset", "f")); !reply.IsNull {
+		t.Fatalf("ZADD XX added a missing member: %+v", reply)
+	}
+
+	if reply, _ := Execute(client, "ZADD", bulkValues("missing", "XX", "1", "a")); reply.Num != 0 {
+		t.Fatalf("expected 0, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "EXISTS", bulkValues("missing")); reply.Num != 0 {
+		t.Fatal("ZADD XX created a missing key")
+	}
+}
+
+func TestZAddIncr(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+
+	if reply, _ := Execute(client, "ZADD", bulkValues("zset", "INCR", "2.5", "a")); reply.DataType != resp.TypeDouble || reply.Double != 2.5 {
+		t.Fatalf("expected 2.5, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "ZADD", bulkValues("zset", "INCR", "-1", "a")); reply.Double != 1.5 {
+		t.Fatalf("expected 1.5, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "ZADD", bulkValues("zset", "NX", "INCR", "1", "a")); !reply.IsNull {
+		t.Fatalf("expected a null reply for NX on an existing member, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "ZADD", bulkValues("zset", "XX", "INCR", "1", "b")); !reply.IsNull {
+		t.Fatalf("expected a null reply for XX on a missing member, got %+v", reply)
+	}
+	if reply, _ := Execute(client, "ZADD", bulkValues("zset", "GT", "INCR", "-1", "a")); !reply.IsNull {
+		t.Fatalf("expected a null reply when GT rejects the increment, got %+v", reply)
+	}
+	Execute(client, "ZADD", bulkValues("zset", "INCR", "inf", "a"))
+	if reply, _ := Execute(client, "ZADD", bulkValues("zset", "INCR", "-inf", "a")); reply.Err != errScoreNaN {
+		t.Fatalf("expected %q, got %+v", errScoreNaN, reply)
+	}
+
+	testCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{"NX And XX", []string{"zset", "NX", "XX", "1", "a"}, errZAddXXAndNX},
+		{"GT And NX", []string{"zset", "GT", "NX", "1", "a"}, errZAddGTLTNX},
+		{"GT And LT", []string{"zset", "GT", "LT", "1", "a"}, errZAddGTLTNX},
+		{"INCR With Two Pairs", []string{"zset", "INCR", "1", "a", "2", "b"}, errZAddIncrPair},
+		{"Not A Float", []string{"zset", "1", "a", "x", "b"}, errNotFloat},
+		{"Odd Pairs", []string{"zset", "1", "a", "2"}, errSyntax},
+	}
+	for _, tc := range testCases {
+		if reply, _ := Execute(client, "ZADD", bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+			t.Errorf("%s: expected %q, got %+v", tc.name, tc.expectedErr, reply)
+		}
+	}
+	if reply, _ := Execute(client, "ZSCORE", bulkValues("zset", "b")); !reply.IsNull {
+		t.Fatalf("a rejected ZADD added members: %+v", reply)
+	}
+}
+
+func TestZRange(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "ZADD", bulkValues("zset", "1", "a", "2", "b", "3", "c", "4", "d", "5", "e"))
+	Execute(client, "ZADD", bulkValues("lex", "0", "a", "0", "b", "0", "c", "0", "d", "0", "e"))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"By Index", []string{"zset", "0", "1"}, []string{"a", "b"}},
+		{"Negative Indexes", []string{"zset", "-2", "-1"}, []string{"d", "e"}},
+		{"Out Of Range", []string{"zset", "10", "20"}, []string{}},
+		{"Rev", []string{"zset", "0", "1", "REV"}, []string{"e", "d"}},
+		{"WithScores", []string{"zset", "0", "0", "WITHSCORES"}, []string{"a", "1"}},
+		{"By Score", []string{"zset", "2", "4", "BYSCORE"}, []string{"b", "c", "d"}},
+		{"By Exclusive Score", []string{"zset", "(2", "+inf", "BYSCORE"}, []string{"c", "d", "e"}},
+		{"By Score Rev", []string{"zset", "4", "(2", "BYSCORE", "REV"}, []string{"d", "c"}},
+		{"By Score Limit", []string{"zset", "-inf", "+inf", "BYSCORE", "LIMIT", "1", "2"}, []string{"b", "c"}},
+		{"By Score Rev Limit", []string{"zset", "+inf", "-inf", "BYSCORE", "REV", "LIMIT", "1", "2"}, []string{"d", "c"}},
+		{"Limit Past The End", []string{"zset", "-inf", "+inf", "BYSCORE", "LIMIT", "10", "2"}, []string{}},
+		{"Negative Limit Count", []string{"zset", "-inf", "+inf", "BYSCORE", "LIMIT", "3", "-1"}, []string{"d", "e"}},
+		{"By Lex", []string{"lex", "[b", "(d", "BYLEX"}, []string{"b", "c"}},
+		{"By Lex Unbounded", []string{"lex", "-", "+", "BYLEX", "LIMIT", "3", "5"}, []string{"d", "e"}},
+		{"By Lex Rev", []string{"lex", "+", "(c", "BYLEX", "REV"}, []string{"e", "d"}},
+		{"Missing Key", []string{"missing", "0", "-1"}, []string{}},
+	}
+	for _, tc := range testCases {
+		reply, _ := Execute(client, "ZRANGE", bulkValues(tc.args...))
+		reply = reply.ForProtocol(2)
+		if got := flatten(reply); reply.DataType != resp.TypeArray || !slices.Equal(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %+v", tc.name, tc.expected, reply)
+		}
+	}
+
+	errorCases := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{"Limit By Index", []string{"zset", "0", "1", "LIMIT", "0", "1"}, errLimitNeedsRange},
+		{"WithScores By Lex", []string{"lex", "-", "+", "BYLEX", "WITHSCORES"}, errWithScoresBYLEX},
+		{"Bad Score", []string{"zset", "x", "1", "BYSCORE"}, errMinMaxNotFloat},
+		{"Bad Lex", []string{"lex", "b", "+", "BYLEX"}, errMinMaxNotLex},
+		{"Bad Index", []string{"zset", "0", "x"}, errNotInteger},
+		{"Short Limit", []string{"zset", "0", "1", "BYSCORE", "LIMIT", "0"}, errSyntax},
+		{"Unknown Option", []string{"zset", "0", "1", "ALL"}, errSyntax},
+	}
+	for _, tc := range errorCases {
+		if reply, _ := Execute(client, "ZRANGE", bulkValues(tc.args...)); reply.Err != tc.expectedErr {
+			t.Errorf("%s: expected %q, got %+v", tc.name, tc.expectedErr, reply)
+		}
+	}
+
+	Execute(client, "SET", bulkValues("str", "v"))
+	if reply, _ := Execute(client, "ZRANGE", bulkValues("str", "0", "-1")); reply.Err != errWrongType {
+		t.Fatalf("expected %q, got %+v", errWrongType, reply)
+	}
+}
+
+func TestZSetScoresForRESP3(t *testing.T) {
+	flushKeyspace()
+	client := NewClient()
+	defer client.Close()
+	Execute(client, "ZADD", bulkValues("zset", "1", "a", "2.5", "b", "3", "c", "4", "d"))
+
+	isEntry := func(v resp.Value, member string, score float64) bool {
+		return v.DataType == resp.TypeArray && len(v.Array) == 2 && v.Array[0].Bulk == member &&
+			v.Array[1].DataType == resp.TypeDouble && v.Array[1].Double == score
+	}
+
+	reply, _ := Execute(client, "ZRANGE", bulkValues("zset", "0", "1", "WITHSCORES"))
+	if reply.DataType != resp.TypePairs || len(reply.Array) != 2 || !isEntry(reply.Array[0], "a", 1) || !isEntry(reply.Array[1], "b", 2.5) {
+		t.Fatalf("expected [[a 1] [b 2.5]], got %+v", reply)
+	}
+	if reply, _ := Execute(client, "ZPOPMIN", bulkValues("zset")); !isEntry(reply, "a", 1) {
+		t.Fatalf("expected a flat [a 1] without a count, got %+v", reply)
+	}
+	reply, _ = Execute(client, "ZPOPMAX", bulkValues("zset", "1"))
+	if reply.DataType != resp.TypePairs || len(reply.Array) != 1 || !isEntry(reply.Array[0], "d", 4) {
+		t.Fatalf("expected [[d 4]] with a count, got %+v", reply)
+	}
+	reply, _ = Execute(client, "ZPOPMIN", bulkValues("zset", "5"))
+	if got := flatten(reply.ForProtocol(2)); !slices.Equal(got, []string{"b", "2.5", "c", "3"}) {
+		t.Fatalf("expected [b 2.5 c 3] for RESP2, got %v", got)
+	}
+	if reply, _ := Execute(client, "ZPOPMIN", bulkValues("zset")); reply.DataType != resp.TypeArray || len(reply.Array) != 0 {
+		t.Fatalf("expected an empty array for a missing key, got %+v", reply)
+	}
+}
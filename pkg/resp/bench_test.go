@@ -0,0 +1,133 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// loopReader reads the same data over and over, like a connection that
+// keeps sending the same request.
+type loopReader struct {
+	data []byte
+	off  int
+}
+
+func (r *loopReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data[r.off:])
+	r.off = (r.off + n) % len(r.data)
+	return n, nil
+}
+
+func commandArgs(args ...string) Value {
+	return Value{DataType: TypeArray, Array: bulkArgs(args...)}
+}
+
+func bulkArgs(args ...string) []Value {
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		values[i] = Value{DataType: TypeBulk, Bulk: arg}
+	}
+	return values
+}
+
+var benchmarkCommands = []struct {
+	name    string
+	command Value
+}{
+	{"GET", commandArgs("GET", "key:000123")},
+	{"SET", commandArgs("SET", "key:000123", strings.Repeat("v", 64))},
+	{"HSET 10 fields", commandArgs(append([]string{"HSET", "hash"}, strings.Split(strings.Repeat("field,value,", 10), ",")[:20]...)...)},
+	{"SET 64KB", commandArgs("SET", "key", strings.Repeat("v", 64*1024))},
+}
+
+func BenchmarkReadCommand(b *testing.B) {
+	for _, bc := range benchmarkCommands {
+		request := bc.command.Serialize()
+
+		b.Run(bc.name+"/legacy", func(b *testing.B) {
+			d := &legacyDeserializer{bufio.NewReader(&loopReader{data: request})}
+			b.SetBytes(int64(len(request)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := d.readCommand(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(bc.name+"/current", func(b *testing.B) {
+			d := NewDeserializer(&loopReader{data: request})
+			var v Value
+			b.SetBytes(int64(len(request)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := d.ReadCommandInto(&v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+var benchmarkReplies = []struct {
+	name  string
+	reply Value
+}{
+	{"OK", Value{DataType: TypeString, Str: "OK"}},
+	{"Integer", Value{DataType: TypeInteger, Num: 1234567}},
+	{"Bulk", Value{DataType: TypeBulk, Bulk: strings.Repeat("v", 64)}},
+	{"Array of 100", commandArgs(strings.Split(strings.Repeat("element,", 100), ",")[:100]...)},
+	{"Bulk 64KB", Value{DataType: TypeBulk, Bulk: strings.Repeat("v", 64*1024)}},
+}
+
+func BenchmarkWrite(b *testing.B) {
+	for _, bc := range benchmarkReplies {
+		size := int64(len(bc.reply.Serialize()))
+
+		b.Run(bc.name+"/legacy", func(b *testing.B) {
+			w := bufio.NewWriter(io.Discard)
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := legacyWrite(w, bc.reply); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(bc.name+"/current", func(b *testing.B) {
+			s := NewSerializer(io.Discard)
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := s.Write(bc.reply); err != nil {
+					b.Fatal(err)
+				}
+				if err := s.Flush(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkForProtocol(b *testing.B) {
+	replies := []struct {
+		name  string
+		reply Value
+	}{
+		{"Array of 100", commandArgs(strings.Split(strings.Repeat("element,", 100), ",")[:100]...)},
+		{"Map of 50", Value{DataType: TypeMap, Array: bulkArgs(strings.Split(strings.Repeat("field,", 100), ",")[:100]...)}},
+	}
+	for _, bc := range replies {
+		for _, version := range []int{2, 3} {
+			b.Run(bc.name+"/RESP"+strconv.Itoa(version), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					bc.reply.ForProtocol(version)
+				}
+			})
+		}
+	}
+}
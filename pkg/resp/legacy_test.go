@@ -0,0 +1,154 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// This file keeps the codec as it was before it was made allocation free,
+// so that the benchmarks can compare the two. Only the paths the
+// benchmarks exercise are kept: reading multibulk requests and serializing
+// replies.
+
+type legacyDeserializer struct {
+	reader *bufio.Reader
+}
+
+// readLine copies every line out of the read buffer.
+func (d *legacyDeserializer) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := d.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > MaxInlineLen {
+			return nil, &ProtocolError{Reason: "line too long"}
+		}
+		if err != bufio.ErrBufferFull {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errNoCRLF
+	}
+	return line[:len(line)-2], nil
+}
+
+// readCommand reads the arguments into a slice of their own, then converts
+// them to a new array of values.
+func (d *legacyDeserializer) readCommand() (Value, error) {
+	if _, err := d.reader.ReadByte(); err != nil {
+		return Value{}, err
+	}
+	count, err := d.readLength()
+	if err != nil {
+		return Value{}, err
+	}
+
+	args := make([]string, 0, min(count, maxPreallocLen))
+	for i := 0; i < count; i++ {
+		prefix, err := d.reader.ReadByte()
+		if err != nil {
+			return Value{}, noEOF(err)
+		}
+		if prefix != BULK {
+			return Value{}, &ProtocolError{Reason: fmt.Sprintf("expected '$', got '%c'", prefix)}
+		}
+		length, err := d.readLength()
+		if err != nil {
+			return Value{}, err
+		}
+		arg, err := d.readBulkPayload(length)
+		if err != nil {
+			return Value{}, err
+		}
+		args = append(args, arg)
+	}
+
+	v := Value{DataType: TypeArray, Array: make([]Value, len(args))}
+	for i, arg := range args {
+		v.Array[i] = Value{DataType: TypeBulk, Bulk: arg}
+	}
+	return v, nil
+}
+
+func (d *legacyDeserializer) readLength() (int, error) {
+	line, err := d.readLine()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(string(line))
+	if err != nil {
+		return 0, &ProtocolError{Reason: "invalid length"}
+	}
+	return n, nil
+}
+
+// readBulkPayload reads into a byte slice, then copies it into a string.
+func (d *legacyDeserializer) readBulkPayload(n int) (string, error) {
+	payload := make([]byte, 0, min(n, bulkChunkLen))
+	for len(payload) < n {
+		read := len(payload)
+		next := min(n, max(2*read, bulkChunkLen))
+		payload = slices.Grow(payload, next-read)[:next]
+		if _, err := io.ReadFull(d.reader, payload[read:]); err != nil {
+			return "", noEOF(err)
+		}
+	}
+
+	var crlf [2]byte
+	if _, err := io.ReadFull(d.reader, crlf[:]); err != nil {
+		return "", noEOF(err)
+	}
+	if crlf != [2]byte{'\r', '\n'} {
+		return "", errNoCRLF
+	}
+	return string(payload), nil
+}
+
+// legacySerialize builds a new slice for every value, nested ones included.
+func legacySerialize(v Value) []byte {
+	var bytes []byte
+	switch v.DataType {
+	case TypeArray:
+		if v.IsNull {
+			return []byte("*-1\r\n")
+		}
+		bytes = append(bytes, ARRAY)
+		bytes = append(bytes, strconv.Itoa(len(v.Array))...)
+		bytes = appendCRLF(bytes)
+		for _, val := range v.Array {
+			bytes = append(bytes, legacySerialize(val)...)
+		}
+	case TypeBulk:
+		bytes = append(bytes, BULK)
+		bytes = append(bytes, strconv.Itoa(len(v.Bulk))...)
+		bytes = appendCRLF(bytes)
+		bytes = append(bytes, v.Bulk...)
+		bytes = appendCRLF(bytes)
+	case TypeString:
+		bytes = append(bytes, STRING)
+		bytes = append(bytes, v.Str...)
+		bytes = appendCRLF(bytes)
+	case TypeInteger:
+		bytes = append(bytes, INTEGER)
+		bytes = append(bytes, strconv.FormatInt(v.Num, 10)...)
+		bytes = appendCRLF(bytes)
+	case TypeNull:
+		return []byte("$-1\r\n")
+	}
+	return bytes
+}
+
+// legacyWrite writes a serialized value through a buffered writer.
+func legacyWrite(w *bufio.Writer, v Value) error {
+	if _, err := w.Write(legacySerialize(v)); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,228 @@
+package resp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ReadCommand reads the next command sent by a client. Besides the RESP
+// arrays of bulk strings sent by client libraries, it accepts inline
+// commands: a line of space-separated arguments, as typed into telnet or
+// nc. Either way the command is returned as a non-empty array of bulk
+// strings. Empty lines and empty arrays are skipped.
+func (d *Deserializer) ReadCommand() (Value, error) {
+	var v Value
+	err := d.ReadCommandInto(&v)
+	return v, err
+}
+
+// ReadCommandInto is ReadCommand reading into v. It reuses the array v
+// holds, so that reading a command allocates nothing but its arguments.
+// Whoever used the previous command v held must be done with its array.
+func (d *Deserializer) ReadCommandInto(v *Value) error {
+	for {
+		prefix, err := d.reader.Peek(1)
+		if err != nil {
+			return err
+		}
+
+		*v = Value{DataType: TypeArray, Array: v.Array[:0]}
+		if prefix[0] == ARRAY {
+			v.Array, err = d.readMultiBulk(v.Array)
+		} else {
+			v.Array, err = d.readInline(v.Array)
+		}
+		if err != nil {
+			return err
+		}
+		if len(v.Array) > 0 {
+			return nil
+		}
+	}
+}
+
+// readMultiBulk reads a request sent as an array of bulk strings, appending
+// its arguments to args.
+func (d *Deserializer) readMultiBulk(args []Value) ([]Value, error) {
+	if _, err := d.reader.ReadByte(); err != nil {
+		return args, err
+	}
+	count, err := d.readRequestLength("too big mbulk count string", "invalid multibulk length")
+	if err != nil || count <= 0 {
+		return args, err
+	}
+	if count > MaxMultiBulkLen {
+		return args, &ProtocolError{Reason: "invalid multibulk length"}
+	}
+
+	// The array grows as arguments arrive rather than trusting the count
+	args = slices.Grow(args, min(count, maxPreallocLen))
+	for i := 0; i < count; i++ {
+		prefix, err := d.reader.ReadByte()
+		if err != nil {
+			return args, noEOF(err)
+		}
+		if prefix != BULK {
+			return args, &ProtocolError{Reason: fmt.Sprintf("expected '$', got '%c'", prefix)}
+		}
+		length, err := d.readRequestLength("too big bulk count string", "invalid bulk length")
+		if err != nil {
+			return args, err
+		}
+		if length < 0 || length > maxBulkLen {
+			return args, &ProtocolError{Reason: "invalid bulk length"}
+		}
+
+		arg, err := d.readBulkPayload(length)
+		if err != nil {
+			return args, err
+		}
+		args = append(args, Value{DataType: TypeBulk, Bulk: arg})
+	}
+	return args, nil
+}
+
+// readRequestLength reads the length line of a multibulk request or of one
+// of its arguments, failing with tooLongReason if the line is too long to
+// hold a length and with invalidReason if it does not hold a number.
+func (d *Deserializer) readRequestLength(tooLongReason, invalidReason string) (int, error) {
+	line, err := d.readLineLF(tooLongReason)
+	if err != nil {
+		return 0, noEOF(err)
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return 0, errNoCRLF
+	}
+	line = line[:len(line)-2]
+
+	sign := 1
+	if len(line) > 0 && line[0] == '-' {
+		sign, line = -1, line[1:]
+	}
+	n, ok := parseLength(line)
+	if !ok || n < 0 {
+		return 0, &ProtocolError{Reason: invalidReason}
+	}
+	return sign * n, nil
+}
+
+// readInline reads a request sent as a line of text, appending its
+// arguments to args.
+func (d *Deserializer) readInline(args []Value) ([]Value, error) {
+	line, err := d.readLineLF("too big inline request")
+	if err != nil {
+		return args, err
+	}
+	inline, err := splitInlineArgs(strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"))
+	if err != nil {
+		return args, err
+	}
+	for _, arg := range inline {
+		args = append(args, Value{DataType: TypeBulk, Bulk: arg})
+	}
+	return args, nil
+}
+
+// splitInlineArgs splits an inline command into its arguments the way
+// Redis does. Arguments are separated by spaces, and quotes may appear
+// anywhere in one: double quotes support the escapes \n, \r, \t, \b, \a,
+// \xHH and a backslash before any other character, single quotes only \'.
+// A closing quote must be followed by a space or the end of the line.
+func splitInlineArgs(line string) ([]string, error) {
+	errUnbalanced := &ProtocolError{Reason: "unbalanced quotes in request"}
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isInlineSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg []byte
+		inDouble, inSingle := false, false
+		for done := false; !done; i++ {
+			if (inDouble || inSingle) && i == len(line) {
+				return nil, errUnbalanced
+			}
+			if i == len(line) {
+				break
+			}
+			c := line[i]
+			switch {
+			case inDouble:
+				if c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]) {
+					arg = append(arg, hexValue(line[i+2])<<4|hexValue(line[i+3]))
+					i += 3
+				} else if c == '\\' && i+1 < len(line) {
+					i++
+					arg = append(arg, unescape(line[i]))
+				} else if c == '"' {
+					if i+1 < len(line) && !isInlineSpace(line[i+1]) {
+						return nil, errUnbalanced
+					}
+					done = true
+				} else {
+					arg = append(arg, c)
+				}
+			case inSingle:
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					arg = append(arg, '\'')
+				} else if c == '\'' {
+					if i+1 < len(line) && !isInlineSpace(line[i+1]) {
+						return nil, errUnbalanced
+					}
+					done = true
+				} else {
+					arg = append(arg, c)
+				}
+			case isInlineSpace(c):
+				done = true
+			case c == '"':
+				inDouble = true
+			case c == '\'':
+				inSingle = true
+			default:
+				arg = append(arg, c)
+			}
+		}
+		args = append(args, string(arg))
+	}
+}
+
+func isInlineSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	}
+	return c
+}
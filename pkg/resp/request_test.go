@@ -0,0 +1,192 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "Array",
+			input:    "*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n",
+			expected: []string{"ECHO", "hi"},
+		},
+		{
+			name:     "Binary Safe Array",
+			input:    "*2\r\n$3\r\nSET\r\n$4\r\na\r\nb\r\n",
+			expected: []string{"SET", "a\r\nb"},
+		},
+		{
+			name:     "Empty Arrays Are Skipped",
+			input:    "*0\r\n*-1\r\n*1\r\n$4\r\nPING\r\n",
+			expected: []string{"PING"},
+		},
+		{
+			name:     "Inline",
+			input:    "SET  key value\r\n",
+			expected: []string{"SET", "key", "value"},
+		},
+		{
+			name:     "Inline Without Carriage Return",
+			input:    "PING\n",
+			expected: []string{"PING"},
+		},
+		{
+			name:     "Empty Lines Are Skipped",
+			input:    "\r\n  \n GET key\r\n",
+			expected: []string{"GET", "key"},
+		},
+		{
+			name:     "Double Quotes",
+			input:    "SET \"a key\" \"tab\\there \\x41\\\"\"\r\n",
+			expected: []string{"SET", "a key", "tab\there A\""},
+		},
+		{
+			name:     "Single Quotes",
+			input:    "SET 'it\\'s' 'no \\n escapes'\r\n",
+			expected: []string{"SET", "it's", "no \\n escapes"},
+		},
+		{
+			name:     "Quotes Inside An Argument",
+			input:    "SET key\"with space\" ''\r\n",
+			expected: []string{"SET", "keywith space", ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deserializer := NewDeserializer(bytes.NewBufferString(tc.input))
+			command, err := deserializer.ReadCommand()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var args []string
+			for _, arg := range command.Array {
+				if arg.DataType != TypeBulk {
+					t.Fatalf("Expected bulk string arguments, but got %+v", arg)
+				}
+				args = append(args, arg.Bulk)
+			}
+			if !reflect.DeepEqual(args, tc.expected) {
+				t.Errorf("Expected %q, but got %q", tc.expected, args)
+			}
+		})
+	}
+}
+
+func TestReadCommandErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		expectedErr string
+		protocol    bool
+	}{
+		{
+			name:        "Unterminated Double Quote",
+			input:       "SET \"key value\r\n",
+			expectedErr: "Protocol error: unbalanced quotes in request",
+			protocol:    true,
+		},
+		{
+			name:        "Text After Closing Quote",
+			input:       "SET 'key'value\r\n",
+			expectedErr: "Protocol error: unbalanced quotes in request",
+			protocol:    true,
+		},
+		{
+			name:        "Invalid Multibulk Length",
+			input:       "*x\r\n",
+			expectedErr: "Protocol error: invalid multibulk length",
+			protocol:    true,
+		},
+		{
+			name:        "Argument Not A Bulk String",
+			input:       "*1\r\n:1\r\n",
+			expectedErr: "Protocol error: expected '$', got ':'",
+			protocol:    true,
+		},
+		{
+			name:        "Invalid Bulk Length",
+			input:       "*1\r\n$-2\r\n",
+			expectedErr: "Protocol error: invalid bulk length",
+			protocol:    true,
+		},
+		{
+			name:        "Too Many Arguments",
+			input:       fmt.Sprintf("*%d\r\n", MaxMultiBulkLen+1),
+			expectedErr: "Protocol error: invalid multibulk length",
+			protocol:    true,
+		},
+		{
+			name:        "Argument Over The Bulk Limit",
+			input:       fmt.Sprintf("*1\r\n$%d\r\n", DefaultMaxBulkLen+1),
+			expectedErr: "Protocol error: invalid bulk length",
+			protocol:    true,
+		},
+		{
+			name:        "Argument Without CRLF",
+			input:       "*1\r\n$4\r\nPINGPONG\r\n",
+			expectedErr: "Protocol error: expected CRLF",
+			protocol:    true,
+		},
+		{
+			name:        "Inline Request Too Long",
+			input:       strings.Repeat("a", MaxInlineLen+1),
+			expectedErr: "Protocol error: too big inline request",
+			protocol:    true,
+		},
+		{
+			name:        "Length Line Too Long",
+			input:       "*1\r\n$" + strings.Repeat("1", MaxInlineLen+1),
+			expectedErr: "Protocol error: too big bulk count string",
+			protocol:    true,
+		},
+		{
+			name:        "Truncated Argument",
+			input:       "*1\r\n$4\r\nPI",
+			expectedErr: "unexpected EOF",
+		},
+		{
+			name:        "Incomplete Line",
+			input:       "PING",
+			expectedErr: "EOF",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deserializer := NewDeserializer(bytes.NewBufferString(tc.input))
+			_, err := deserializer.ReadCommand()
+			if err == nil {
+				t.Fatal("Expected an error, but got nil")
+			}
+			if err.Error() != tc.expectedErr {
+				t.Errorf("Expected error '%s', but got '%s'", tc.expectedErr, err.Error())
+			}
+			var protocolErr *ProtocolError
+			if errors.As(err, &protocolErr) != tc.protocol {
+				t.Errorf("Expected a protocol error: %v, but got %T", tc.protocol, err)
+			}
+		})
+	}
+}
+
+func TestReadCommandBulkLimit(t *testing.T) {
+	SetMaxBulkLen(8)
+	defer SetMaxBulkLen(DefaultMaxBulkLen)
+
+	deserializer := NewDeserializer(bytes.NewBufferString("*2\r\n$3\r\nGET\r\n$9\r\n123456789\r\n"))
+	_, err := deserializer.ReadCommand()
+	if err == nil || err.Error() != "Protocol error: invalid bulk length" {
+		t.Fatalf("Expected the argument to be refused, but got %v", err)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"errors"
 	"io"
-	"log"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -14,6 +16,17 @@ const (
 	INTEGER = ':'
 	BULK    = '$'
 	ARRAY   = '*'
+
+	// RESP3 only
+	MAP       = '%'
+	SET       = '~'
+	DOUBLE    = ','
+	BOOLEAN   = '#'
+	BIGNUMBER = '('
+	VERBATIM  = '='
+	NULL      = '_'
+	ATTRIBUTE = '|'
+	PUSH      = '>'
 )
 
 type DataType int
@@ -24,24 +37,86 @@ const (
 	TypeInteger
 	TypeBulk
 	TypeArray
-	TypeNull
+	TypeNull // the RESP2 null bulk string
+
+	// RESP3 only. ForProtocol converts them for RESP2 connections.
+	TypeMap
+	TypeSet
+	TypeDouble
+	TypeBoolean
+	TypeBigNumber
+	TypeVerbatim
+	TypeNil // the RESP3 null
+	TypePush
+	TypePairs // an array of two-element arrays, flattened into one array for RESP2
 )
 
 type Value struct {
-	DataType DataType
-	Str      string  // simple string value
-	Num      int     // integer value
-	Bulk     string  // bulk string value
-	Err      string  // simple error string value
-	Array    []Value // array value
-	IsNull   bool
+	DataType   DataType
+	Str        string  // simple string value, or the digits of a big number
+	Num        int64   // integer value
+	Bulk       string  // bulk or verbatim string value
+	Err        string  // simple error string value
+	Array      []Value // array, set or push value; map entries as alternating keys and values
+	IsNull     bool
+	Double     float64 // double value
+	Bool       bool    // boolean value
+	Format     string  // verbatim string format, such as "txt"
+	Attributes []Value // attributes sent ahead of the value, as alternating keys and values
+}
+
+// Limits on what a peer may send, as in Redis. They keep a malformed or
+// hostile stream from making the server allocate without bound.
+const (
+	// DefaultMaxBulkLen is the default longest bulk string, Redis'
+	// proto-max-bulk-len.
+	DefaultMaxBulkLen = 512 * 1024 * 1024
+	// MaxMultiBulkLen is the most arguments a request may have.
+	MaxMultiBulkLen = 1024 * 1024
+	// MaxInlineLen is the longest line accepted, be it an inline command
+	// or the header of a value.
+	MaxInlineLen = 64 * 1024
+
+	// bulkChunkLen is how much of a bulk string is allocated before any of
+	// it has arrived, and maxPreallocLen how many elements of an array.
+	bulkChunkLen   = 64 * 1024
+	maxPreallocLen = 1024
+)
+
+var maxBulkLen = DefaultMaxBulkLen
+
+// SetMaxBulkLen sets the longest bulk string deserializers accept. It must
+// be called before any of them is used.
+func SetMaxBulkLen(n int) {
+	maxBulkLen = n
+}
+
+// MaxBulkLen returns the longest bulk string deserializers accept.
+func MaxBulkLen() int {
+	return maxBulkLen
+}
+
+// ProtocolError is returned for a stream that does not follow the
+// protocol. Nothing after the error can be made sense of, so a server
+// replies with it and closes the connection.
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Reason
 }
 
+var errNoCRLF = &ProtocolError{Reason: "expected CRLF"}
+
 type Deserializer struct {
 	reader *bufio.Reader
 }
+
+// Serializer writes values to a buffered writer, encoding them in place
+// rather than building each reply as a byte slice first.
 type Serializer struct {
-	writer io.Writer
+	writer *bufio.Writer
 }
 
 func appendCRLF(data []byte) []byte {
@@ -55,41 +130,75 @@ func NewSerializer(w io.Writer) *Serializer {
 	return &Serializer{bufio.NewWriter(w)}
 }
 
-// read until we find \r\n
-// return line read and number of bytes read
-func (d *Deserializer) readLine() (line []byte, numBytes int, err error) {
+// readLineLF reads up to and including the next LF. A line that fits in
+// the read buffer is returned without being copied, so it is only valid
+// until the next read. A line longer than MaxInlineLen is refused with a
+// protocol error giving reason, so that a peer cannot make the reader
+// buffer without bound.
+func (d *Deserializer) readLineLF(reason string) ([]byte, error) {
+	line, err := d.reader.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return line, err
+	}
+
+	line = slices.Clone(line)
 	for {
-		b, err := d.reader.ReadByte()
-		if err != nil {
-			return nil, 0, err
+		chunk, err := d.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > MaxInlineLen {
+			return nil, &ProtocolError{Reason: reason}
 		}
-		numBytes += 1
-		line = append(line, b)
-		if len(line) >= 2 && line[len(line)-2] == '\r' {
-			break
+		if err != bufio.ErrBufferFull {
+			return line, err
 		}
 	}
-	return line[:len(line)-2], numBytes, nil
 }
-func (d *Deserializer) readIntegerInLine() (num int, numBytes int, err error) {
-	line, numBytes, err := d.readLine()
+
+// readLine reads a line terminated by CRLF and returns it without the CRLF,
+// along with the number of bytes read. The line is only valid until the
+// next read.
+func (d *Deserializer) readLine() (line []byte, numBytes int, err error) {
+	line, err = d.readLineLF("line too long")
 	if err != nil {
-		return 0, 0, err
+		return nil, 0, err
 	}
-	i64, err := strconv.ParseInt(string(line), 10, 64)
-	if err != nil {
-		return 0, numBytes, err
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, 0, errNoCRLF
+	}
+	return line[:len(line)-2], len(line), nil
+}
+
+// parseLength parses the length of a bulk string or an aggregate, which is
+// a decimal integer of at most 18 digits, or -1 for a null. Unlike
+// strconv.Atoi it works on the line in place, without allocating.
+func parseLength(line []byte) (int, bool) {
+	if len(line) == 2 && line[0] == '-' && line[1] == '1' {
+		return -1, true
+	}
+	if len(line) == 0 || len(line) > 18 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range line {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
 	}
-	return int(i64), numBytes, nil
+	return n, true
 }
 func (d *Deserializer) readBulk() (Value, error) {
 	v := Value{}
 	v.DataType = TypeBulk
 
-	strLen, _, err := d.readIntegerInLine()
+	line, _, err := d.readLine()
 	if err != nil {
 		return v, err
 	}
+	strLen, ok := parseLength(line)
+	if !ok || strLen > maxBulkLen {
+		return Value{}, &ProtocolError{Reason: "invalid bulk length"}
+	}
 
 	// handle NULL
 	if strLen == -1 {
@@ -98,29 +207,72 @@ func (d *Deserializer) readBulk() (Value, error) {
 		return v, nil
 	}
 
-	bulkString := make([]byte, strLen)
-	_, err = d.reader.Read(bulkString)
+	v.Bulk, err = d.readBulkPayload(strLen)
 	if err != nil {
 		return Value{}, err
 	}
-	v.Bulk = string(bulkString)
+	return v, nil
+}
+
+// readBulkPayload reads a bulk string of n bytes and the CRLF that ends
+// it. However short the reads from the connection are, it returns only once
+// all n bytes have arrived. The string is built straight from the read
+// buffer, and its memory is committed as the data arrives rather than all
+// at once, so declaring a huge length is not enough to make the reader
+// allocate it.
+func (d *Deserializer) readBulkPayload(n int) (string, error) {
+	var payload strings.Builder
+	payload.Grow(min(n, bulkChunkLen))
+	for payload.Len() < n {
+		if d.reader.Buffered() == 0 {
+			if _, err := d.reader.Peek(1); err != nil {
+				return "", noEOF(err)
+			}
+		}
+		chunk, _ := d.reader.Peek(min(n-payload.Len(), d.reader.Buffered()))
+		payload.Write(chunk)
+		d.reader.Discard(len(chunk))
+	}
 
-	// read the trailing CRLF
-	_, _, err = d.readLine()
+	crlf, err := d.reader.Peek(2)
 	if err != nil {
-		return Value{}, err
+		return "", noEOF(err)
 	}
-	return v, nil
+	if crlf[0] != '\r' || crlf[1] != '\n' {
+		return "", errNoCRLF
+	}
+	d.reader.Discard(2)
+	return payload.String(), nil
 }
+
+// noEOF reports a stream that ends in the middle of a value as
+// io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
 func (d *Deserializer) readArray() (Value, error) {
+	return d.readAggregate(TypeArray, 1)
+}
+
+// readAggregate reads the elements of an array, set, map or push value.
+// Maps have width 2: their length counts entries, each a key and a value.
+func (d *Deserializer) readAggregate(dataType DataType, width int) (Value, error) {
 	v := Value{}
-	v.DataType = TypeArray
+	v.DataType = dataType
 
 	// get the length of the array
-	arrLen, _, err := d.readIntegerInLine()
+	line, _, err := d.readLine()
 	if err != nil {
 		return v, err
 	}
+	arrLen, ok := parseLength(line)
+	if !ok {
+		return v, errors.New("invalid aggregate length")
+	}
 
 	// handle NULL
 	if arrLen == -1 {
@@ -128,15 +280,14 @@ func (d *Deserializer) readArray() (Value, error) {
 		v.DataType = TypeNull
 		return v, nil
 	}
-	// allocate a slice of arrLen
-	v.Array = make([]Value, arrLen)
-	// read each subsequent entry of the array and insert it into Value[]
-	for i := 0; i < arrLen; i++ {
+	// The slice grows as elements arrive rather than trusting the length
+	v.Array = make([]Value, 0, min(arrLen*width, maxPreallocLen))
+	for i := 0; i < arrLen*width; i++ {
 		val, err := d.Read()
 		if err != nil {
 			return v, err
 		}
-		v.Array[i] = val
+		v.Array = append(v.Array, val)
 	}
 	return v, nil
 }
@@ -162,7 +313,7 @@ func (d *Deserializer) readInteger() (Value, error) {
 	numStr := string(line)
 
 	// Check if the number is negative
-	sign := 1
+	sign := int64(1)
 	if len(numStr) > 0 && numStr[0] == '-' {
 		sign = -1
 		numStr = numStr[1:] // Remove the sign
@@ -171,7 +322,7 @@ func (d *Deserializer) readInteger() (Value, error) {
 	}
 
 	// Parse the number
-	num, err := strconv.Atoi(numStr)
+	num, err := strconv.ParseInt(numStr, 10, 64)
 	if err != nil {
 		return v, err
 	}
@@ -189,6 +340,90 @@ func (d *Deserializer) readError() (Value, error) {
 	v.Err = string(errorMsg)
 	return v, nil
 }
+func (d *Deserializer) readDouble() (Value, error) {
+	v := Value{}
+	v.DataType = TypeDouble
+	line, _, err := d.readLine()
+	if err != nil {
+		return v, err
+	}
+	v.Double, err = strconv.ParseFloat(string(line), 64)
+	return v, err
+}
+func (d *Deserializer) readBoolean() (Value, error) {
+	v := Value{}
+	v.DataType = TypeBoolean
+	line, _, err := d.readLine()
+	if err != nil {
+		return v, err
+	}
+	switch string(line) {
+	case "t":
+		v.Bool = true
+	case "f":
+	default:
+		return v, errors.New("invalid boolean")
+	}
+	return v, nil
+}
+func (d *Deserializer) readBigNumber() (Value, error) {
+	v := Value{}
+	v.DataType = TypeBigNumber
+	line, _, err := d.readLine()
+	if err != nil {
+		return v, err
+	}
+	digits := line
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 {
+		return v, errors.New("invalid big number")
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return v, errors.New("invalid big number")
+		}
+	}
+	v.Str = string(line)
+	return v, nil
+}
+func (d *Deserializer) readVerbatim() (Value, error) {
+	v, err := d.readBulk()
+	if err != nil || v.IsNull {
+		return v, err
+	}
+	// The payload starts with a three character format and a colon
+	if len(v.Bulk) < 4 || v.Bulk[3] != ':' {
+		return Value{}, errors.New("invalid verbatim string")
+	}
+	return Value{DataType: TypeVerbatim, Format: v.Bulk[:3], Bulk: v.Bulk[4:]}, nil
+}
+func (d *Deserializer) readNull() (Value, error) {
+	line, _, err := d.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+	if len(line) != 0 {
+		return Value{}, errors.New("invalid null")
+	}
+	return Value{DataType: TypeNil, IsNull: true}, nil
+}
+
+// readAttribute reads an attribute map and the value it belongs to, which
+// follows it.
+func (d *Deserializer) readAttribute() (Value, error) {
+	attributes, err := d.readAggregate(TypeMap, 2)
+	if err != nil {
+		return Value{}, err
+	}
+	v, err := d.Read()
+	if err != nil {
+		return v, err
+	}
+	v.Attributes = attributes.Array
+	return v, nil
+}
 func (d *Deserializer) Read() (Value, error) {
 	dataType, err := d.reader.ReadByte() // read the first byte of the RESP message to get the datatype
 	if err != nil {
@@ -205,83 +440,293 @@ func (d *Deserializer) Read() (Value, error) {
 		return d.readBulk()
 	case ARRAY:
 		return d.readArray()
+	case MAP:
+		return d.readAggregate(TypeMap, 2)
+	case SET:
+		return d.readAggregate(TypeSet, 1)
+	case PUSH:
+		return d.readAggregate(TypePush, 1)
+	case DOUBLE:
+		return d.readDouble()
+	case BOOLEAN:
+		return d.readBoolean()
+	case BIGNUMBER:
+		return d.readBigNumber()
+	case VERBATIM:
+		return d.readVerbatim()
+	case NULL:
+		return d.readNull()
+	case ATTRIBUTE:
+		return d.readAttribute()
 	default:
 		return Value{}, errors.New("invalid data type")
 	}
 }
 
+// Serialize returns the RESP encoding of v.
 func (v Value) Serialize() []byte {
+	return v.AppendTo(nil)
+}
+
+// AppendTo appends the RESP encoding of v to dst and returns the extended
+// slice. Nested values are appended in place, so a buffer that is reused
+// across calls makes serializing allocation free.
+func (v Value) AppendTo(dst []byte) []byte {
+	if len(v.Attributes) > 0 {
+		dst = appendHeader(dst, ATTRIBUTE, len(v.Attributes)/2)
+		for _, attribute := range v.Attributes {
+			dst = attribute.AppendTo(dst)
+		}
+	}
+	if prefix, width, ok := v.aggregate(); ok {
+		dst = appendHeader(dst, prefix, len(v.Array)/width)
+		for _, element := range v.Array {
+			dst = element.AppendTo(dst)
+		}
+		return dst
+	}
+
 	switch v.DataType {
-	case TypeArray:
-		return v.serializeArray()
 	case TypeBulk:
-		return v.serializeBulkString()
+		dst = appendHeader(dst, BULK, len(v.Bulk))
+		return appendCRLF(append(dst, v.Bulk...))
+	case TypeVerbatim:
+		dst = appendHeader(dst, VERBATIM, len(v.Format)+1+len(v.Bulk))
+		dst = append(append(dst, v.Format...), ':')
+		return appendCRLF(append(dst, v.Bulk...))
+	}
+	return v.appendScalar(dst)
+}
+
+// aggregate reports the prefix of v and the number of elements per entry
+// if it is a non-null aggregate.
+func (v Value) aggregate() (prefix byte, width int, ok bool) {
+	switch v.DataType {
+	case TypeArray:
+		// A null array, as opposed to a null bulk string, is a scalar
+		return ARRAY, 1, !v.IsNull
+	case TypeMap:
+		return MAP, 2, true
+	case TypeSet:
+		return SET, 1, true
+	case TypePush:
+		return PUSH, 1, true
+	case TypePairs:
+		return ARRAY, 1, true
+	}
+	return 0, 0, false
+}
+
+// appendScalar appends the encoding of a value that fits on one line.
+func (v Value) appendScalar(dst []byte) []byte {
+	switch v.DataType {
+	case TypeArray:
+		return append(dst, "*-1\r\n"...)
+	case TypeDouble:
+		dst = append(dst, DOUBLE)
+		return appendCRLF(appendDouble(dst, v.Double))
+	case TypeBoolean:
+		if v.Bool {
+			return append(dst, "#t\r\n"...)
+		}
+		return append(dst, "#f\r\n"...)
+	case TypeBigNumber:
+		return appendLine(dst, BIGNUMBER, v.Str)
+	case TypeNil:
+		return append(dst, "_\r\n"...)
 	case TypeString:
-		return v.serializeString()
+		return appendLine(dst, STRING, v.Str)
 	case TypeInteger:
-		return v.serializeInteger()
+		dst = append(dst, INTEGER)
+		return appendCRLF(strconv.AppendInt(dst, v.Num, 10))
 	case TypeError:
-		return v.serializeError()
+		return appendLine(dst, ERROR, v.Err)
 	case TypeNull:
-		return v.serializeNull()
-	default:
-		return []byte{}
-	}
-}
-func (v Value) serializeError() []byte {
-	var bytes []byte
-	bytes = append(bytes, ERROR)
-	bytes = append(bytes, v.Err...)
-	bytes = appendCRLF(bytes)
-	return bytes
-}
-func (v Value) serializeNull() []byte {
-	return []byte("$-1\r\n")
-}
-func (v Value) serializeString() []byte {
-	var bytes []byte
-	bytes = append(bytes, STRING)
-	bytes = append(bytes, v.Str...)
-	bytes = appendCRLF(bytes)
-	return bytes
-}
-func (v Value) serializeArray() []byte {
-	var bytes []byte
-	length := len(v.Array)
-	bytes = append(bytes, ARRAY)
-	bytes = append(bytes, strconv.Itoa(length)...)
-	bytes = appendCRLF(bytes)
-	for _, val := range v.Array {
-		bytes = append(bytes, val.Serialize()...)
-	}
-	return bytes
-}
-func (v Value) serializeBulkString() []byte {
-	var bytes []byte
-	length := len(v.Bulk)
-	bytes = append(bytes, BULK)
-	bytes = append(bytes, strconv.Itoa(length)...)
-	bytes = appendCRLF(bytes)
-	bytes = append(bytes, v.Bulk...)
-	bytes = appendCRLF(bytes)
-	return bytes
-}
-func (v Value) serializeInteger() []byte {
-	var bytes []byte
-	bytes = append(bytes, INTEGER)
-	bytes = append(bytes, strconv.Itoa(v.Num)...)
-	bytes = appendCRLF(bytes)
-	return bytes
+		return append(dst, "$-1\r\n"...)
+	}
+	return dst
+}
+
+func appendHeader(dst []byte, prefix byte, n int) []byte {
+	dst = append(dst, prefix)
+	return appendCRLF(strconv.AppendInt(dst, int64(n), 10))
+}
+
+func appendLine(dst []byte, prefix byte, line string) []byte {
+	dst = append(dst, prefix)
+	return appendCRLF(append(dst, line...))
+}
+
+// FormatDouble renders a double the way Redis replies with it: integral
+// and moderately sized values without an exponent, everything else in the
+// shortest representation that round-trips.
+func FormatDouble(f float64) string {
+	var buf [32]byte
+	return string(appendDouble(buf[:0], f))
 }
+
+func appendDouble(dst []byte, f float64) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return append(dst, "inf"...)
+	case math.IsInf(f, -1):
+		return append(dst, "-inf"...)
+	case math.IsNaN(f):
+		return append(dst, "nan"...)
+	}
+	abs := math.Abs(f)
+	if abs == 0 || (abs >= 1e-4 && abs < 1e21) {
+		return strconv.AppendFloat(dst, f, 'f', -1, 64)
+	}
+	return strconv.AppendFloat(dst, f, 'e', -1, 64)
+}
+
+// ForProtocol converts v for a connection speaking the given RESP
+// version. For RESP2, the RESP3 types are replaced by their RESP2
+// equivalents, as Redis does: maps, sets and pushes become arrays, pairs
+// are flattened into one array, doubles and big numbers become bulk
+// strings, booleans 0 or 1, and attributes are dropped. For RESP3, the null bulk string and null array become the RESP3
+// null. A reply with nothing to convert is returned as it is.
+func (v Value) ForProtocol(version int) Value {
+	if !v.needsConversion(version) {
+		return v
+	}
+	if version >= 3 {
+		if v.IsNull && (v.DataType == TypeNull || v.DataType == TypeArray) {
+			return Value{DataType: TypeNil, IsNull: true}
+		}
+	} else {
+		v.Attributes = nil
+		switch v.DataType {
+		case TypeMap, TypeSet, TypePush:
+			v.DataType = TypeArray
+		case TypeDouble:
+			return Value{DataType: TypeBulk, Bulk: FormatDouble(v.Double)}
+		case TypeBoolean:
+			if v.Bool {
+				return Value{DataType: TypeInteger, Num: 1}
+			}
+			return Value{DataType: TypeInteger, Num: 0}
+		case TypeBigNumber:
+			return Value{DataType: TypeBulk, Bulk: v.Str}
+		case TypeVerbatim:
+			return Value{DataType: TypeBulk, Bulk: v.Bulk}
+		case TypeNil:
+			return Value{DataType: TypeNull, IsNull: true}
+		case TypePairs:
+			array := make([]Value, 0, 2*len(v.Array))
+			for _, pair := range v.Array {
+				for _, element := range pair.Array {
+					array = append(array, element.ForProtocol(version))
+				}
+			}
+			return Value{DataType: TypeArray, Array: array}
+		}
+	}
+
+	// Elements are converted into a new slice, as the same reply may be
+	// sent to clients speaking different versions
+	if len(v.Array) > 0 {
+		array := make([]Value, len(v.Array))
+		for i, element := range v.Array {
+			array[i] = element.ForProtocol(version)
+		}
+		v.Array = array
+	}
+	if len(v.Attributes) > 0 {
+		attributes := make([]Value, len(v.Attributes))
+		for i, attribute := range v.Attributes {
+			attributes[i] = attribute.ForProtocol(version)
+		}
+		v.Attributes = attributes
+	}
+	return v
+}
+
+// needsConversion reports whether ForProtocol has anything to change in v
+// or the values it holds.
+func (v Value) needsConversion(version int) bool {
+	if version >= 3 {
+		if v.IsNull && (v.DataType == TypeNull || v.DataType == TypeArray) {
+			return true
+		}
+	} else if v.DataType >= TypeMap || len(v.Attributes) > 0 {
+		return true
+	}
+	for _, element := range v.Array {
+		if element.needsConversion(version) {
+			return true
+		}
+	}
+	for _, attribute := range v.Attributes {
+		if attribute.needsConversion(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write buffers v, to be sent with the next Flush or once the buffer is
+// full. It returns the error writing to the underlying writer ran into, if
+// any.
 func (s *Serializer) Write(v Value) error {
-	var bytes = v.Serialize()
-	_, err := s.writer.Write(bytes)
-	if f, ok := s.writer.(interface{ Flush() error }); ok {
-		return f.Flush()
+	s.writeValue(v)
+	// bufio.Writer keeps the first error it runs into, and an empty write
+	// returns it
+	_, err := s.writer.Write(nil)
+	return err
+}
+
+// Flush sends everything buffered to the underlying writer.
+func (s *Serializer) Flush() error {
+	return s.writer.Flush()
+}
+
+// writeValue encodes v straight into the buffered writer. Headers and other
+// short lines are appended to the writer's free space, and bulk strings are
+// copied into it, so no intermediate slice is allocated however large v is.
+// Errors stick to the writer and are reported by the next Flush.
+func (s *Serializer) writeValue(v Value) {
+	if len(v.Attributes) > 0 {
+		s.writer.Write(appendHeader(s.writer.AvailableBuffer(), ATTRIBUTE, len(v.Attributes)/2))
+		for _, attribute := range v.Attributes {
+			s.writeValue(attribute)
+		}
 	}
-	if err != nil {
-		log.Println(err)
-		return err
+	if prefix, width, ok := v.aggregate(); ok {
+		s.writer.Write(appendHeader(s.writer.AvailableBuffer(), prefix, len(v.Array)/width))
+		for _, element := range v.Array {
+			s.writeValue(element)
+		}
+		return
+	}
+
+	switch v.DataType {
+	case TypeBulk:
+		s.writer.Write(appendHeader(s.writer.AvailableBuffer(), BULK, len(v.Bulk)))
+		s.writer.WriteString(v.Bulk)
+		s.writer.WriteString("\r\n")
+	case TypeVerbatim:
+		s.writer.Write(appendHeader(s.writer.AvailableBuffer(), VERBATIM, len(v.Format)+1+len(v.Bulk)))
+		s.writer.WriteString(v.Format)
+		s.writer.WriteByte(':')
+		s.writer.WriteString(v.Bulk)
+		s.writer.WriteString("\r\n")
+	case TypeString:
+		s.writeLine(STRING, v.Str)
+	case TypeError:
+		s.writeLine(ERROR, v.Err)
+	case TypeBigNumber:
+		s.writeLine(BIGNUMBER, v.Str)
+	default:
+		s.writer.Write(v.appendScalar(s.writer.AvailableBuffer()))
 	}
-	return nil
+}
+
+// writeLine writes a line that may be long, copying it into the writer
+// rather than appending it to the free space, which could outgrow it.
+func (s *Serializer) writeLine(prefix byte, line string) {
+	s.writer.WriteByte(prefix)
+	s.writer.WriteString(line)
+	s.writer.WriteString("\r\n")
 }
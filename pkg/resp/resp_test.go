@@ -3,8 +3,10 @@ package resp
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
+	"testing/iotest"
 )
 
 func TestSerializeDeserialize(t *testing.T) {
@@ -15,56 +17,99 @@ func TestSerializeDeserialize(t *testing.T) {
 		{
 			name: "Simple String",
 			value: Value{
-				DataType: "STRING",
-				str:      "Hello, World!",
+				DataType: TypeString,
+				Str:      "Hello, World!",
 			},
 		},
 		{
 			name: "Integer",
 			value: Value{
-				DataType: "INTEGER",
-				num:      42,
+				DataType: TypeInteger,
+				Num:      42,
 			},
 		},
 		{
 			name: "Negative Integer",
 			value: Value{
-				DataType: "INTEGER",
-				num:      -15,
+				DataType: TypeInteger,
+				Num:      -15,
 			},
 		},
 		{
 			name: "Bulk String",
 			value: Value{
-				DataType: "BULK",
-				bulk:     "This is a bulk string",
+				DataType: TypeBulk,
+				Bulk:     "This is a bulk string",
 			},
 		},
 		{
 			name: "Error",
 			value: Value{
-				DataType: "ERROR",
-				err:      "Error message",
+				DataType: TypeError,
+				Err:      "Error message",
 			},
 		},
 		{
 			name: "Null",
 			value: Value{
-				DataType: "NULL",
-				isNull:   true,
+				DataType: TypeNull,
+				IsNull:   true,
 			},
 		},
 		{
 			name: "Array",
 			value: Value{
-				DataType: "ARRAY",
-				array: []Value{
-					{DataType: "STRING", str: "item1"},
-					{DataType: "INTEGER", num: 2},
-					{DataType: "BULK", bulk: "item3"},
+				DataType: TypeArray,
+				Array: []Value{
+					{DataType: TypeString, Str: "item1"},
+					{DataType: TypeInteger, Num: 2},
+					{DataType: TypeBulk, Bulk: "item3"},
 				},
 			},
 		},
+		{
+			name: "Map",
+			value: Value{
+				DataType: TypeMap,
+				Array: []Value{
+					{DataType: TypeBulk, Bulk: "key"},
+					{DataType: TypeInteger, Num: 1},
+					{DataType: TypeString, Str: "other"},
+					{DataType: TypeSet, Array: []Value{{DataType: TypeBoolean, Bool: true}}},
+				},
+			},
+		},
+		{
+			name:  "Double",
+			value: Value{DataType: TypeDouble, Double: -1.5},
+		},
+		{
+			name:  "Big Number",
+			value: Value{DataType: TypeBigNumber, Str: "-3492890328409238509324850943850943825024385"},
+		},
+		{
+			name:  "Verbatim String",
+			value: Value{DataType: TypeVerbatim, Format: "txt", Bulk: "Some string"},
+		},
+		{
+			name:  "RESP3 Null",
+			value: Value{DataType: TypeNil, IsNull: true},
+		},
+		{
+			name: "Push",
+			value: Value{
+				DataType: TypePush,
+				Array:    []Value{{DataType: TypeBulk, Bulk: "message"}, {DataType: TypeBulk, Bulk: "hello"}},
+			},
+		},
+		{
+			name: "Attribute",
+			value: Value{
+				DataType:   TypeInteger,
+				Num:        7,
+				Attributes: []Value{{DataType: TypeBulk, Bulk: "ttl"}, {DataType: TypeInteger, Num: 3600}},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -108,7 +153,52 @@ func TestDeserializerErrors(t *testing.T) {
 		{
 			name:        "Invalid integer",
 			input:       []byte(":abc\r\n"),
-			expectedErr: "strconv.Atoi: parsing \"abc\": invalid syntax",
+			expectedErr: "strconv.ParseInt: parsing \"abc\": invalid syntax",
+		},
+		{
+			name:        "Invalid boolean",
+			input:       []byte("#x\r\n"),
+			expectedErr: "invalid boolean",
+		},
+		{
+			name:        "Invalid big number",
+			input:       []byte("(12a\r\n"),
+			expectedErr: "invalid big number",
+		},
+		{
+			name:        "Verbatim string without format",
+			input:       []byte("=3\r\ntxt\r\n"),
+			expectedErr: "invalid verbatim string",
+		},
+		{
+			name:        "Line without CR",
+			input:       []byte("+OK\n"),
+			expectedErr: "Protocol error: expected CRLF",
+		},
+		{
+			name:        "Line too long",
+			input:       append(bytes.Repeat([]byte("+"), MaxInlineLen+1), "\r\n"...),
+			expectedErr: "Protocol error: line too long",
+		},
+		{
+			name:        "Bulk string longer than declared",
+			input:       []byte("$3\r\nabcd\r\n"),
+			expectedErr: "Protocol error: expected CRLF",
+		},
+		{
+			name:        "Negative bulk length",
+			input:       []byte("$-2\r\n"),
+			expectedErr: "Protocol error: invalid bulk length",
+		},
+		{
+			name:        "Bulk length over the limit",
+			input:       []byte(fmt.Sprintf("$%d\r\n", DefaultMaxBulkLen+1)),
+			expectedErr: "Protocol error: invalid bulk length",
+		},
+		{
+			name:        "Truncated bulk string",
+			input:       []byte("$5\r\nab"),
+			expectedErr: "unexpected EOF",
 		},
 	}
 
@@ -129,6 +219,24 @@ func TestDeserializerErrors(t *testing.T) {
 	}
 }
 
+func TestDeserializerReadsLargeBulkStringsInFull(t *testing.T) {
+	payload := make([]byte, 3*1024*1024+7)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	input := Value{DataType: TypeBulk, Bulk: string(payload)}.Serialize()
+
+	// HalfReader hands the payload over in ever smaller reads
+	deserializer := NewDeserializer(iotest.HalfReader(bytes.NewReader(input)))
+	v, err := deserializer.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v.Bulk != string(payload) {
+		t.Fatalf("Expected the %d byte payload, but got %d bytes", len(payload), len(v.Bulk))
+	}
+}
+
 func TestSerializerMethods(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -137,34 +245,74 @@ func TestSerializerMethods(t *testing.T) {
 	}{
 		{
 			name:     "Serialize Simple String",
-			value:    Value{DataType: "STRING", str: "Hello"},
+			value:    Value{DataType: TypeString, Str: "Hello"},
 			expected: []byte("+Hello\r\n"),
 		},
 		{
 			name:     "Serialize Integer",
-			value:    Value{DataType: "INTEGER", num: 42},
-			expected: []byte(":+42\r\n"),
+			value:    Value{DataType: TypeInteger, Num: 42},
+			expected: []byte(":42\r\n"),
 		},
 		{
 			name:     "Serialize Negative Integer",
-			value:    Value{DataType: "INTEGER", num: -15},
+			value:    Value{DataType: TypeInteger, Num: -15},
 			expected: []byte(":-15\r\n"),
 		},
 		{
 			name:     "Serialize Bulk String",
-			value:    Value{DataType: "BULK", bulk: "Hello, World!"},
+			value:    Value{DataType: TypeBulk, Bulk: "Hello, World!"},
 			expected: []byte("$13\r\nHello, World!\r\n"),
 		},
 		{
 			name:     "Serialize Error",
-			value:    Value{DataType: "ERROR", err: "Error occurred"},
+			value:    Value{DataType: TypeError, Err: "Error occurred"},
 			expected: []byte("-Error occurred\r\n"),
 		},
 		{
 			name:     "Serialize Null",
-			value:    Value{DataType: "NULL"},
+			value:    Value{DataType: TypeNull},
 			expected: []byte("$-1\r\n"),
 		},
+		{
+			name:     "Serialize Null Array",
+			value:    Value{DataType: TypeArray, IsNull: true},
+			expected: []byte("*-1\r\n"),
+		},
+		{
+			name:     "Serialize Map",
+			value:    Value{DataType: TypeMap, Array: []Value{{DataType: TypeString, Str: "first"}, {DataType: TypeInteger, Num: 1}}},
+			expected: []byte("%1\r\n+first\r\n:1\r\n"),
+		},
+		{
+			name:     "Serialize Double",
+			value:    Value{DataType: TypeDouble, Double: 0.1},
+			expected: []byte(",0.1\r\n"),
+		},
+		{
+			name:     "Serialize Infinite Double",
+			value:    Value{DataType: TypeDouble, Double: math.Inf(-1)},
+			expected: []byte(",-inf\r\n"),
+		},
+		{
+			name:     "Serialize Boolean",
+			value:    Value{DataType: TypeBoolean, Bool: false},
+			expected: []byte("#f\r\n"),
+		},
+		{
+			name:     "Serialize Verbatim String",
+			value:    Value{DataType: TypeVerbatim, Format: "txt", Bulk: "Some string"},
+			expected: []byte("=15\r\ntxt:Some string\r\n"),
+		},
+		{
+			name:     "Serialize RESP3 Null",
+			value:    Value{DataType: TypeNil, IsNull: true},
+			expected: []byte("_\r\n"),
+		},
+		{
+			name:     "Serialize Attribute",
+			value:    Value{DataType: TypeString, Str: "OK", Attributes: []Value{{DataType: TypeBulk, Bulk: "a"}, {DataType: TypeBoolean, Bool: true}}},
+			expected: []byte("|1\r\n$1\r\na\r\n#t\r\n+OK\r\n"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -173,6 +321,19 @@ func TestSerializerMethods(t *testing.T) {
 			if !bytes.Equal(result, tc.expected) {
 				t.Errorf("Expected %v, but got %v", tc.expected, result)
 			}
+
+			// Serializer encodes straight into its buffer, separately
+			var written bytes.Buffer
+			serializer := NewSerializer(&written)
+			if err := serializer.Write(tc.value); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := serializer.Flush(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(written.Bytes(), tc.expected) {
+				t.Errorf("Expected Write to produce %q, but got %q", tc.expected, written.Bytes())
+			}
 		})
 	}
 }
@@ -186,32 +347,62 @@ func TestDeserializerMethods(t *testing.T) {
 		{
 			name:     "Deserialize Simple String",
 			input:    []byte("+Hello\r\n"),
-			expected: Value{DataType: "STRING", str: "Hello"},
+			expected: Value{DataType: TypeString, Str: "Hello"},
 		},
 		{
 			name:     "Deserialize Integer",
 			input:    []byte(":42\r\n"),
-			expected: Value{DataType: "INTEGER", num: 42},
+			expected: Value{DataType: TypeInteger, Num: 42},
 		},
 		{
 			name:     "Deserialize Negative Integer",
 			input:    []byte(":-15\r\n"),
-			expected: Value{DataType: "INTEGER", num: -15},
+			expected: Value{DataType: TypeInteger, Num: -15},
 		},
 		{
 			name:     "Deserialize Bulk String",
 			input:    []byte("$13\r\nHello, World!\r\n"),
-			expected: Value{DataType: "BULK", bulk: "Hello, World!"},
+			expected: Value{DataType: TypeBulk, Bulk: "Hello, World!"},
 		},
 		{
 			name:     "Deserialize Error",
 			input:    []byte("-Error occurred\r\n"),
-			expected: Value{DataType: "ERROR", err: "Error occurred"},
+			expected: Value{DataType: TypeError, Err: "Error occurred"},
 		},
 		{
 			name:     "Deserialize Null",
 			input:    []byte("$-1\r\n"),
-			expected: Value{DataType: "NULL", isNull: true},
+			expected: Value{DataType: TypeNull, IsNull: true},
+		},
+		{
+			name:     "Deserialize Set",
+			input:    []byte("~2\r\n+a\r\n+b\r\n"),
+			expected: Value{DataType: TypeSet, Array: []Value{{DataType: TypeString, Str: "a"}, {DataType: TypeString, Str: "b"}}},
+		},
+		{
+			name:     "Deserialize Double",
+			input:    []byte(",1.23\r\n"),
+			expected: Value{DataType: TypeDouble, Double: 1.23},
+		},
+		{
+			name:     "Deserialize Infinite Double",
+			input:    []byte(",inf\r\n"),
+			expected: Value{DataType: TypeDouble, Double: math.Inf(1)},
+		},
+		{
+			name:     "Deserialize Boolean",
+			input:    []byte("#t\r\n"),
+			expected: Value{DataType: TypeBoolean, Bool: true},
+		},
+		{
+			name:     "Deserialize Big Number",
+			input:    []byte("(3492890328409238509324850943850943825024385\r\n"),
+			expected: Value{DataType: TypeBigNumber, Str: "3492890328409238509324850943850943825024385"},
+		},
+		{
+			name:     "Deserialize RESP3 Null",
+			input:    []byte("_\r\n"),
+			expected: Value{DataType: TypeNil, IsNull: true},
 		},
 	}
 
@@ -229,3 +420,46 @@ func TestDeserializerMethods(t *testing.T) {
 		})
 	}
 }
+
+func TestForProtocol(t *testing.T) {
+	reply := Value{DataType: TypeMap, Array: []Value{
+		{DataType: TypeBulk, Bulk: "score"},
+		{DataType: TypeDouble, Double: 1.5},
+		{DataType: TypeBulk, Bulk: "members"},
+		{DataType: TypeSet, Array: []Value{{DataType: TypeBoolean, Bool: true}, {DataType: TypeNil, IsNull: true}}},
+	}, Attributes: []Value{{DataType: TypeBulk, Bulk: "key"}, {DataType: TypeBulk, Bulk: "value"}}}
+
+	expected := []byte("*4\r\n$5\r\nscore\r\n$3\r\n1.5\r\n$7\r\nmembers\r\n*2\r\n:1\r\n$-1\r\n")
+	if result := reply.ForProtocol(2).Serialize(); !bytes.Equal(result, expected) {
+		t.Errorf("Expected %q for RESP2, but got %q", expected, result)
+	}
+	if reply.Array[1].DataType != TypeDouble {
+		t.Error("Converting a reply modified it")
+	}
+
+	nulls := Value{DataType: TypeArray, Array: []Value{{DataType: TypeNull, IsNull: true}, {DataType: TypeArray, IsNull: true}}}
+	expected = []byte("*2\r\n_\r\n_\r\n")
+	if result := nulls.ForProtocol(3).Serialize(); !bytes.Equal(result, expected) {
+		t.Errorf("Expected %q for RESP3, but got %q", expected, result)
+	}
+
+	pairs := Value{DataType: TypePairs, Array: []Value{
+		{DataType: TypeArray, Array: []Value{{DataType: TypeBulk, Bulk: "a"}, {DataType: TypeDouble, Double: 1}}},
+		{DataType: TypeArray, Array: []Value{{DataType: TypeBulk, Bulk: "b"}, {DataType: TypeDouble, Double: 2.5}}},
+	}}
+	expected = []byte("*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$3\r\n2.5\r\n")
+	if result := pairs.ForProtocol(2).Serialize(); !bytes.Equal(result, expected) {
+		t.Errorf("Expected %q for RESP2, but got %q", expected, result)
+	}
+	expected = []byte("*2\r\n*2\r\n$1\r\na\r\n,1\r\n*2\r\n$1\r\nb\r\n,2.5\r\n")
+	if result := pairs.ForProtocol(3).Serialize(); !bytes.Equal(result, expected) {
+		t.Errorf("Expected %q for RESP3, but got %q", expected, result)
+	}
+
+	array := Value{DataType: TypeArray, Array: []Value{{DataType: TypeBulk, Bulk: "a"}, {DataType: TypeInteger, Num: 1}}}
+	for _, version := range []int{2, 3} {
+		if allocs := testing.AllocsPerRun(100, func() { array.ForProtocol(version) }); allocs != 0 {
+			t.Errorf("Expected an array with nothing to convert to be returned as it is for RESP%d, but it took %v allocations", version, allocs)
+		}
+	}
+}
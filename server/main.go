@@ -1,66 +1,247 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"go-redis/pkg/commands"
 	"go-redis/pkg/resp"
 	"log"
 	"net"
-	"strings"
+	"sync/atomic"
+	"time"
 )
 
 func handleConnection(conn net.Conn) {
 	defer conn.Close()
 
-	deserializer := resp.NewDeserializer(conn)
+	client := commands.NewClient()
+	batch := &replyBatch{client: client}
+	deserializer := resp.NewDeserializer(&waitingReader{conn: conn, batch: batch})
 	serializer := resp.NewSerializer(conn)
 
 	greetingMsg := "-REDIS 0.0.1 go-redis-server 00000000:0 standalone"
-	err := serializer.Write(resp.Value{DataType: resp.TypeString, Str: greetingMsg})
-	if err != nil {
+	serializer.Write(resp.Value{DataType: resp.TypeString, Str: greetingMsg})
+	if err := serializer.Flush(); err != nil {
 		log.Println("Error sending greeting:", err)
 		return
 	}
 
-	for {
-		value, err := deserializer.Read()
-		if err != nil {
-			log.Println("Error reading from connection:", err)
+	// Closing the client stops the writer, which first flushes whatever is
+	// still queued, such as the error reply to a malformed request
+	written := make(chan struct{})
+	defer func() {
+		client.Close()
+		<-written
+	}()
+
+	// Requests are read on their own goroutine so that a client going away
+	// is noticed even while one of its commands is blocked. Replies and
+	// published messages are written by another, in the order they were
+	// queued on the client.
+	requests := make(chan resp.Value)
+	go readRequests(deserializer, requests, client, batch)
+	go func() {
+		writeReplies(serializer, conn, client)
+		close(written)
+	}()
+
+	for value := range requests {
+		// A malformed request ends the connection: nothing after it can
+		// be read
+		if value.DataType == resp.TypeError {
+			client.Reply(value)
 			return
 		}
 
-		if value.DataType != resp.TypeArray {
-			log.Println("unexpected data type:", value.DataType, " ,expected array")
-			continue
+		result, ok := commands.Execute(client, value.Array[0].Bulk, value.Array[1:])
+		if !ok {
+			log.Println("Unknown command:", value.Array[0].Bulk)
 		}
 
-		if len(value.Array) == 0 {
-			log.Println("Invalid request, expected array length > 0")
-			continue
+		if !client.Reply(result) {
+			return
 		}
+		batch.requestAnswered()
+	}
+}
+
+// replyBatch decides when the replies to a connection are flushed. That is
+// once the main loop has answered every request read before the reader ran
+// out of input, so that the replies to pipelined requests go out in one
+// write rather than in one write each.
+type replyBatch struct {
+	client   *commands.Client
+	read     atomic.Int64 // requests passed on to the main loop
+	answered atomic.Int64 // requests the main loop has answered
+	waiting  atomic.Int64 // requests read when the reader last ran out of input
+}
 
-		command := strings.ToUpper(value.Array[0].Bulk)
-		args := value.Array[1:]
+// readerWaiting is called by the reader before it waits for more input.
+func (b *replyBatch) readerWaiting() {
+	read := b.read.Load()
+	b.waiting.Store(read)
+	// Either the reader sees that the main loop has caught up, or the main
+	// loop sees that the reader is waiting, so one of them flushes
+	if b.answered.Load() >= read {
+		b.client.Flush()
+	}
+}
 
-		handler, ok := commands.CommandHandler[command]
-		if !ok {
-			log.Println("Invalid command:", command)
+// requestAnswered is called by the main loop once the reply to a request
+// is queued.
+func (b *replyBatch) requestAnswered() {
+	answered := b.answered.Add(1)
+	if b.waiting.Load() >= answered {
+		b.client.Flush()
+	}
+}
+
+// waitingReader reads from the connection on behalf of the deserializer,
+// which only reads once the requests it has buffered are used up.
+type waitingReader struct {
+	conn  net.Conn
+	batch *replyBatch
+}
+
+func (r *waitingReader) Read(p []byte) (int, error) {
+	r.batch.readerWaiting()
+	return r.conn.Read(p)
+}
+
+// flushTimeout bounds how long a closed client is given to read the
+// replies still queued for it.
+const flushTimeout = time.Second
+
+// writeReplies writes everything queued on the client to the connection,
+// flushing when asked to, until the client is closed. It then flushes what
+// is left.
+func writeReplies(serializer *resp.Serializer, conn net.Conn, client *commands.Client) {
+	for {
+		select {
+		case value := <-client.Replies():
+			if err := serializer.Write(value); err != nil {
+				log.Println("Error writing response:", err)
+				client.Close()
+				return
+			}
+		case <-client.Flushes():
+			err := writeQueued(serializer, client)
+			if err == nil {
+				err = serializer.Flush()
+			}
 			if err != nil {
 				log.Println("Error writing response:", err)
+				client.Close()
+				return
 			}
-			continue
+		case <-client.Done():
+			conn.SetWriteDeadline(time.Now().Add(flushTimeout))
+			if writeQueued(serializer, client) == nil {
+				serializer.Flush()
+			}
+			return
+		}
+	}
+}
+
+// writeQueued writes the replies already queued on the client.
+func writeQueued(serializer *resp.Serializer, client *commands.Client) error {
+	for {
+		select {
+		case value := <-client.Replies():
+			if err := serializer.Write(value); err != nil {
+				return err
+			}
+		default:
+			return nil
 		}
+	}
+}
 
-		result := handler(args)
-		err = serializer.Write(result)
-		if err != nil {
-			log.Println("Error writing response:", err)
+// readRequests feeds every request read from the connection into requests
+// until reading fails. A malformed request is passed on as the error reply
+// that answers it; any other failure closes the client.
+func readRequests(deserializer *resp.Deserializer, requests chan<- resp.Value, client *commands.Client, batch *replyBatch) {
+	defer close(requests)
+
+	// Requests are read into two values in turn, reusing their arrays.
+	// requests is unbuffered, so by the time the main loop has received
+	// one value it is done with the other.
+	var buffers [2]resp.Value
+	for i := 0; ; i ^= 1 {
+		err := deserializer.ReadCommandInto(&buffers[i])
+		value := buffers[i]
+		var protocolErr *resp.ProtocolError
+		if errors.As(err, &protocolErr) {
+			log.Println("Closing client after a malformed request:", err)
+			value = resp.Value{DataType: resp.TypeError, Err: "ERR " + err.Error()}
+		} else if err != nil {
+			log.Println("Error reading from connection:", err)
+			client.Close()
+			return
+		}
+		batch.read.Add(1)
+		select {
+		case requests <- value:
+		case <-client.Done():
+			return
+		}
+		if protocolErr != nil {
+			return
 		}
 	}
 }
 
 func main() {
+	dbFilename := flag.String("dbfilename", "dump.rdb", "path of the snapshot file")
+	save := flag.String("save", "3600 1 300 100 60 10000", `snapshot after "seconds changes" pairs, "" to disable`)
+	appendOnly := flag.Bool("appendonly", false, "log every write to the append only file and load it instead of the snapshot")
+	appendFilename := flag.String("appendfilename", "appendonly.aof", "path of the append only file")
+	appendFsync := flag.String("appendfsync", "everysec", "when to fsync the append only file: always, everysec or no")
+	protoMaxBulkLen := flag.Int("proto-max-bulk-len", resp.DefaultMaxBulkLen, "longest bulk string accepted, in bytes")
+	databases := flag.Int("databases", commands.DefaultDatabases, "number of databases, numbered from 0")
+	luaTimeLimit := flag.Duration("lua-time-limit", commands.DefaultScriptTimeLimit, "how long a script that has not written may run before it is stopped")
+	flag.Parse()
+
+	if *protoMaxBulkLen < 1 {
+		log.Fatalln("proto-max-bulk-len must be positive")
+	}
+	resp.SetMaxBulkLen(*protoMaxBulkLen)
+	if *databases < 1 {
+		log.Fatalln("databases must be positive")
+	}
+	commands.SetDatabases(*databases)
+	if *luaTimeLimit <= 0 {
+		log.Fatalln("lua-time-limit must be positive")
+	}
+	commands.SetScriptTimeLimit(*luaTimeLimit)
+
+	rules, err := commands.ParseSaveRules(*save)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fsync, err := commands.ParseAppendFsync(*appendFsync)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	fmt.Println("***********Go-Redis-Server***********")
+	commands.SetSnapshotPath(*dbFilename)
+	if *appendOnly {
+		replayed, err := commands.LoadAppendOnly(*appendFilename, fsync)
+		if err != nil {
+			log.Fatalln("Error loading append only file:", err)
+		}
+		fmt.Printf("Replayed %d commands from %s\n", replayed, *appendFilename)
+	} else {
+		loaded, err := commands.LoadSnapshot(*dbFilename)
+		if err != nil {
+			log.Fatalln("Error loading snapshot:", err)
+		}
+		fmt.Printf("Loaded %d keys from %s\n", loaded, *dbFilename)
+	}
+
 	// start a server on port 6379
 	l, err := net.Listen("tcp", ":6379")
 	if err != nil {
@@ -68,6 +249,11 @@ func main() {
 	}
 	defer l.Close()
 
+	go commands.RunActiveExpire()
+	if len(rules) > 0 {
+		go commands.RunSaveRules(rules)
+	}
+
 	fmt.Println("Server is listening on port 6379")
 	for {
 		fmt.Println("Waiting for a connection...")
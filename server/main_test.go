@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Connections log as they come and go
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// countingConn counts the writes made to a connection, each of which is a
+// system call.
+type countingConn struct {
+	net.Conn
+	writes *atomic.Int64
+}
+
+func (c countingConn) Write(p []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(p)
+}
+
+// startServer serves connections on a local port, returning its address
+// and the number of writes made to the connections so far.
+func startServer(tb testing.TB) (string, *atomic.Int64) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { l.Close() })
+
+	writes := new(atomic.Int64)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handleConnection(countingConn{conn, writes})
+		}
+	}()
+	return l.Addr().String(), writes
+}
+
+// dial connects to the server and reads its greeting.
+func dial(tb testing.TB, addr string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { conn.Close() })
+	reader := bufio.NewReader(conn)
+	if _, err := reader.ReadString('\n'); err != nil {
+		tb.Fatal(err)
+	}
+	return conn, reader
+}
+
+// pingPipeline returns count PING commands, and the replies to them.
+func pingPipeline(count int) (request, replies []byte) {
+	return bytes.Repeat([]byte("*1\r\n$4\r\nPING\r\n"), count), bytes.Repeat([]byte("+PONG\r\n"), count)
+}
+
+func TestPipelinedRepliesAreBatched(t *testing.T) {
+	addr, writes := startServer(t)
+	conn, reader := dial(t, addr)
+	before := writes.Load()
+
+	const count = 1000
+	request, expected := pingPipeline(count)
+	if _, err := conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	replies := make([]byte, len(expected))
+	if _, err := io.ReadFull(reader, replies); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(replies, expected) {
+		t.Fatalf("expected %d PONGs, got %q", count, replies)
+	}
+
+	// The replies fill a few buffers, and the request arrives in a few
+	// reads, each of which may end a batch
+	if n := writes.Load() - before; n > count/10 {
+		t.Fatalf("expected the %d replies to be batched, but they took %d writes", count, n)
+	}
+}
+
+func TestRepliesAreFlushedWithoutPipelining(t *testing.T) {
+	addr, _ := startServer(t)
+	conn, reader := dial(t, addr)
+
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(conn, "ECHO %d\r\n", i)
+		reply, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("+%d\r\n", i); reply != expected {
+			t.Fatalf("expected %q, got %q", expected, reply)
+		}
+	}
+}
+
+func BenchmarkPipelinedPing(b *testing.B) {
+	for _, depth := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("depth %d", depth), func(b *testing.B) {
+			addr, writes := startServer(b)
+			conn, reader := dial(b, addr)
+			request, expected := pingPipeline(depth)
+			replies := make([]byte, len(expected))
+			before := writes.Load()
+
+			b.ResetTimer()
+			for sent := 0; sent < b.N; sent += depth {
+				if _, err := conn.Write(request); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.ReadFull(reader, replies); err != nil {
+					b.Fatal(err)
+				}
+			}
+			// Each op is one command, so the time per op is the inverse of
+			// the throughput, and writes/op the system calls per reply
+			b.ReportMetric(float64(writes.Load()-before)/float64(b.N), "writes/op")
+		})
+	}
+}